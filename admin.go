@@ -2,22 +2,54 @@ package main
 
 import (
 	"fmt"
+	"log/slog"
 	"net/http"
 )
 
+// fileServerPrefix is the path prefix the file server's hits are counted
+// under in analytics.
+const fileServerPrefix = "/app/"
+
+// fileServerHits reports how often the file server has been visited, from
+// the persisted analytics so the count survives restarts and covers every
+// replica.
 func (cfg *apiConfig) fileServerHits(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	// Hits still buffered in this replica are flushed first so the page
+	// includes them.
+	if err := cfg.analytics.Flush(r.Context()); err != nil {
+		slog.ErrorContext(r.Context(), "error flushing analytics", "error", err)
+	}
+	hits, err := cfg.analyticsStore.CountHits(r.Context(), fileServerPrefix)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error counting file server hits", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't count file server hits")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprintf(w, `<html>
   <body>
 	<h1>Welcome, Chirpy Admin</h1>
 	<p>Chirpy has been visited %d times!</p>
   </body>
-</html>`, cfg.hits.Load())
+</html>`, hits)
 }
 
+// fileServerReset deletes the file server's hits from analytics and reports
+// how many there were.
 func (cfg *apiConfig) fileServerReset(w http.ResponseWriter, r *http.Request) {
+	if err := cfg.analytics.Flush(r.Context()); err != nil {
+		slog.ErrorContext(r.Context(), "error flushing analytics", "error", err)
+	}
+	hits, err := cfg.analyticsStore.DeleteHits(r.Context(), fileServerPrefix)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error resetting file server hits", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't reset file server hits")
+		return
+	}
+
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, "%d", cfg.hits.Swap(0))
+	fmt.Fprintf(w, "%d", hits)
 }
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFileServerHits(t *testing.T) {
+	db := &fakeDB{}
+	_, handler := newTestAPI(t, sql.OpenDB(db))
+	admin := http.Header{"Authorization": {"ApiKey " + testAdminKey}}
+
+	do := func(method, path string, header http.Header) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header = header.Clone()
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for range 3 {
+		if rec := do("GET", "/app/", nil); rec.Code != http.StatusOK {
+			t.Fatalf("GET /app/: status = %d, want 200", rec.Code)
+		}
+	}
+	// Other requests aren't file server hits.
+	do("GET", "/api/chirps", nil)
+
+	if rec := do("GET", "/admin/metrics", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("metrics without the admin key: status = %d, want 401", rec.Code)
+	}
+	if rec := do("POST", "/admin/reset", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("reset without the admin key: status = %d, want 401", rec.Code)
+	}
+
+	if rec := do("GET", "/admin/metrics", admin); !strings.Contains(rec.Body.String(), "visited 3 times") {
+		t.Errorf("metrics = %d %q, want 3 visits", rec.Code, rec.Body)
+	}
+	if rec := do("POST", "/admin/reset", admin); rec.Body.String() != "3" {
+		t.Errorf("reset = %d %q, want 3", rec.Code, rec.Body)
+	}
+	if rec := do("GET", "/admin/metrics", admin); !strings.Contains(rec.Body.String(), "visited 0 times") {
+		t.Errorf("metrics after reset = %d %q, want 0 visits", rec.Code, rec.Body)
+	}
+
+	if hits := db.analyticsHits["/api/chirps"]; hits != 1 {
+		t.Errorf("reset left %d hits on /api/chirps, want 1", hits)
+	}
+}
+
+func TestFileServerHitPaths(t *testing.T) {
+	db := &fakeDB{}
+	cfg, handler := newTestAPI(t, sql.OpenDB(db))
+
+	for _, path := range []string{
+		"/app/",
+		"/app/index.html",     // redirected to /app/
+		"/app/nope.html",      // not found
+		"/app/a/b/index.html", // redirected to /app/a/b/
+		"/app/assets/logo.png",
+	} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	if err := cfg.analytics.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]int64{"/app/": 4, "/app/assets/logo.png": 1}
+	if !maps.Equal(db.analyticsHits, want) {
+		t.Errorf("hits = %v, want %v", db.analyticsHits, want)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ireoluwa12345/chirpy/internal/analytics"
+	"github.com/ireoluwa12345/chirpy/internal/database"
+	"github.com/ireoluwa12345/chirpy/internal/health"
+)
+
+// analyticsPath is what a request is counted under: the route pattern, or
+// for files the file server served, the file itself. Redirects and misses
+// are counted under the route, so clients can't add a path per request.
+func analyticsPath(r *http.Request, route string, status int) string {
+	if route == fileServerPrefix && status >= 200 && status < 300 {
+		return r.URL.Path
+	}
+	return route
+}
+
+// runAnalyticsRetention rolls old analytics buckets into coarser ones every
+// interval until ctx is cancelled, beating heartbeat after each run.
+func (cfg *apiConfig) runAnalyticsRetention(ctx context.Context, interval time.Duration, retention analytics.Retention, heartbeat *health.Heartbeat) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := cfg.analyticsStore.RollUp(ctx, retention, time.Now()); err != nil {
+			slog.ErrorContext(ctx, "error rolling up analytics", "error", err)
+		}
+		heartbeat.Beat()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// HandleGetAnalytics returns hit counts between ?from= and ?to= (RFC 3339,
+// defaulting to the last 24 hours) in buckets of ?granularity= (minute,
+// hour or day; default hour), optionally for a single ?path=. Buckets older
+// than the retention period only exist at a coarser granularity and are
+// reported at the start of their coarser bucket. Hits from the last flush
+// interval are not included yet.
+func (cfg *apiConfig) HandleGetAnalytics(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	to := time.Now().UTC()
+	if toString := query.Get("to"); toString != "" {
+		var err error
+		to, err = time.Parse(time.RFC3339, toString)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "to must be an RFC 3339 timestamp")
+			return
+		}
+	}
+
+	from := to.Add(-24 * time.Hour)
+	if fromString := query.Get("from"); fromString != "" {
+		var err error
+		from, err = time.Parse(time.RFC3339, fromString)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "from must be an RFC 3339 timestamp")
+			return
+		}
+	}
+
+	if !from.Before(to) {
+		respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "from must be before to")
+		return
+	}
+
+	granularity := analytics.Hour
+	if granularityString := query.Get("granularity"); granularityString != "" {
+		var err error
+		granularity, err = analytics.ParseGranularity(granularityString)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "granularity must be minute, hour or day")
+			return
+		}
+	}
+
+	path := query.Get("path")
+
+	rows, err := cfg.db.GetAnalyticsHits(r.Context(), database.GetAnalyticsHitsParams{
+		Granularity: string(granularity),
+		FromTime:    from,
+		ToTime:      to,
+		Path:        sql.NullString{String: path, Valid: path != ""},
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error fetching analytics", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't get analytics")
+		return
+	}
+
+	type bucket struct {
+		Start time.Time `json:"start"`
+		Path  string    `json:"path"`
+		Hits  int64     `json:"hits"`
+	}
+
+	body := struct {
+		From        time.Time             `json:"from"`
+		To          time.Time             `json:"to"`
+		Granularity analytics.Granularity `json:"granularity"`
+		Buckets     []bucket              `json:"buckets"`
+	}{
+		From:        from,
+		To:          to,
+		Granularity: granularity,
+		Buckets:     make([]bucket, 0, len(rows)),
+	}
+	for _, row := range rows {
+		body.Buckets = append(body.Buckets, bucket{Start: row.Bucket.UTC(), Path: row.Path, Hits: row.Hits})
+	}
+
+	respondJSON(w, http.StatusOK, body)
+}
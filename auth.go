@@ -3,69 +3,89 @@ package main
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
-	"fmt"
+	"log/slog"
 	"net/http"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/ireoluwa12345/chirpy/internal/auth"
+	"github.com/ireoluwa12345/chirpy/internal/database"
 )
 
+// issueTokens creates the access and refresh token pair handed out when a
+// user logs in.
+func (cfg *apiConfig) issueTokens(ctx context.Context, userID uuid.UUID) (string, string, error) {
+	accessToken, err := auth.MakeJWT(userID, cfg.jwtSecret, cfg.accessTokenExpiry)
+	if err != nil {
+		return "", "", err
+	}
+
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	storedRefreshToken, err := cfg.db.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		UserID:    userID,
+		Token:     refreshToken,
+		ExpiresAt: time.Now().Add(cfg.refreshTokenExpiry),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, storedRefreshToken.Token, nil
+}
+
 func (cfg *apiConfig) HandleRefresh(w http.ResponseWriter, r *http.Request) {
 	bearerToken, err := auth.GetBearerToken(r.Header)
 
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte(fmt.Sprintf("error occurred getting bearer token: %v", err)))
+		respondError(w, http.StatusUnauthorized, errCodeUnauthorized, err.Error())
 		return
 	}
 
-	refreshToken, err := cfg.db.CheckRefreshToken(context.Background(), bearerToken)
+	refreshToken, err := cfg.db.CheckRefreshToken(r.Context(), bearerToken)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			w.WriteHeader(http.StatusUnauthorized)
+			respondError(w, http.StatusUnauthorized, errCodeInvalidToken, "refresh token is invalid, expired or revoked")
 			return
 		}
 
-		w.WriteHeader(http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error checking refresh token", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't refresh token")
 		return
 	}
 
-	expiresIn, err := time.ParseDuration(accessTokenExpiry)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-	}
-	accessToken, err := auth.MakeJWT(refreshToken.UserID, cfg.jwtSecret, expiresIn)
+	accessToken, err := auth.MakeJWT(refreshToken.UserID, cfg.jwtSecret, cfg.accessTokenExpiry)
 
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error creating access token", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't refresh token")
 		return
 	}
 
-	resp, _ := json.Marshal(map[string]interface{}{
+	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"token": accessToken,
 	})
-
-	w.WriteHeader(http.StatusOK)
-	w.Write(resp)
 }
 
 func (cfg *apiConfig) HandleRevoke(w http.ResponseWriter, r *http.Request) {
 	bearerToken, err := auth.GetBearerToken(r.Header)
 
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte(fmt.Sprintf("error occurred getting bearer token: %v", err)))
+		respondError(w, http.StatusUnauthorized, errCodeUnauthorized, err.Error())
 		return
 	}
 
-	err = cfg.db.RevokeRefreshToken(context.Background(), bearerToken)
+	err = cfg.db.RevokeRefreshToken(r.Context(), bearerToken)
 
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error revoking refresh token", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't revoke token")
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	respondNoContent(w)
 }
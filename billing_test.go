@@ -0,0 +1,308 @@
+package main
+
+import (
+	"cmp"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ireoluwa12345/chirpy/internal/billing"
+	"github.com/ireoluwa12345/chirpy/internal/billing/billingtest"
+	"github.com/ireoluwa12345/chirpy/internal/database"
+)
+
+// sendPolkaEvent delivers a Polka webhook, with an X-Polka-Event-Id header
+// unless eventID is empty.
+func sendPolkaEvent(handler http.Handler, eventID, event string, userID uuid.UUID) *httptest.ResponseRecorder {
+	body := fmt.Sprintf(`{"event":%q,"data":{"user_id":%q}}`, event, userID)
+	req := httptest.NewRequest("POST", "/api/polka/webhooks", strings.NewReader(body))
+	req.Header.Set("Authorization", "ApiKey polka-key")
+	if eventID != "" {
+		req.Header.Set(billing.PolkaEventIDHeader, eventID)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// newBillingTestDB returns a fake database with one user.
+func newBillingTestDB() (*fakeDB, uuid.UUID) {
+	userID := uuid.New()
+	now := time.Now().UTC()
+	return &fakeDB{users: []database.User{{ID: userID, CreatedAt: now, UpdatedAt: now, Email: "ada@example.com"}}}, userID
+}
+
+func (db *fakeDB) webhookEvent(t *testing.T, eventID string) database.WebhookEvent {
+	t.Helper()
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, event := range db.webhookEvents {
+		if event.EventID == eventID {
+			return event
+		}
+	}
+	t.Fatalf("no webhook event %q was logged", eventID)
+	return database.WebhookEvent{}
+}
+
+func (db *fakeDB) user(t *testing.T, id uuid.UUID) database.User {
+	t.Helper()
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, user := range db.users {
+		if user.ID == id {
+			return user
+		}
+	}
+	t.Fatalf("no user %s", id)
+	return database.User{}
+}
+
+func (db *fakeDB) subscription(t *testing.T, userID uuid.UUID) database.Subscription {
+	t.Helper()
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, sub := range db.subscriptions {
+		if sub.UserID == userID {
+			return sub
+		}
+	}
+	t.Fatalf("user %s has no subscription", userID)
+	return database.Subscription{}
+}
+
+func TestBillingWebhookFakeProvider(t *testing.T) {
+	db, userID := newBillingTestDB()
+	cfg, handler := newTestAPI(t, sql.OpenDB(db))
+	cfg.subscriptionGracePeriod = time.Hour
+	fake := billingtest.NewFake(testBillingSecret)
+	periodEnd := time.Now().Add(30 * 24 * time.Hour).UTC().Truncate(time.Second)
+
+	event := func(id string, eventType billing.EventType) billing.Event {
+		return billing.Event{ID: id, Type: eventType, RawType: string(eventType), UserID: userID}
+	}
+	activated := event("evt_1", billing.SubscriptionActivated)
+	activated.Plan, activated.CurrentPeriodEnd = "chirpy_red_yearly", periodEnd
+	wrongSecret := billingtest.NewFake("not-the-secret")
+
+	// The steps run in order against the same user.
+	tests := []struct {
+		name       string
+		provider   *billingtest.Fake
+		event      billing.Event
+		wantCode   int
+		wantLogged string // status of the logged event, empty if it isn't logged
+		wantSub    string
+		wantRed    bool
+	}{
+		{name: "activated", event: activated, wantCode: 204, wantLogged: webhookEventProcessed, wantSub: subscriptionActive, wantRed: true},
+		{name: "payment failed", event: event("evt_2", billing.PaymentFailed), wantCode: 204, wantLogged: webhookEventProcessed, wantSub: subscriptionPastDue, wantRed: true},
+		{name: "renewed", event: event("evt_3", billing.SubscriptionActivated), wantCode: 204, wantLogged: webhookEventProcessed, wantSub: subscriptionActive, wantRed: true},
+		{name: "duplicate payment failed", event: event("evt_2", billing.PaymentFailed), wantCode: 204, wantLogged: webhookEventProcessed, wantSub: subscriptionActive, wantRed: true},
+		{name: "canceled", event: event("evt_4", billing.SubscriptionCanceled), wantCode: 204, wantLogged: webhookEventProcessed, wantSub: subscriptionCanceled},
+		{name: "reactivated", event: event("evt_5", billing.SubscriptionActivated), wantCode: 204, wantLogged: webhookEventProcessed, wantSub: subscriptionActive, wantRed: true},
+		{name: "refunded", event: event("evt_6", billing.SubscriptionRefunded), wantCode: 204, wantLogged: webhookEventProcessed, wantSub: subscriptionRefunded},
+		{name: "unhandled event", event: billing.Event{ID: "evt_7", RawType: "invoice.created", UserID: userID}, wantCode: 204, wantLogged: webhookEventIgnored, wantSub: subscriptionRefunded},
+		{name: "wrong secret", provider: wrongSecret, event: event("evt_8", billing.SubscriptionActivated), wantCode: 401, wantSub: subscriptionRefunded},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := cmp.Or(tc.provider, fake)
+			req, err := provider.NewRequest("/api/billing/fake/webhooks", tc.event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantCode {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tc.wantCode, rec.Body)
+			}
+			if tc.wantLogged == "" {
+				if slices.ContainsFunc(db.webhookEvents, func(e database.WebhookEvent) bool { return e.EventID == tc.event.ID }) {
+					t.Errorf("event %s was logged", tc.event.ID)
+				}
+			} else if logged := db.webhookEvent(t, tc.event.ID); logged.Provider != "fake" || logged.Status != tc.wantLogged || logged.Attempts != 1 {
+				t.Errorf("logged event = %s from %s after %d attempts, want %s from fake after 1", logged.Status, logged.Provider, logged.Attempts, tc.wantLogged)
+			}
+			sub := db.subscription(t, userID)
+			if sub.Status != tc.wantSub {
+				t.Errorf("subscription status = %s, want %s", sub.Status, tc.wantSub)
+			}
+			if tc.event.Plan != "" && (sub.Plan != tc.event.Plan || !sub.CurrentPeriodEnd.Equal(tc.event.CurrentPeriodEnd)) {
+				t.Errorf("subscription = %s until %s, want %s until %s", sub.Plan, sub.CurrentPeriodEnd, tc.event.Plan, tc.event.CurrentPeriodEnd)
+			}
+			if red := db.user(t, userID).IsChirpyRed; red != tc.wantRed {
+				t.Errorf("is_chirpy_red = %t, want %t", red, tc.wantRed)
+			}
+		})
+	}
+
+	t.Run("unknown provider", func(t *testing.T) {
+		req, err := fake.NewRequest("/api/billing/acme/webhooks", event("evt_9", billing.SubscriptionActivated))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want 404", rec.Code)
+		}
+		assertProblem(t, rec, errCodeNotFound)
+	})
+}
+
+func TestBillingWebhookDeduplication(t *testing.T) {
+	db, userID := newBillingTestDB()
+	_, handler := newTestAPI(t, sql.OpenDB(db))
+
+	for range 3 {
+		if rec := sendPolkaEvent(handler, "evt_1", "user.upgraded", userID); rec.Code != http.StatusNoContent {
+			t.Fatalf("status = %d, want 204; body %s", rec.Code, rec.Body)
+		}
+	}
+
+	event := db.webhookEvent(t, "evt_1")
+	if event.Status != webhookEventProcessed || event.Attempts != 1 {
+		t.Errorf("event status = %s after %d attempts, want processed after 1", event.Status, event.Attempts)
+	}
+	if !db.user(t, userID).IsChirpyRed {
+		t.Error("user isn't Chirpy Red after upgrading")
+	}
+}
+
+func TestBillingWebhookWithoutEventID(t *testing.T) {
+	db, userID := newBillingTestDB()
+	_, handler := newTestAPI(t, sql.OpenDB(db))
+
+	// Polka sends identical payloads for separate upgrades, so none of
+	// these may be dropped as a duplicate.
+	for _, event := range []string{"user.upgraded", "user.downgraded", "user.upgraded"} {
+		if rec := sendPolkaEvent(handler, "", event, userID); rec.Code != http.StatusNoContent {
+			t.Fatalf("%s: status = %d, want 204; body %s", event, rec.Code, rec.Body)
+		}
+	}
+
+	if got := len(db.webhookEvents); got != 3 {
+		t.Errorf("logged %d events, want 3", got)
+	}
+	if !db.user(t, userID).IsChirpyRed {
+		t.Error("user isn't Chirpy Red after upgrading again")
+	}
+}
+
+func TestBillingWebhookConcurrentDuplicates(t *testing.T) {
+	db, userID := newBillingTestDB()
+	_, handler := newTestAPI(t, sql.OpenDB(db))
+
+	var wg sync.WaitGroup
+	codes := make([]int, 10)
+	for i := range codes {
+		wg.Go(func() {
+			codes[i] = sendPolkaEvent(handler, "evt_1", "user.upgraded", userID).Code
+		})
+	}
+	wg.Wait()
+
+	for i, code := range codes {
+		if code != http.StatusNoContent {
+			t.Errorf("delivery %d: status = %d, want 204", i, code)
+		}
+	}
+	if event := db.webhookEvent(t, "evt_1"); event.Attempts != 1 {
+		t.Errorf("event was processed %d times, want once", event.Attempts)
+	}
+}
+
+func TestBillingWebhookFailure(t *testing.T) {
+	db, _ := newBillingTestDB()
+	_, handler := newTestAPI(t, sql.OpenDB(db))
+	unknownUser := uuid.New()
+
+	rec := sendPolkaEvent(handler, "evt_1", "user.upgraded", unknownUser)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404; body %s", rec.Code, rec.Body)
+	}
+	event := db.webhookEvent(t, "evt_1")
+	if event.Status != webhookEventFailed || !event.Error.Valid {
+		t.Fatalf("event = %+v, want failed with an error", event)
+	}
+
+	// Redeliveries of a failed event are processed again.
+	now := time.Now().UTC()
+	db.mu.Lock()
+	db.users = append(db.users, database.User{ID: unknownUser, CreatedAt: now, UpdatedAt: now, Email: "bob@example.com"})
+	db.mu.Unlock()
+
+	if rec := sendPolkaEvent(handler, "evt_1", "user.upgraded", unknownUser); rec.Code != http.StatusNoContent {
+		t.Fatalf("redelivery: status = %d, want 204; body %s", rec.Code, rec.Body)
+	}
+	event = db.webhookEvent(t, "evt_1")
+	if event.Status != webhookEventProcessed || event.Attempts != 2 || event.Error.Valid {
+		t.Errorf("event = %+v, want processed after 2 attempts without an error", event)
+	}
+}
+
+func TestReplayWebhookEvent(t *testing.T) {
+	spec := loadOpenAPI(t)
+	admin := http.Header{"Authorization": {"ApiKey " + testAdminKey}}
+
+	tests := []struct {
+		name       string
+		status     string
+		updatedAgo time.Duration
+		query      string
+		wantCode   int
+		wantStatus string
+	}{
+		{name: "failed", status: webhookEventFailed, wantCode: 200, wantStatus: webhookEventProcessed},
+		{name: "processed", status: webhookEventProcessed, wantCode: 409, wantStatus: webhookEventProcessed},
+		{name: "ignored", status: webhookEventIgnored, wantCode: 409, wantStatus: webhookEventIgnored},
+		{name: "processed with force", status: webhookEventProcessed, query: "?force=true", wantCode: 200, wantStatus: webhookEventProcessed},
+		{name: "processing", status: webhookEventProcessing, query: "?force=true", wantCode: 409, wantStatus: webhookEventProcessing},
+		{name: "stuck processing", status: webhookEventProcessing, updatedAgo: 10 * time.Minute, wantCode: 200, wantStatus: webhookEventProcessed},
+		{name: "bad force", status: webhookEventFailed, query: "?force=maybe", wantCode: 400, wantStatus: webhookEventFailed},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, userID := newBillingTestDB()
+			updated := time.Now().UTC().Add(-tc.updatedAgo)
+			event := database.WebhookEvent{
+				ID:        uuid.New(),
+				CreatedAt: updated,
+				UpdatedAt: updated,
+				Provider:  "polka",
+				EventID:   "evt_1",
+				EventType: "user.upgraded",
+				Payload:   fmt.Appendf(nil, `{"event":"user.upgraded","data":{"user_id":%q}}`, userID),
+				Status:    tc.status,
+				Attempts:  1,
+			}
+			db.webhookEvents = append(db.webhookEvents, event)
+			_, handler := newTestAPI(t, sql.OpenDB(db))
+
+			req := httptest.NewRequest("POST", "/admin/webhooks/events/"+event.ID.String()+"/replay"+tc.query, nil)
+			req.Header = admin.Clone()
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantCode {
+				t.Errorf("status = %d, want %d; body %s", rec.Code, tc.wantCode, rec.Body)
+			}
+			if got := db.webhookEvent(t, "evt_1").Status; got != tc.wantStatus {
+				t.Errorf("event status = %s, want %s", got, tc.wantStatus)
+			}
+			assertMatchesSpec(t, spec, req, rec)
+		})
+	}
+}
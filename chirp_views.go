@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ireoluwa12345/chirpy/internal/database"
+)
+
+// recordViews counts a view of each chirp served to the caller, identified
+// the same way as for rate limiting. Authors viewing their own chirps are
+// not counted.
+func (cfg *apiConfig) recordViews(r *http.Request, chirps ...database.Chirp) {
+	viewer, userID := cfg.rateLimitKey(r)
+
+	for _, chirp := range chirps {
+		if chirp.UserID == userID {
+			continue
+		}
+		cfg.views.Record(chirp.ID, viewer)
+	}
+}
+
+// HandleGetChirpStats shows the author how often their chirp was viewed,
+// in total and per day for the last 30 days.
+func (cfg *apiConfig) HandleGetChirpStats(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeInvalidID, "invalid UUID")
+		return
+	}
+
+	userID := r.Context().Value("user_id").(uuid.UUID)
+
+	chirp, ok := cfg.getOwnChirp(w, r, chirpID, userID, "see stats for")
+	if !ok {
+		return
+	}
+
+	days, err := cfg.db.GetChirpDailyViews(r.Context(), database.GetChirpDailyViewsParams{
+		ChirpID: chirp.ID,
+		Since:   time.Now().UTC().AddDate(0, 0, -29),
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error fetching chirp views", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't get chirp stats")
+		return
+	}
+
+	type dailyViews struct {
+		Day   string `json:"day"`
+		Views int64  `json:"views"`
+	}
+
+	body := struct {
+		ChirpID   uuid.UUID    `json:"chirp_id"`
+		ViewCount int64        `json:"view_count"`
+		Daily     []dailyViews `json:"daily_views"`
+	}{
+		ChirpID: chirp.ID,
+		// Include views recorded since the last flush so authors see
+		// their own activity straight away.
+		ViewCount: chirp.ViewCount + cfg.views.Pending(chirp.ID),
+		Daily:     make([]dailyViews, 0, len(days)),
+	}
+	for _, day := range days {
+		body.Daily = append(body.Daily, dailyViews{Day: day.Day.Format(time.DateOnly), Views: day.Views})
+	}
+
+	respondJSON(w, http.StatusOK, body)
+}
@@ -4,15 +4,20 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
 	"net/http"
-	"sort"
+	"net/url"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"github.com/ireoluwa12345/chirpy/internal/auth"
 	"github.com/ireoluwa12345/chirpy/internal/database"
+	"github.com/ireoluwa12345/chirpy/internal/entitlements"
 )
 
 func validateChirp(w http.ResponseWriter, r *http.Request) {
@@ -23,14 +28,12 @@ func validateChirp(w http.ResponseWriter, r *http.Request) {
 	err := jsonDecoder.Decode(&jsonData)
 
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "Something went wrong"}`))
+		respondError(w, http.StatusBadRequest, errCodeInvalidJSON, "couldn't decode json")
 		return
 	}
 
-	if len(jsonData.Body) > 140 {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "Chirp is too long"}`))
+	if len(jsonData.Body) > entitlements.Free.MaxChirpLength {
+		respondError(w, http.StatusBadRequest, errCodeChirpTooLong, "Chirp is too long")
 		return
 	}
 
@@ -50,198 +53,446 @@ func validateChirp(w http.ResponseWriter, r *http.Request) {
 
 	result := strings.Join(bodyArray, " ")
 
-	resp, _ := json.Marshal(map[string]string{
+	respondJSON(w, http.StatusOK, map[string]string{
 		"cleaned_body": result,
 	})
-
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(resp))
 }
 
 func (cfg *apiConfig) HandleCreateChirp(w http.ResponseWriter, r *http.Request) {
 	decoder := json.NewDecoder(r.Body)
 
 	param := struct {
-		Body string `json:"body"`
+		Body      string   `json:"body"`
+		MediaURLs []string `json:"media_urls"`
 	}{}
 
-	bearerToken, err := auth.GetBearerToken(r.Header)
+	user_id, err := cfg.authenticate(r, auth.ScopeChirpsWrite)
 
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte(fmt.Sprintf("error occurred getting bearer token: %v", err)))
+		writeAuthenticationError(w, err)
 		return
 	}
 
-	user_id, err := auth.ValidateJWT(bearerToken, cfg.jwtSecret)
+	err = decoder.Decode(&param)
 
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte(fmt.Sprintf("error occurred getting validating token: %v", err)))
+		respondError(w, http.StatusBadRequest, errCodeInvalidJSON, "couldn't decode json")
 		return
 	}
 
-	err = decoder.Decode(&param)
+	ent, err := cfg.entitlementsFor(r.Context(), user_id)
 
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "couldn't decode json}`))
+		slog.ErrorContext(r.Context(), "error resolving entitlements", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't create chirp")
 		return
 	}
 
-	chirp, err := cfg.db.CreateChirp(context.Background(), database.CreateChirpParams{
-		ID:     uuid.New(),
-		UserID: user_id,
-		Body:   param.Body,
+	if !checkChirpLimits(w, ent, param.Body, param.MediaURLs) {
+		return
+	}
+
+	if param.MediaURLs == nil {
+		param.MediaURLs = []string{}
+	}
+
+	chirp, err := cfg.db.CreateChirp(r.Context(), database.CreateChirpParams{
+		ID:        uuid.New(),
+		UserID:    user_id,
+		Body:      param.Body,
+		MediaUrls: param.MediaURLs,
 	})
 
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		log.Println("Error creating chirps:", err)
-		w.Write([]byte(`{"error": "couldn't create chirp}`))
+		slog.ErrorContext(r.Context(), "error creating chirps", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't create chirp")
+		return
+	}
+
+	cfg.metrics.ChirpsCreated.Inc()
+	cfg.emitEvent(context.WithoutCancel(r.Context()), eventChirpCreated, user_id, chirp)
+
+	respondJSON(w, http.StatusCreated, chirp)
+}
+
+// checkChirpLimits writes an error response and returns false when a chirp
+// with body and mediaURLs exceeds what ent allows.
+func checkChirpLimits(w http.ResponseWriter, ent entitlements.Entitlements, body string, mediaURLs []string) bool {
+	if utf8.RuneCountInString(body) > ent.MaxChirpLength {
+		respondProblem(w, problem{
+			Status:     http.StatusBadRequest,
+			Code:       errCodeChirpTooLong,
+			Detail:     "Chirp is too long",
+			Extensions: map[string]any{"max_length": ent.MaxChirpLength},
+		})
+		return false
+	}
+
+	if len(mediaURLs) > 0 && ent.MaxMediaAttachments == 0 {
+		respondError(w, http.StatusForbidden, errCodeEntitlementRequired, "media attachments require Chirpy Red")
+		return false
+	}
+
+	if len(mediaURLs) > ent.MaxMediaAttachments {
+		respondProblem(w, problem{
+			Status:     http.StatusBadRequest,
+			Code:       errCodeLimitExceeded,
+			Detail:     "too many media attachments",
+			Extensions: map[string]any{"max_media_attachments": ent.MaxMediaAttachments},
+		})
+		return false
+	}
+
+	for _, mediaURL := range mediaURLs {
+		u, err := url.Parse(mediaURL)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "media URLs must be absolute https URLs")
+			return false
+		}
+	}
+
+	return true
+}
+
+// HandleUpdateChirp edits the body of one of the caller's chirps, which
+// requires the edit entitlement.
+func (cfg *apiConfig) HandleUpdateChirp(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeInvalidID, "invalid UUID")
 		return
 	}
 
-	resp, err := json.Marshal(chirp)
+	userID := r.Context().Value("user_id").(uuid.UUID)
+
+	param := struct {
+		Body string `json:"body"`
+	}{}
+
+	err = json.NewDecoder(r.Body).Decode(&param)
 
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "couldn't marshal json}`))
+		respondError(w, http.StatusBadRequest, errCodeInvalidJSON, "couldn't decode json")
+		return
+	}
+
+	chirp, ok := cfg.getOwnChirp(w, r, chirpID, userID, "edit")
+	if !ok {
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	w.Write([]byte(resp))
+	ent, err := cfg.entitlementsFor(r.Context(), userID)
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error resolving entitlements", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't update chirp")
+		return
+	}
+
+	if !ent.CanEditChirps {
+		respondError(w, http.StatusForbidden, errCodeEntitlementRequired, "editing chirps requires Chirpy Red")
+		return
+	}
+
+	if !checkChirpLimits(w, ent, param.Body, nil) {
+		return
+	}
+
+	chirp, err = cfg.db.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{
+		ID:   chirp.ID,
+		Body: param.Body,
+	})
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error updating chirp", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't update chirp")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, chirp)
 }
-func (cfg *apiConfig) HandleGetChirps(w http.ResponseWriter, r *http.Request) {
-	authorIDString := r.URL.Query().Get("author_id")
-	var authorID uuid.UUID
 
-	if authorIDString != "" {
-		var err error
-		authorID, err = uuid.Parse(authorIDString)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte(`{"error": "invalid author ID"}`))
-			return
+// getOwnChirp fetches a chirp and checks that userID wrote it, writing an
+// error response and returning false otherwise. action names what the
+// caller is trying to do in the 403 message.
+func (cfg *apiConfig) getOwnChirp(w http.ResponseWriter, r *http.Request, chirpID, userID uuid.UUID, action string) (database.Chirp, bool) {
+	chirp, err := cfg.db.GetChirpByID(r.Context(), chirpID)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, errCodeNotFound, "chirp not found")
+			return chirp, false
 		}
+		slog.ErrorContext(r.Context(), "error fetching chirp by ID", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't get chirp")
+		return chirp, false
 	}
 
-	sortString := r.URL.Query().Get("sort")
+	if chirp.UserID != userID {
+		respondError(w, http.StatusForbidden, errCodeForbidden, fmt.Sprintf("you can only %s your own chirps", action))
+		return chirp, false
+	}
+
+	return chirp, true
+}
 
-	chirps, err := cfg.db.GetChirps(context.Background())
+// HandlePinChirp pins one of the caller's chirps to their profile, up to
+// the number of pins they are entitled to.
+func (cfg *apiConfig) HandlePinChirp(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
 
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		log.Println("Error fetching chirps:", err)
-		w.Write([]byte(`{"error": "couldn't get chirps"}`))
+		respondError(w, http.StatusBadRequest, errCodeInvalidID, "invalid UUID")
 		return
 	}
 
-	var filteredChirps []database.Chirp
-	for _, dbChirp := range chirps {
-		if authorID != uuid.Nil && dbChirp.UserID != authorID {
-			continue
-		}
-		filteredChirps = append(filteredChirps, dbChirp)
+	userID := r.Context().Value("user_id").(uuid.UUID)
+
+	if _, ok := cfg.getOwnChirp(w, r, chirpID, userID, "pin"); !ok {
+		return
+	}
+
+	pin := database.PinChirpParams{UserID: userID, ChirpID: chirpID}
+
+	pinned, err := cfg.db.IsChirpPinned(r.Context(), database.IsChirpPinnedParams(pin))
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error checking pinned chirp", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't pin chirp")
+		return
+	}
+
+	if pinned {
+		respondNoContent(w)
+		return
 	}
 
-	if sortString == "asc" {
-		sort.Slice(filteredChirps, func(i, j int) bool {
-			return filteredChirps[i].CreatedAt.Before(filteredChirps[j].CreatedAt)
+	ent, err := cfg.entitlementsFor(r.Context(), userID)
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error resolving entitlements", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't pin chirp")
+		return
+	}
+
+	count, err := cfg.db.CountPinnedChirps(r.Context(), userID)
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error counting pinned chirps", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't pin chirp")
+		return
+	}
+
+	if count >= int64(ent.MaxPinnedChirps) {
+		respondProblem(w, problem{
+			Status:     http.StatusForbidden,
+			Code:       errCodeLimitExceeded,
+			Detail:     "pinned chirp limit reached",
+			Extensions: map[string]any{"max_pinned_chirps": ent.MaxPinnedChirps},
 		})
+		return
 	}
 
-	resp, err := json.Marshal(filteredChirps)
+	err = cfg.db.PinChirp(r.Context(), pin)
+
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(`{"error": "couldn't marshal json"}`))
+		slog.ErrorContext(r.Context(), "error pinning chirp", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't pin chirp")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(resp)
+	respondNoContent(w)
 }
 
-func (cfg *apiConfig) HandleGetChirpByID(w http.ResponseWriter, r *http.Request) {
-	chirpIDString := r.PathValue("chirpID")
-	chirpID, err := uuid.Parse(chirpIDString)
+func (cfg *apiConfig) HandleUnpinChirp(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
 
-	fmt.Println(chirpIDString)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeInvalidID, "invalid UUID")
+		return
+	}
+
+	userID := r.Context().Value("user_id").(uuid.UUID)
+
+	rows, err := cfg.db.UnpinChirp(r.Context(), database.UnpinChirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
 
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "invalid UUID}`))
+		slog.ErrorContext(r.Context(), "error unpinning chirp", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't unpin chirp")
 		return
 	}
 
-	chirp, err := cfg.db.GetChirpByID(context.Background(), chirpID)
+	if rows == 0 {
+		respondError(w, http.StatusNotFound, errCodeNotFound, "chirp is not pinned")
+		return
+	}
+
+	respondNoContent(w)
+}
+
+func (cfg *apiConfig) HandleGetPinnedChirps(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
 
 	if err != nil {
-		if err == sql.ErrNoRows {
-			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte(`{"error": "chirp not found"}`))
+		respondError(w, http.StatusBadRequest, errCodeInvalidID, "invalid UUID")
+		return
+	}
+
+	chirps, err := cfg.db.GetPinnedChirps(r.Context(), userID)
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error fetching pinned chirps", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't get pinned chirps")
+		return
+	}
+
+	if chirps == nil {
+		chirps = []database.Chirp{}
+	}
+
+	cfg.recordViews(r, chirps...)
+
+	respondJSON(w, http.StatusOK, chirps)
+}
+
+func (cfg *apiConfig) HandleGetChirps(w http.ResponseWriter, r *http.Request) {
+	params := database.GetChirpsParams{Ascending: r.URL.Query().Get("sort") == "asc"}
+
+	if authorIDString := r.URL.Query().Get("author_id"); authorIDString != "" {
+		authorID, err := uuid.Parse(authorIDString)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, errCodeInvalidID, "invalid author ID")
 			return
 		}
-		w.WriteHeader(http.StatusBadRequest)
-		log.Println("Error fetching chirp by ID:", err)
-		w.Write([]byte(`{"error": "couldn't get chirp}`))
-		return
+		params.AuthorID = uuid.NullUUID{UUID: authorID, Valid: true}
 	}
 
-	resp, err := json.Marshal(chirp)
+	// after is a keyset cursor: the last chirp of the previous page. Pages
+	// then continue from its position in the sort order, unlike offsets,
+	// which shift as chirps are posted and deleted.
+	if afterString := r.URL.Query().Get("after"); afterString != "" {
+		afterID, err := uuid.Parse(afterString)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, errCodeInvalidID, "invalid after chirp ID")
+			return
+		}
+		after, err := cfg.db.GetChirpByID(r.Context(), afterID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "after chirp not found")
+				return
+			}
+			slog.ErrorContext(r.Context(), "error fetching cursor chirp", "error", err)
+			respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't get chirps")
+			return
+		}
+		params.AfterCreatedAt = sql.NullTime{Time: after.CreatedAt, Valid: true}
+		params.AfterID = uuid.NullUUID{UUID: after.ID, Valid: true}
+	}
 
+	limit, offset, err := pageParams(r)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "couldn't marshal json}`))
+		respondError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
 		return
 	}
+	if limit > 0 {
+		params.RowLimit = sql.NullInt32{Int32: int32(limit), Valid: true}
+	}
+	params.RowOffset = int32(offset)
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(resp))
+	chirps, err := cfg.db.GetChirps(r.Context(), params)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error fetching chirps", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't get chirps")
+		return
+	}
+	if chirps == nil {
+		chirps = []database.Chirp{}
+	}
+
+	cfg.recordViews(r, chirps...)
+
+	respondJSON(w, http.StatusOK, chirps)
 }
 
-func (cfg *apiConfig) HandleDeleteChirps(w http.ResponseWriter, r *http.Request) {
+const maxPageSize = 100
+
+// pageParams reads the optional limit and offset query parameters. A zero
+// limit means the caller asked for everything.
+func pageParams(r *http.Request) (limit, offset int, err error) {
+	if s := r.URL.Query().Get("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+	}
+	if s := r.URL.Query().Get("offset"); s != "" {
+		offset, err = strconv.Atoi(s)
+		if err != nil || offset < 0 || offset > math.MaxInt32 {
+			return 0, 0, errors.New("offset must be a non-negative integer")
+		}
+	}
+	return limit, offset, nil
+}
+
+func (cfg *apiConfig) HandleGetChirpByID(w http.ResponseWriter, r *http.Request) {
 	chirpIDString := r.PathValue("chirpID")
 	chirpID, err := uuid.Parse(chirpIDString)
 
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "invalid UUID"}`))
+		respondError(w, http.StatusBadRequest, errCodeInvalidID, "invalid UUID")
 		return
 	}
 
-	userID := r.Context().Value("user_id").(uuid.UUID)
-
-	chirp, err := cfg.db.GetChirpByID(context.Background(), chirpID)
+	chirp, err := cfg.db.GetChirpByID(r.Context(), chirpID)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte(`{"error": "chirp not found"}`))
+			respondError(w, http.StatusNotFound, errCodeNotFound, "chirp not found")
 			return
 		}
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("Error fetching chirp by ID:", err)
-		w.Write([]byte(`{"error": "couldn't get chirp"}`))
+		slog.ErrorContext(r.Context(), "error fetching chirp by ID", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't get chirp")
 		return
 	}
 
-	if chirp.UserID != userID {
-		w.WriteHeader(http.StatusForbidden)
-		w.Write([]byte(`{"error": "you can only delete your own chirps"}`))
+	cfg.recordViews(r, chirp)
+
+	respondJSON(w, http.StatusOK, chirp)
+}
+
+func (cfg *apiConfig) HandleDeleteChirps(w http.ResponseWriter, r *http.Request) {
+	chirpIDString := r.PathValue("chirpID")
+	chirpID, err := uuid.Parse(chirpIDString)
+
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeInvalidID, "invalid UUID")
+		return
+	}
+
+	userID := r.Context().Value("user_id").(uuid.UUID)
+
+	chirp, ok := cfg.getOwnChirp(w, r, chirpID, userID, "delete")
+	if !ok {
 		return
 	}
 
-	err = cfg.db.DeleteChirp(context.Background(), chirpID)
+	err = cfg.db.DeleteChirp(r.Context(), chirpID)
 
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("Error deleting chirp:", err)
-		w.Write([]byte(`{"error": "couldn't delete chirp"}`))
+		slog.ErrorContext(r.Context(), "error deleting chirp", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't delete chirp")
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	cfg.emitEvent(context.WithoutCancel(r.Context()), eventChirpDeleted, userID, map[string]any{
+		"id":      chirp.ID,
+		"user_id": chirp.UserID,
+	})
+
+	respondNoContent(w)
 }
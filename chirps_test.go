@@ -0,0 +1,79 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ireoluwa12345/chirpy/internal/database"
+)
+
+func TestGetChirpsPaging(t *testing.T) {
+	ada, bob := uuid.New(), uuid.New()
+	t0 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	chirp := func(n int, author uuid.UUID, createdAt time.Time) database.Chirp {
+		id := uuid.MustParse(fmt.Sprintf("00000000-0000-0000-0000-%012d", n))
+		return database.Chirp{ID: id, CreatedAt: createdAt, UpdatedAt: createdAt, UserID: author, Body: fmt.Sprint("chirp ", n)}
+	}
+	// Chirps 2, 3 and 4 were posted at the same time, so only their IDs
+	// order them.
+	chirps := []database.Chirp{
+		chirp(1, ada, t0),
+		chirp(3, ada, t0.Add(time.Second)),
+		chirp(2, ada, t0.Add(time.Second)),
+		chirp(4, bob, t0.Add(time.Second)),
+		chirp(5, bob, t0.Add(2*time.Second)),
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []int
+	}{
+		{name: "newest first", want: []int{5, 4, 3, 2, 1}},
+		{name: "oldest first", query: "&sort=asc", want: []int{1, 2, 3, 4, 5}},
+		{name: "author", query: "&author_id=" + ada.String(), want: []int{3, 2, 1}},
+		{name: "author oldest first", query: "&author_id=" + ada.String() + "&sort=asc", want: []int{1, 2, 3}},
+		{name: "offset", query: "&offset=3", want: []int{2, 1}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, handler := newTestAPI(t, sql.OpenDB(&fakeDB{chirps: slices.Clone(chirps)}))
+
+			// Page two at a time, continuing after the last chirp of each page.
+			var got []int
+			after := ""
+			for page := 0; page < 10; page++ {
+				req := httptest.NewRequest("GET", "/api/chirps?limit=2"+tc.query+after, nil)
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				if rec.Code != http.StatusOK {
+					t.Fatalf("status = %d, want 200; body %s", rec.Code, rec.Body)
+				}
+
+				var chirps []database.Chirp
+				if err := json.NewDecoder(rec.Body).Decode(&chirps); err != nil {
+					t.Fatal(err)
+				}
+				for _, chirp := range chirps {
+					got = append(got, int(chirp.ID[15]))
+				}
+				if len(chirps) < 2 {
+					break
+				}
+				after = "&after=" + chirps[len(chirps)-1].ID.String()
+			}
+
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("chirps = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ireoluwa12345/chirpy/internal/auth"
+	"github.com/ireoluwa12345/chirpy/pkg/chirpyclient"
+)
+
+// TestChirpyClient runs the client SDK against the real server.
+func TestChirpyClient(t *testing.T) {
+	_, handler := newTestAPI(t, sql.OpenDB(&fakeDB{}))
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := chirpyclient.New(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	user, err := client.CreateUser(ctx, "ada@example.com", "correct horse battery")
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if _, err := client.CreateUser(ctx, "bob@example.com", "short"); !errors.Is(err, chirpyclient.ErrPasswordPolicy) {
+		t.Errorf("CreateUser with a weak password: error = %v, want ErrPasswordPolicy", err)
+	}
+
+	if _, err := client.Login(ctx, "ada@example.com", "wrong horse battery"); !errors.Is(err, chirpyclient.ErrInvalidCredentials) {
+		t.Errorf("Login with a wrong password: error = %v, want ErrInvalidCredentials", err)
+	}
+	if _, err := client.CreateChirp(ctx, "hello"); !errors.Is(err, chirpyclient.ErrNoSession) {
+		t.Errorf("CreateChirp before Login: error = %v, want ErrNoSession", err)
+	}
+	loggedIn, err := client.Login(ctx, "ada@example.com", "correct horse battery")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if loggedIn.ID != user.ID {
+		t.Errorf("Login user = %v, want %v", loggedIn.ID, user.ID)
+	}
+
+	t.Run("chirps", func(t *testing.T) {
+		var created []chirpyclient.Chirp
+		for i := range 7 {
+			chirp, err := client.CreateChirp(ctx, fmt.Sprintf("chirp %d", i))
+			if err != nil {
+				t.Fatalf("CreateChirp: %v", err)
+			}
+			created = append(created, chirp)
+		}
+
+		got, err := client.GetChirp(ctx, created[0].ID)
+		if err != nil || got.Body != "chirp 0" || got.UserID != user.ID {
+			t.Errorf("GetChirp = %+v, %v", got, err)
+		}
+		if _, err := client.GetChirp(ctx, uuid.New()); !errors.Is(err, chirpyclient.ErrNotFound) {
+			t.Errorf("GetChirp of a missing chirp: error = %v, want ErrNotFound", err)
+		}
+		if _, err := client.CreateChirp(ctx, strings.Repeat("a", 141)); !errors.Is(err, chirpyclient.ErrChirpTooLong) {
+			t.Errorf("CreateChirp too long: error = %v, want ErrChirpTooLong", err)
+		}
+
+		if err := client.PinChirp(ctx, created[1].ID); err != nil {
+			t.Fatalf("PinChirp: %v", err)
+		}
+		pinned, err := client.PinnedChirps(ctx, user.ID)
+		if err != nil || len(pinned) != 1 || pinned[0].ID != created[1].ID {
+			t.Errorf("PinnedChirps = %v, %v", pinned, err)
+		}
+		if err := client.UnpinChirp(ctx, created[1].ID); err != nil {
+			t.Errorf("UnpinChirp: %v", err)
+		}
+
+		if err := client.DeleteChirp(ctx, created[6].ID); err != nil {
+			t.Fatalf("DeleteChirp: %v", err)
+		}
+	})
+
+	t.Run("list chirps", func(t *testing.T) {
+		var bodies []string
+		for chirp, err := range client.ListChirps(ctx, chirpyclient.ListChirpsOptions{AuthorID: user.ID, Ascending: true, PageSize: 4}) {
+			if err != nil {
+				t.Fatalf("ListChirps: %v", err)
+			}
+			bodies = append(bodies, chirp.Body)
+		}
+		want := "chirp 0,chirp 1,chirp 2,chirp 3,chirp 4,chirp 5"
+		if got := strings.Join(bodies, ","); got != want {
+			t.Errorf("ListChirps = %s, want %s", got, want)
+		}
+
+		pages := 0
+		for page, err := range client.ListChirpPages(ctx, chirpyclient.ListChirpsOptions{PageSize: 2}) {
+			if err != nil {
+				t.Fatalf("ListChirpPages: %v", err)
+			}
+			if len(page) != 2 {
+				t.Errorf("page %d has %d chirps, want 2", pages, len(page))
+			}
+			pages++
+		}
+		if pages != 3 {
+			t.Errorf("ListChirpPages yielded %d pages, want 3", pages)
+		}
+
+		for chirp, err := range client.ListChirps(ctx, chirpyclient.ListChirpsOptions{PageSize: 3}) {
+			if err != nil {
+				t.Fatalf("ListChirps: %v", err)
+			}
+			if chirp.Body != "chirp 5" {
+				t.Errorf("newest chirp = %q, want chirp 5", chirp.Body)
+			}
+			break
+		}
+	})
+
+	t.Run("refreshes an expired access token", func(t *testing.T) {
+		_, refreshToken := client.Tokens()
+		expired, err := auth.MakeJWT(user.ID, testJWTSecret, -time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client.SetTokens(expired, refreshToken)
+
+		if _, err := client.UpdateUser(ctx, "ada@example.org", "correct horse battery"); err != nil {
+			t.Fatalf("UpdateUser with an expired access token: %v", err)
+		}
+		if access, _ := client.Tokens(); access == expired {
+			t.Error("access token wasn't refreshed")
+		}
+	})
+
+	t.Run("revoke", func(t *testing.T) {
+		_, refreshToken := client.Tokens()
+		if err := client.Revoke(ctx); err != nil {
+			t.Fatalf("Revoke: %v", err)
+		}
+		if access, refresh := client.Tokens(); access != "" || refresh != "" {
+			t.Error("Revoke kept the session's tokens")
+		}
+
+		client.SetTokens("", refreshToken)
+		if _, err := client.Refresh(ctx); !errors.Is(err, chirpyclient.ErrInvalidToken) {
+			t.Errorf("Refresh after Revoke: error = %v, want ErrInvalidToken", err)
+		}
+	})
+}
@@ -0,0 +1,610 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ireoluwa12345/chirpy/internal/auth"
+	"github.com/ireoluwa12345/chirpy/internal/config"
+	"github.com/ireoluwa12345/chirpy/internal/database"
+	"github.com/ireoluwa12345/chirpy/internal/entitlements"
+	"github.com/ireoluwa12345/chirpy/internal/metrics"
+	"github.com/ireoluwa12345/chirpy/internal/migrate"
+)
+
+const commandUsage = `Usage: chirpy <command> [flags]
+
+Commands:
+  serve                        run the API server (the default)
+  migrate up|down|redo|status  manage the database schema
+  user create                  create a user
+  user promote                 grant a user Chirpy Red
+  user disable                 stop a user logging in or using the API, and revoke their refresh tokens
+  user enable                  let a disabled user log in again
+  user reset-password          set a user's password and revoke their refresh tokens
+  token revoke-all             revoke every refresh token of a user
+  chirps purge                 delete every chirp of a user
+  webhooks replay              process failed billing webhooks again
+
+Every command but serve and migrate accepts -json to print JSON, and
+-config or -db-url to pick the database. Run "chirpy <command> -h" for its
+flags.
+`
+
+// openDB opens the commands' database. Tests replace it.
+var openDB = func(dbURL string) (*sql.DB, error) {
+	return sql.Open("postgres", dbURL)
+}
+
+// errUsage is returned after a command's usage has been printed for a
+// mistake in its arguments.
+var errUsage = errors.New("invalid arguments")
+
+// runCommand runs the command named by args[0], other than serve.
+func runCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	switch args[0] {
+	case "help":
+		fmt.Fprint(stdout, commandUsage)
+		return nil
+	case "migrate":
+		return runMigrate(args[1:], stdout)
+	}
+
+	if len(args) < 2 {
+		fmt.Fprint(os.Stderr, commandUsage)
+		return errUsage
+	}
+
+	commands := map[string]func(c *command) error{
+		"user create":         (*command).userCreate,
+		"user promote":        (*command).userPromote,
+		"user disable":        (*command).userDisable,
+		"user enable":         (*command).userEnable,
+		"user reset-password": (*command).userResetPassword,
+		"token revoke-all":    (*command).tokenRevokeAll,
+		"chirps purge":        (*command).chirpsPurge,
+		"webhooks replay":     (*command).webhooksReplay,
+	}
+	name := args[0] + " " + args[1]
+	run, ok := commands[name]
+	if !ok {
+		fmt.Fprint(os.Stderr, commandUsage)
+		return fmt.Errorf("unknown command %q", name)
+	}
+
+	c := &command{
+		args:   args[2:],
+		flags:  flag.NewFlagSet("chirpy "+name, flag.ContinueOnError),
+		stdin:  stdin,
+		stdout: stdout,
+	}
+	c.flags.StringVar(&c.configFile, "config", "", "JSON config file (env CONFIG_FILE)")
+	c.flags.StringVar(&c.dbURL, "db-url", "", "Postgres connection URL (env DB_URL)")
+	c.flags.BoolVar(&c.json, "json", false, "print JSON instead of text")
+	if err := run(c); !errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	return nil
+}
+
+// command is an admin command run from the CLI against the database,
+// through the same queries and helpers as the API.
+type command struct {
+	args   []string
+	flags  *flag.FlagSet
+	stdin  io.Reader
+	stdout io.Writer
+
+	configFile string
+	dbURL      string
+	json       bool
+
+	cfg *apiConfig
+}
+
+// parse parses the command's flags, which must have been defined, and
+// connects to the database.
+func (c *command) parse() error {
+	if err := c.flags.Parse(c.args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	if c.flags.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %q", c.flags.Arg(0))
+	}
+	return c.connect()
+}
+
+func (c *command) connect() error {
+	var configArgs []string
+	if c.configFile != "" {
+		configArgs = append(configArgs, "-config", c.configFile)
+	}
+	if c.dbURL != "" {
+		configArgs = append(configArgs, "-db-url", c.dbURL)
+	}
+	conf, err := config.Parse(configArgs, os.Getenv)
+	if err != nil {
+		return err
+	}
+	if conf.DBURL == "" {
+		return errors.New("db_url is required")
+	}
+
+	passwordPolicy, err := newPasswordPolicy(conf)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(conf.DBURL)
+	if err != nil {
+		return err
+	}
+
+	migrator, err := migrate.New(db, migrations())
+	if err != nil {
+		return err
+	}
+	if err := migrator.Check(context.Background()); err != nil {
+		return err
+	}
+
+	// The API's own configuration, with only what these commands use.
+	c.cfg = &apiConfig{
+		db:       database.New(db),
+		dbPool:   db,
+		migrator: migrator,
+
+		passwordPolicy: passwordPolicy,
+
+		billingProviders:        newBillingProviders(conf),
+		subscriptionGracePeriod: conf.SubscriptionGracePeriod,
+
+		metrics: metrics.New(),
+	}
+	return nil
+}
+
+func (c *command) close() {
+	if c.cfg != nil {
+		c.cfg.dbPool.Close()
+	}
+}
+
+// print writes v as JSON with -json, or as text written by text otherwise.
+// Text is written through a tabwriter, so columns are separated by tabs.
+func (c *command) print(v any, text func(w io.Writer)) error {
+	if c.json {
+		enc := json.NewEncoder(c.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	text(tw)
+	return tw.Flush()
+}
+
+// findUser looks a user up by ID or email.
+func (c *command) findUser(ctx context.Context, idOrEmail string) (database.User, error) {
+	if idOrEmail == "" {
+		return database.User{}, errors.New("-user is required")
+	}
+
+	var user database.User
+	var err error
+	if id, parseErr := uuid.Parse(idOrEmail); parseErr == nil {
+		user, err = c.cfg.db.GetUserByID(ctx, id)
+	} else {
+		user, err = c.cfg.db.GetUserByEmail(ctx, idOrEmail)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, fmt.Errorf("user %s not found", idOrEmail)
+	}
+	return user, err
+}
+
+// readPassword returns password, or the first line of stdin when it is
+// empty, so passwords needn't appear in shell history.
+func (c *command) readPassword(password string) (string, error) {
+	if password != "" {
+		return password, nil
+	}
+
+	line, err := bufio.NewReader(c.stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	password = strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("pass -password or write the password to stdin")
+	}
+	return password, nil
+}
+
+type userRecord struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Email       string     `json:"email"`
+	IsChirpyRed bool       `json:"is_chirpy_red"`
+	DisabledAt  *time.Time `json:"disabled_at"`
+	// RevokedTokens is set by commands that revoke the user's tokens.
+	RevokedTokens *int64 `json:"revoked_refresh_tokens,omitempty"`
+}
+
+func newUserRecord(user database.User) userRecord {
+	record := userRecord{
+		ID:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+		Email:       user.Email,
+		IsChirpyRed: user.IsChirpyRed,
+	}
+	if user.DisabledAt.Valid {
+		record.DisabledAt = &user.DisabledAt.Time
+	}
+	return record
+}
+
+func (c *command) printUser(user database.User, revokedTokens *int64) error {
+	record := newUserRecord(user)
+	record.RevokedTokens = revokedTokens
+	return c.print(record, func(w io.Writer) {
+		disabled := "no"
+		if record.DisabledAt != nil {
+			disabled = record.DisabledAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "ID:\t%s\n", record.ID)
+		fmt.Fprintf(w, "Email:\t%s\n", record.Email)
+		fmt.Fprintf(w, "Created:\t%s\n", record.CreatedAt.Format(time.RFC3339))
+		fmt.Fprintf(w, "Chirpy Red:\t%t\n", record.IsChirpyRed)
+		fmt.Fprintf(w, "Disabled:\t%s\n", disabled)
+		if record.RevokedTokens != nil {
+			fmt.Fprintf(w, "Refresh tokens revoked:\t%d\n", *record.RevokedTokens)
+		}
+	})
+}
+
+func (c *command) userCreate() error {
+	email := c.flags.String("email", "", "email of the new user")
+	password := c.flags.String("password", "", "password of the new user; read from stdin if unset")
+	if err := c.parse(); err != nil {
+		return err
+	}
+	defer c.close()
+
+	if *email == "" {
+		return errors.New("-email is required")
+	}
+	plain, err := c.readPassword(*password)
+	if err != nil {
+		return err
+	}
+	if err := c.cfg.passwordPolicy.Validate(*email, plain); err != nil {
+		return err
+	}
+	hashedPassword, err := auth.HashPassword(plain)
+	if err != nil {
+		return err
+	}
+
+	user, err := c.cfg.db.CreateUser(context.Background(), database.CreateUserParams{
+		ID:       uuid.New(),
+		Email:    *email,
+		Password: hashedPassword,
+	})
+	if err != nil {
+		return err
+	}
+	return c.printUser(user, nil)
+}
+
+func (c *command) userPromote() error {
+	userFlag := c.flags.String("user", "", "ID or email of the user")
+	reason := c.flags.String("reason", "granted by an administrator", "why the user was promoted")
+	duration := c.flags.Duration("for", 0, "how long the grant lasts; forever if unset")
+	if err := c.parse(); err != nil {
+		return err
+	}
+	defer c.close()
+
+	ctx := context.Background()
+	user, err := c.findUser(ctx, *userFlag)
+	if err != nil {
+		return err
+	}
+
+	var expiresAt sql.NullTime
+	if *duration > 0 {
+		expiresAt = sql.NullTime{Time: time.Now().Add(*duration), Valid: true}
+	}
+	grant, err := c.cfg.db.CreateEntitlementGrant(ctx, database.CreateEntitlementGrantParams{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Feature:   string(entitlements.ChirpyRed),
+		Reason:    *reason,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	record := newEntitlementGrantResponse(grant)
+	return c.print(record, func(w io.Writer) {
+		expires := "never"
+		if record.ExpiresAt != nil {
+			expires = record.ExpiresAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "Grant:\t%s\n", record.ID)
+		fmt.Fprintf(w, "User:\t%s (%s)\n", user.Email, user.ID)
+		fmt.Fprintf(w, "Feature:\t%s\n", record.Feature)
+		fmt.Fprintf(w, "Reason:\t%s\n", record.Reason)
+		fmt.Fprintf(w, "Expires:\t%s\n", expires)
+	})
+}
+
+func (c *command) userDisable() error {
+	userFlag := c.flags.String("user", "", "ID or email of the user")
+	if err := c.parse(); err != nil {
+		return err
+	}
+	defer c.close()
+
+	ctx := context.Background()
+	user, err := c.findUser(ctx, *userFlag)
+	if err != nil {
+		return err
+	}
+
+	user, err = c.cfg.db.DisableUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	revoked, err := c.cfg.revokeAllTokens(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	// The user's access tokens are refused from now on too; authorize
+	// checks disabled_at on every request.
+	return c.printUser(user, &revoked)
+}
+
+func (c *command) userEnable() error {
+	userFlag := c.flags.String("user", "", "ID or email of the user")
+	if err := c.parse(); err != nil {
+		return err
+	}
+	defer c.close()
+
+	ctx := context.Background()
+	user, err := c.findUser(ctx, *userFlag)
+	if err != nil {
+		return err
+	}
+
+	user, err = c.cfg.db.EnableUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	return c.printUser(user, nil)
+}
+
+func (c *command) userResetPassword() error {
+	userFlag := c.flags.String("user", "", "ID or email of the user")
+	password := c.flags.String("password", "", "new password; read from stdin if unset")
+	if err := c.parse(); err != nil {
+		return err
+	}
+	defer c.close()
+
+	ctx := context.Background()
+	user, err := c.findUser(ctx, *userFlag)
+	if err != nil {
+		return err
+	}
+
+	plain, err := c.readPassword(*password)
+	if err != nil {
+		return err
+	}
+	if err := c.cfg.passwordPolicy.Validate(user.Email, plain); err != nil {
+		return err
+	}
+	hashedPassword, err := auth.HashPassword(plain)
+	if err != nil {
+		return err
+	}
+
+	err = c.cfg.db.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+		ID:       user.ID,
+		Password: hashedPassword,
+	})
+	if err != nil {
+		return err
+	}
+	revoked, err := c.cfg.revokeAllTokens(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	return c.printUser(user, &revoked)
+}
+
+func (c *command) tokenRevokeAll() error {
+	userFlag := c.flags.String("user", "", "ID or email of the user")
+	if err := c.parse(); err != nil {
+		return err
+	}
+	defer c.close()
+
+	ctx := context.Background()
+	user, err := c.findUser(ctx, *userFlag)
+	if err != nil {
+		return err
+	}
+
+	revoked, err := c.cfg.revokeAllTokens(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	result := struct {
+		UserID  uuid.UUID `json:"user_id"`
+		Revoked int64     `json:"revoked"`
+	}{user.ID, revoked}
+	return c.print(result, func(w io.Writer) {
+		fmt.Fprintf(w, "Revoked %d refresh tokens of %s.\n", revoked, user.Email)
+	})
+}
+
+// revokeAllTokens revokes the user's refresh tokens, both their own and
+// those issued to OAuth clients, and returns how many were revoked.
+func (cfg *apiConfig) revokeAllTokens(ctx context.Context, userID uuid.UUID) (int64, error) {
+	own, err := cfg.db.RevokeRefreshTokensForUser(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	oauth, err := cfg.db.RevokeOAuthRefreshTokensForUser(ctx, userID)
+	if err != nil {
+		return own, err
+	}
+	return own + oauth, nil
+}
+
+func (c *command) chirpsPurge() error {
+	userFlag := c.flags.String("user", "", "ID or email of the user")
+	if err := c.parse(); err != nil {
+		return err
+	}
+	defer c.close()
+
+	ctx := context.Background()
+	user, err := c.findUser(ctx, *userFlag)
+	if err != nil {
+		return err
+	}
+
+	chirpIDs, err := c.cfg.db.DeleteChirpsByUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	for _, chirpID := range chirpIDs {
+		c.cfg.emitEvent(ctx, eventChirpDeleted, user.ID, map[string]any{
+			"id":      chirpID,
+			"user_id": user.ID,
+		})
+	}
+
+	result := struct {
+		UserID  uuid.UUID   `json:"user_id"`
+		Deleted []uuid.UUID `json:"deleted"`
+	}{user.ID, chirpIDs}
+	if result.Deleted == nil {
+		result.Deleted = []uuid.UUID{}
+	}
+	return c.print(result, func(w io.Writer) {
+		fmt.Fprintf(w, "Deleted %d chirps of %s.\n", len(chirpIDs), user.Email)
+	})
+}
+
+func (c *command) webhooksReplay() error {
+	failed := c.flags.Bool("failed", false, "replay every failed event")
+	limit := c.flags.Int("limit", 100, "most failed events to replay with -failed")
+	force := c.flags.Bool("force", false, "also replay events that were already processed or ignored, applying them again")
+	// Event IDs follow the flags.
+	if err := c.flags.Parse(c.args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	eventIDs := c.flags.Args()
+	if *failed == (len(eventIDs) > 0) {
+		return errors.New("pass either -failed or event IDs")
+	}
+	if err := c.connect(); err != nil {
+		return err
+	}
+	defer c.close()
+
+	ctx := context.Background()
+	var events []database.WebhookEvent
+	if *failed {
+		var err error
+		events, err = c.cfg.db.ListWebhookEvents(ctx, database.ListWebhookEventsParams{
+			Status:     sql.NullString{String: webhookEventFailed, Valid: true},
+			MaxResults: int32(*limit),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, idString := range eventIDs {
+		id, err := uuid.Parse(idString)
+		if err != nil {
+			return fmt.Errorf("invalid event ID %q", idString)
+		}
+		event, err := c.cfg.db.GetWebhookEventByID(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("webhook event %s not found", id)
+		}
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+	}
+
+	results := make([]webhookEventResponse, 0, len(events))
+	failures, skipped := 0, 0
+	for _, event := range events {
+		claimed, err := c.cfg.claimWebhookEvent(ctx, event.ID, replayStatuses(*force)...)
+		if err == errWebhookEventClaimed {
+			skipped++
+			results = append(results, newWebhookEventResponse(event))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		event, err = c.cfg.processWebhookEvent(ctx, claimed)
+		if err != nil {
+			failures++
+		}
+		results = append(results, newWebhookEventResponse(event))
+	}
+
+	err := c.print(results, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tPROVIDER\tEVENT\tSTATUS\tATTEMPTS\tERROR")
+		for _, event := range results {
+			errorMessage := ""
+			if event.Error != nil {
+				errorMessage = *event.Error
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", event.ID, event.Provider, event.EventType, event.Status, event.Attempts, errorMessage)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if skipped > 0 {
+		return fmt.Errorf("skipped %d of %d events: only failed events are replayed without -force, and events being processed never are", skipped, len(events))
+	}
+	if failures > 0 {
+		return fmt.Errorf("%d of %d events failed again", failures, len(events))
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ireoluwa12345/chirpy/internal/auth"
+	"github.com/ireoluwa12345/chirpy/internal/database"
+)
+
+// runTestCommand runs a CLI command against db and returns its output.
+func runTestCommand(t *testing.T, db *fakeDB, stdin string, args ...string) (string, error) {
+	t.Helper()
+
+	open := openDB
+	openDB = func(string) (*sql.DB, error) { return sql.OpenDB(db), nil }
+	t.Cleanup(func() { openDB = open })
+
+	// -db-url goes before the command's own arguments, since flags stop at
+	// the first positional argument.
+	if len(args) >= 2 {
+		args = append(args[:2:2], append([]string{"-db-url", "postgres://fake"}, args[2:]...)...)
+	}
+	var stdout bytes.Buffer
+	err := runCommand(args, strings.NewReader(stdin), &stdout)
+	return stdout.String(), err
+}
+
+// newCLITestDB returns a fake database with a user who has two refresh
+// tokens of their own and one issued to an OAuth client.
+func newCLITestDB(t *testing.T) (*fakeDB, database.User) {
+	t.Helper()
+
+	hash, err := auth.HashPassword("correct horse battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	user := database.User{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Email: "ada@example.com", Password: hash}
+	expires := now.Add(time.Hour)
+	return &fakeDB{
+		users: []database.User{user},
+		refreshTokens: []database.RefreshToken{
+			{Token: "own-1", UserID: user.ID, ExpiresAt: expires},
+			{Token: "own-2", UserID: user.ID, ExpiresAt: expires},
+			{Token: "other", UserID: uuid.New(), ExpiresAt: expires},
+		},
+		oauthTokens: []database.OauthRefreshToken{
+			{Token: "oauth-1", ClientID: "client", UserID: user.ID, ExpiresAt: expires},
+		},
+	}, user
+}
+
+// assertTokensRevoked checks every token of userID in both token tables is
+// revoked and no one else's is.
+func assertTokensRevoked(t *testing.T, db *fakeDB, userID uuid.UUID) {
+	t.Helper()
+
+	for _, token := range db.refreshTokens {
+		if token.Revoked.Valid != (token.UserID == userID) {
+			t.Errorf("refresh token %s revoked = %t", token.Token, token.Revoked.Valid)
+		}
+	}
+	for _, token := range db.oauthTokens {
+		if !token.RevokedAt.Valid {
+			t.Errorf("OAuth refresh token %s isn't revoked", token.Token)
+		}
+	}
+}
+
+func TestRunCommandArguments(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "unknown command", args: []string{"user", "frobnicate"}, wantErr: `unknown command "user frobnicate"`},
+		{name: "unknown flag", args: []string{"user", "disable", "-nope"}, wantErr: errUsage.Error()},
+		{name: "missing user", args: []string{"user", "disable"}, wantErr: "-user is required"},
+		{name: "unknown user", args: []string{"user", "disable", "-user", "bob@example.com"}, wantErr: "user bob@example.com not found"},
+		{name: "extra argument", args: []string{"user", "enable", "-user", "ada@example.com", "extra"}, wantErr: `unexpected argument "extra"`},
+		{name: "no password", args: []string{"user", "reset-password", "-user", "ada@example.com"}, wantErr: "pass -password or write the password to stdin"},
+		{name: "weak password", args: []string{"user", "reset-password", "-user", "ada@example.com", "-password", "short"}, wantErr: "password"},
+		{name: "replay without events", args: []string{"webhooks", "replay"}, wantErr: "pass either -failed or event IDs"},
+		{name: "replay failed and events", args: []string{"webhooks", "replay", "-failed", uuid.NewString()}, wantErr: "pass either -failed or event IDs"},
+		{name: "replay invalid event ID", args: []string{"webhooks", "replay", "nope"}, wantErr: `invalid event ID "nope"`},
+		{name: "replay unknown event", args: []string{"webhooks", "replay", uuid.Nil.String()}, wantErr: "not found"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, _ := newCLITestDB(t)
+			_, err := runTestCommand(t, db, "", tc.args...)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("error = %v, want one containing %q", err, tc.wantErr)
+			}
+		})
+	}
+
+	t.Run("help", func(t *testing.T) {
+		var stdout bytes.Buffer
+		if err := runCommand([]string{"help"}, nil, &stdout); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(stdout.String(), "webhooks replay") {
+			t.Errorf("help = %q, want the command list", stdout.String())
+		}
+	})
+}
+
+func TestUserDisable(t *testing.T) {
+	db, user := newCLITestDB(t)
+
+	out, err := runTestCommand(t, db, "", "user", "disable", "-json", "-user", user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var record userRecord
+	if err := json.Unmarshal([]byte(out), &record); err != nil {
+		t.Fatalf("output %q isn't JSON: %v", out, err)
+	}
+	if record.ID != user.ID || record.DisabledAt == nil {
+		t.Errorf("record = %+v, want user %s disabled", record, user.ID)
+	}
+	if record.RevokedTokens == nil || *record.RevokedTokens != 3 {
+		t.Errorf("revoked_refresh_tokens = %v, want 3", record.RevokedTokens)
+	}
+	assertTokensRevoked(t, db, user.ID)
+
+	out, err = runTestCommand(t, db, "", "user", "enable", "-user", user.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "Disabled:") || !strings.HasSuffix(strings.TrimSpace(out), "no") || db.users[0].DisabledAt.Valid {
+		t.Errorf("output = %q, want the user enabled", out)
+	}
+}
+
+func TestUserResetPassword(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		stdin string
+	}{
+		{name: "flag", args: []string{"-password", "a new horse battery"}},
+		{name: "stdin", stdin: "a new horse battery\n"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, user := newCLITestDB(t)
+
+			args := append([]string{"user", "reset-password", "-user", user.Email}, tc.args...)
+			out, err := runTestCommand(t, db, tc.stdin, args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !strings.Contains(out, "Refresh tokens revoked:  3") {
+				t.Errorf("output = %q, want 3 revoked tokens", out)
+			}
+			if ok, err := auth.VerifyPassword("a new horse battery", db.users[0].Password); err != nil || !ok {
+				t.Errorf("new password doesn't verify: %v", err)
+			}
+			assertTokensRevoked(t, db, user.ID)
+		})
+	}
+}
+
+func TestWebhooksReplay(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     string
+		args       []string
+		wantErr    bool
+		wantStatus string
+	}{
+		{name: "failed", status: webhookEventFailed, wantStatus: webhookEventProcessed},
+		{name: "failed with -failed", status: webhookEventFailed, args: []string{"-failed"}, wantStatus: webhookEventProcessed},
+		{name: "processed", status: webhookEventProcessed, wantErr: true, wantStatus: webhookEventProcessed},
+		{name: "processed with -force", status: webhookEventProcessed, args: []string{"-force"}, wantStatus: webhookEventProcessed},
+		{name: "processing with -force", status: webhookEventProcessing, args: []string{"-force"}, wantErr: true, wantStatus: webhookEventProcessing},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, user := newCLITestDB(t)
+			now := time.Now().UTC()
+			event := database.WebhookEvent{
+				ID:        uuid.New(),
+				CreatedAt: now,
+				UpdatedAt: now,
+				Provider:  "polka",
+				EventID:   "evt_1",
+				EventType: "user.upgraded",
+				Payload:   []byte(`{"event":"user.upgraded","data":{"user_id":"` + user.ID.String() + `"}}`),
+				Status:    tc.status,
+				Attempts:  1,
+			}
+			db.webhookEvents = append(db.webhookEvents, event)
+
+			args := append([]string{"webhooks", "replay", "-json"}, tc.args...)
+			if !strings.Contains(strings.Join(tc.args, " "), "-failed") {
+				args = append(args, event.ID.String())
+			}
+			out, err := runTestCommand(t, db, "", args...)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("error = %v, wantErr %t", err, tc.wantErr)
+			}
+
+			var results []webhookEventResponse
+			if err := json.Unmarshal([]byte(out), &results); err != nil {
+				t.Fatalf("output %q isn't JSON: %v", out, err)
+			}
+			if len(results) != 1 || results[0].Status != tc.wantStatus {
+				t.Errorf("results = %+v, want one %s event", results, tc.wantStatus)
+			}
+			logged := db.webhookEvent(t, "evt_1")
+			if logged.Status != tc.wantStatus {
+				t.Errorf("event status = %s, want %s", logged.Status, tc.wantStatus)
+			}
+			if replayed := logged.Attempts > 1; replayed == tc.wantErr {
+				t.Errorf("event attempts = %d, want it replayed only without an error", logged.Attempts)
+			}
+		})
+	}
+}
+
+func TestUserCommandsDatabaseErrors(t *testing.T) {
+	open := openDB
+	openDB = func(string) (*sql.DB, error) { return sql.OpenDB(stubConnector{err: errDatabaseDown}), nil }
+	t.Cleanup(func() { openDB = open })
+
+	err := runCommand([]string{"user", "disable", "-user", "ada@example.com", "-db-url", "postgres://fake"}, nil, &bytes.Buffer{})
+	if !errors.Is(err, errDatabaseDown) {
+		t.Errorf("error = %v, want %v", err, errDatabaseDown)
+	}
+}
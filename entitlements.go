@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ireoluwa12345/chirpy/internal/database"
+	"github.com/ireoluwa12345/chirpy/internal/entitlements"
+)
+
+// entitlementsFor is the single place handlers ask what a user may do. It
+// combines the user's Chirpy Red status with any features granted by hand.
+// Within a request the result is remembered, so the nested rate limits and
+// the handler look a user up only once.
+func (cfg *apiConfig) entitlementsFor(ctx context.Context, userID uuid.UUID) (entitlements.Entitlements, error) {
+	cache, _ := ctx.Value("entitlements").(map[uuid.UUID]entitlements.Entitlements)
+	if ent, ok := cache[userID]; ok {
+		return ent, nil
+	}
+
+	user, err := cfg.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return entitlements.Free, err
+	}
+
+	grants, err := cfg.db.ListActiveEntitlementFeatures(ctx, userID)
+	if err != nil {
+		return entitlements.Free, err
+	}
+
+	features := make([]entitlements.Feature, 0, len(grants))
+	for _, grant := range grants {
+		features = append(features, entitlements.Feature(grant))
+	}
+
+	ent := entitlements.Resolve(user.IsChirpyRed, features)
+	if cache != nil {
+		cache[userID] = ent
+	}
+	return ent, nil
+}
+
+type entitlementGrantResponse struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    uuid.UUID  `json:"user_id"`
+	Feature   string     `json:"feature"`
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+func newEntitlementGrantResponse(grant database.EntitlementGrant) entitlementGrantResponse {
+	resp := entitlementGrantResponse{
+		ID:        grant.ID,
+		CreatedAt: grant.CreatedAt,
+		UserID:    grant.UserID,
+		Feature:   grant.Feature,
+		Reason:    grant.Reason,
+	}
+	if grant.ExpiresAt.Valid {
+		resp.ExpiresAt = &grant.ExpiresAt.Time
+	}
+	if grant.RevokedAt.Valid {
+		resp.RevokedAt = &grant.RevokedAt.Time
+	}
+	return resp
+}
+
+// HandleGrantEntitlement lets support grant a feature to a user by hand,
+// optionally until expires_at.
+func (cfg *apiConfig) HandleGrantEntitlement(w http.ResponseWriter, r *http.Request) {
+	param := struct {
+		UserID    uuid.UUID  `json:"user_id"`
+		Feature   string     `json:"feature"`
+		Reason    string     `json:"reason"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}{}
+
+	err := json.NewDecoder(r.Body).Decode(&param)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeInvalidJSON, "couldn't decode json")
+		return
+	}
+
+	if !entitlements.Valid(entitlements.Feature(param.Feature)) {
+		respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "unknown feature")
+		return
+	}
+
+	if param.Reason == "" {
+		respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "a reason is required")
+		return
+	}
+
+	expiresAt := sql.NullTime{}
+	if param.ExpiresAt != nil {
+		if !param.ExpiresAt.After(time.Now()) {
+			respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "expires_at must be in the future")
+			return
+		}
+		expiresAt = sql.NullTime{Time: *param.ExpiresAt, Valid: true}
+	}
+
+	_, err = cfg.db.GetUserByID(r.Context(), param.UserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, errCodeNotFound, "user not found")
+			return
+		}
+		slog.ErrorContext(r.Context(), "error fetching user", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't get user")
+		return
+	}
+
+	grant, err := cfg.db.CreateEntitlementGrant(r.Context(), database.CreateEntitlementGrantParams{
+		ID:        uuid.New(),
+		UserID:    param.UserID,
+		Feature:   param.Feature,
+		Reason:    param.Reason,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error granting entitlement", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't grant entitlement")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, newEntitlementGrantResponse(grant))
+}
+
+// HandleGetUserEntitlements shows a user's effective entitlements together
+// with every grant ever made to them.
+func (cfg *apiConfig) HandleGetUserEntitlements(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeInvalidID, "invalid UUID")
+		return
+	}
+
+	ent, err := cfg.entitlementsFor(r.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, errCodeNotFound, "user not found")
+			return
+		}
+		slog.ErrorContext(r.Context(), "error resolving entitlements", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't get entitlements")
+		return
+	}
+
+	grants, err := cfg.db.ListEntitlementGrants(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error listing entitlement grants", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't get entitlements")
+		return
+	}
+
+	body := struct {
+		UserID       uuid.UUID                  `json:"user_id"`
+		Entitlements entitlements.Entitlements  `json:"entitlements"`
+		Grants       []entitlementGrantResponse `json:"grants"`
+	}{
+		UserID:       userID,
+		Entitlements: ent,
+		Grants:       make([]entitlementGrantResponse, 0, len(grants)),
+	}
+	for _, grant := range grants {
+		body.Grants = append(body.Grants, newEntitlementGrantResponse(grant))
+	}
+
+	respondJSON(w, http.StatusOK, body)
+}
+
+// HandleRevokeEntitlement ends a manual grant. The grant is kept for the
+// audit trail.
+func (cfg *apiConfig) HandleRevokeEntitlement(w http.ResponseWriter, r *http.Request) {
+	grantID, err := uuid.Parse(r.PathValue("grantID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeInvalidID, "invalid UUID")
+		return
+	}
+
+	_, err = cfg.db.RevokeEntitlementGrant(r.Context(), grantID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, errCodeNotFound, "active grant not found")
+			return
+		}
+		slog.ErrorContext(r.Context(), "error revoking entitlement", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't revoke entitlement")
+		return
+	}
+
+	respondNoContent(w)
+}
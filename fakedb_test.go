@@ -0,0 +1,629 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ireoluwa12345/chirpy/internal/database"
+	"github.com/lib/pq"
+)
+
+// fakeDB is an in-memory database/sql connector that answers the user,
+// refresh token, OAuth, identity, chirp, pin, subscription, webhook event
+// and analytics hit queries by their sqlc name, so tests can drive whole flows
+// through the handlers. Other queries return no rows and change nothing,
+// like stubConnector.
+type fakeDB struct {
+	mu            sync.Mutex
+	users         []database.User
+	refreshTokens []database.RefreshToken
+	oauthTokens   []database.OauthRefreshToken
+	oauthClients  []database.OauthClient
+	oauthCodes    []database.OauthAuthorizationCode
+	oauthGrants   []database.OauthGrant
+	identities    []database.UserIdentity
+	linkStates    []database.OidcLinkState
+	chirps        []database.Chirp
+	pins          []database.PinnedChirp
+	subscriptions []database.Subscription
+	webhookEvents []database.WebhookEvent
+	analyticsHits map[string]int64 // by path, across buckets
+
+	// schemaVersion is the migration version Migrator.Version reports.
+	schemaVersion int64
+
+	// queries counts the queries run, by name.
+	queries map[string]int
+}
+
+var errUniqueViolation = errors.New("duplicate key value violates unique constraint")
+
+func (db *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{db}, nil }
+func (db *fakeDB) Driver() driver.Driver                        { return fakeDriver{db} }
+
+type fakeDriver struct{ db *fakeDB }
+
+func (d fakeDriver) Open(string) (driver.Conn, error) { return fakeConn(d), nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	// sqlc queries start with "-- name: <Name> :<kind>".
+	name, _, _ := strings.Cut(strings.TrimPrefix(query, "-- name: "), " ")
+	if strings.Contains(query, "FROM goose_db_version") {
+		name = "SchemaVersion"
+	}
+	return fakeStmt{db: c.db, name: name}, nil
+}
+
+func (c fakeConn) Close() error              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) { return stubTx{}, nil }
+
+type fakeStmt struct {
+	db   *fakeDB
+	name string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	_, affected, err := s.db.run(s.name, args)
+	return driver.RowsAffected(affected), err
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows, _, err := s.db.run(s.name, args)
+	return &fakeRows{rows: rows}, err
+}
+
+type fakeRows struct{ rows [][]driver.Value }
+
+func (r *fakeRows) Columns() []string {
+	if len(r.rows) == 0 {
+		return nil
+	}
+	return make([]string, len(r.rows[0]))
+}
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// run executes the query called name and returns its rows and how many rows
+// it changed.
+func (db *fakeDB) run(name string, args []driver.Value) ([][]driver.Value, int64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.queries == nil {
+		db.queries = map[string]int{}
+	}
+	db.queries[name]++
+
+	now := time.Now().UTC()
+	switch name {
+	case "SchemaVersion":
+		return [][]driver.Value{{db.schemaVersion}}, 0, nil
+	case "CreateUser":
+		email := args[1].(string)
+		if slices.ContainsFunc(db.users, func(u database.User) bool { return u.Email == email }) {
+			return nil, 0, errUniqueViolation
+		}
+		user := database.User{ID: argUUID(args[0]), CreatedAt: now, UpdatedAt: now, Email: email, Password: args[2].(string)}
+		db.users = append(db.users, user)
+		return [][]driver.Value{userRow(user)}, 1, nil
+	case "GetUserByEmail":
+		for _, user := range db.users {
+			if user.Email == args[0].(string) {
+				return [][]driver.Value{userRow(user)}, 0, nil
+			}
+		}
+	case "GetUserByID":
+		for _, user := range db.users {
+			if user.ID == argUUID(args[0]) {
+				return [][]driver.Value{userRow(user)}, 0, nil
+			}
+		}
+	case "UpdateUser":
+		for i := range db.users {
+			if db.users[i].ID == argUUID(args[2]) {
+				db.users[i].Email, db.users[i].Password, db.users[i].UpdatedAt = args[0].(string), args[1].(string), now
+				return [][]driver.Value{userRow(db.users[i])}, 1, nil
+			}
+		}
+	case "UpdateUserPassword":
+		for i := range db.users {
+			if db.users[i].ID == argUUID(args[0]) {
+				db.users[i].Password, db.users[i].UpdatedAt = args[1].(string), now
+				return nil, 1, nil
+			}
+		}
+	case "CreateRefreshToken":
+		token := database.RefreshToken{UserID: argUUID(args[0]), Token: args[1].(string), ExpiresAt: args[2].(time.Time), CreatedAt: now, UpdatedAt: now}
+		db.refreshTokens = append(db.refreshTokens, token)
+		return [][]driver.Value{refreshTokenRow(token)}, 1, nil
+	case "CheckRefreshToken":
+		for _, token := range db.refreshTokens {
+			if token.Token == args[0].(string) && !token.Revoked.Valid && token.ExpiresAt.After(now) {
+				return [][]driver.Value{refreshTokenRow(token)}, 0, nil
+			}
+		}
+	case "RevokeRefreshToken":
+		for i := range db.refreshTokens {
+			if db.refreshTokens[i].Token == args[0].(string) {
+				db.refreshTokens[i].Revoked = sql.NullTime{Time: now, Valid: true}
+				db.refreshTokens[i].UpdatedAt = now
+				return nil, 1, nil
+			}
+		}
+	case "DisableUser", "EnableUser":
+		for i := range db.users {
+			user := &db.users[i]
+			if user.ID != argUUID(args[0]) {
+				continue
+			}
+			if name == "EnableUser" {
+				user.DisabledAt = sql.NullTime{}
+			} else if !user.DisabledAt.Valid {
+				user.DisabledAt = sql.NullTime{Time: now, Valid: true}
+			}
+			user.UpdatedAt = now
+			return [][]driver.Value{userRow(*user)}, 1, nil
+		}
+	case "RevokeRefreshTokensForUser":
+		var revoked int64
+		for i := range db.refreshTokens {
+			if token := &db.refreshTokens[i]; token.UserID == argUUID(args[0]) && !token.Revoked.Valid {
+				token.Revoked, token.UpdatedAt = sql.NullTime{Time: now, Valid: true}, now
+				revoked++
+			}
+		}
+		return nil, revoked, nil
+	case "RevokeOAuthRefreshTokensForUser":
+		var revoked int64
+		for i := range db.oauthTokens {
+			if token := &db.oauthTokens[i]; token.UserID == argUUID(args[0]) && !token.RevokedAt.Valid {
+				token.RevokedAt, token.UpdatedAt = sql.NullTime{Time: now, Valid: true}, now
+				revoked++
+			}
+		}
+		return nil, revoked, nil
+	case "CreateOAuthClient":
+		var redirectURIs pq.StringArray
+		if err := redirectURIs.Scan(args[4]); err != nil {
+			return nil, 0, err
+		}
+		client := database.OauthClient{ID: args[0].(string), CreatedAt: now, UpdatedAt: now, OwnerID: argUUID(args[1]), Name: args[2].(string), RedirectUris: redirectURIs}
+		if args[3] != nil {
+			client.SecretHash = sql.NullString{String: args[3].(string), Valid: true}
+		}
+		db.oauthClients = append(db.oauthClients, client)
+		return [][]driver.Value{oauthClientRow(client)}, 1, nil
+	case "GetOAuthClient":
+		for _, client := range db.oauthClients {
+			if client.ID == args[0].(string) {
+				return [][]driver.Value{oauthClientRow(client)}, 0, nil
+			}
+		}
+	case "CreateOAuthAuthorizationCode":
+		db.oauthCodes = append(db.oauthCodes, database.OauthAuthorizationCode{
+			Code:          args[0].(string),
+			CreatedAt:     now,
+			ClientID:      args[1].(string),
+			UserID:        argUUID(args[2]),
+			RedirectUri:   args[3].(string),
+			Scope:         args[4].(string),
+			CodeChallenge: args[5].(string),
+			ExpiresAt:     args[6].(time.Time),
+		})
+		return nil, 1, nil
+	case "ConsumeOAuthAuthorizationCode":
+		for i := range db.oauthCodes {
+			code := &db.oauthCodes[i]
+			if code.Code == args[0].(string) && !code.UsedAt.Valid && code.ExpiresAt.After(now) {
+				code.UsedAt = sql.NullTime{Time: now, Valid: true}
+				return [][]driver.Value{{code.Code, code.CreatedAt, code.ClientID, code.UserID.String(), code.RedirectUri, code.Scope, code.CodeChallenge, code.ExpiresAt, now}}, 1, nil
+			}
+		}
+	case "GetUserIdentity":
+		for _, identity := range db.identities {
+			if identity.Provider == args[0].(string) && identity.Subject == args[1].(string) {
+				return [][]driver.Value{userIdentityRow(identity)}, 0, nil
+			}
+		}
+	case "CreateUserIdentity":
+		identity := database.UserIdentity{ID: argUUID(args[0]), CreatedAt: now, UpdatedAt: now, UserID: argUUID(args[1]), Provider: args[2].(string), Subject: args[3].(string), Email: args[4].(string)}
+		db.identities = append(db.identities, identity)
+		return [][]driver.Value{userIdentityRow(identity)}, 1, nil
+	case "DeleteOIDCLinkStatesForUser":
+		before := len(db.linkStates)
+		db.linkStates = slices.DeleteFunc(db.linkStates, func(l database.OidcLinkState) bool { return l.UserID == argUUID(args[0]) })
+		return nil, int64(before - len(db.linkStates)), nil
+	case "CreateOIDCLinkState":
+		db.linkStates = append(db.linkStates, database.OidcLinkState{
+			State:        args[0].(string),
+			CreatedAt:    now,
+			UserID:       argUUID(args[1]),
+			Provider:     args[2].(string),
+			Nonce:        args[3].(string),
+			CodeVerifier: args[4].(string),
+			ExpiresAt:    args[5].(time.Time),
+		})
+		return nil, 1, nil
+	case "ConsumeOIDCLinkState":
+		for i, link := range db.linkStates {
+			if link.State == args[0].(string) && link.Provider == args[1].(string) && link.ExpiresAt.After(now) {
+				db.linkStates = slices.Delete(db.linkStates, i, i+1)
+				return [][]driver.Value{{link.State, link.CreatedAt, link.UserID.String(), link.Provider, link.Nonce, link.CodeVerifier, link.ExpiresAt}}, 1, nil
+			}
+		}
+	case "UpsertOAuthGrant":
+		clientID, userID := args[0].(string), argUUID(args[1])
+		i := slices.IndexFunc(db.oauthGrants, func(g database.OauthGrant) bool { return g.ClientID == clientID && g.UserID == userID })
+		if i < 0 {
+			db.oauthGrants = append(db.oauthGrants, database.OauthGrant{ClientID: clientID, UserID: userID, CreatedAt: now})
+			i = len(db.oauthGrants) - 1
+		}
+		grant := &db.oauthGrants[i]
+		grant.Scope, grant.UpdatedAt, grant.RevokedAt = args[2].(string), now, sql.NullTime{}
+		return [][]driver.Value{{grant.ClientID, grant.UserID.String(), grant.CreatedAt, grant.UpdatedAt, grant.Scope, nil}}, 1, nil
+	case "ListOAuthGrantsForUser":
+		var rows [][]driver.Value
+		for _, grant := range db.oauthGrants {
+			client := slices.IndexFunc(db.oauthClients, func(c database.OauthClient) bool { return c.ID == grant.ClientID })
+			if grant.UserID == argUUID(args[0]) && !grant.RevokedAt.Valid && client >= 0 {
+				rows = append(rows, []driver.Value{grant.ClientID, db.oauthClients[client].Name, grant.Scope, grant.CreatedAt, grant.UpdatedAt})
+			}
+		}
+		return rows, 0, nil
+	case "RevokeOAuthGrant":
+		for i := range db.oauthGrants {
+			grant := &db.oauthGrants[i]
+			if grant.ClientID == args[0].(string) && grant.UserID == argUUID(args[1]) && !grant.RevokedAt.Valid {
+				grant.RevokedAt, grant.UpdatedAt = sql.NullTime{Time: now, Valid: true}, now
+				return nil, 1, nil
+			}
+		}
+	case "CreateOAuthRefreshToken":
+		token := database.OauthRefreshToken{Token: args[0].(string), CreatedAt: now, UpdatedAt: now, ClientID: args[1].(string), UserID: argUUID(args[2]), Scope: args[3].(string), ExpiresAt: args[4].(time.Time)}
+		db.oauthTokens = append(db.oauthTokens, token)
+		return [][]driver.Value{oauthRefreshTokenRow(token)}, 1, nil
+	case "CheckOAuthRefreshToken":
+		for _, token := range db.oauthTokens {
+			if token.Token == args[0].(string) && !token.RevokedAt.Valid && token.ExpiresAt.After(now) {
+				return [][]driver.Value{oauthRefreshTokenRow(token)}, 0, nil
+			}
+		}
+	case "RevokeOAuthRefreshToken":
+		for i := range db.oauthTokens {
+			token := &db.oauthTokens[i]
+			if token.Token == args[0].(string) && token.ClientID == args[1].(string) && !token.RevokedAt.Valid {
+				token.RevokedAt, token.UpdatedAt = sql.NullTime{Time: now, Valid: true}, now
+				return nil, 1, nil
+			}
+		}
+	case "RevokeOAuthRefreshTokensForGrant":
+		for i := range db.oauthTokens {
+			token := &db.oauthTokens[i]
+			if token.ClientID == args[0].(string) && token.UserID == argUUID(args[1]) && !token.RevokedAt.Valid {
+				token.RevokedAt, token.UpdatedAt = sql.NullTime{Time: now, Valid: true}, now
+			}
+		}
+		return nil, 0, nil
+	case "CreateChirp":
+		var mediaURLs pq.StringArray
+		if err := mediaURLs.Scan(args[3]); err != nil {
+			return nil, 0, err
+		}
+		chirp := database.Chirp{ID: argUUID(args[0]), CreatedAt: now, UpdatedAt: now, UserID: argUUID(args[1]), Body: args[2].(string), MediaUrls: mediaURLs}
+		db.chirps = append(db.chirps, chirp)
+		return [][]driver.Value{chirpRow(chirp)}, 1, nil
+	case "GetChirps":
+		ascending := args[2].(bool)
+		chirps := slices.SortedFunc(slices.Values(db.chirps), func(a, b database.Chirp) int {
+			return compareChirpKeys(a, b.CreatedAt, b.ID)
+		})
+		if !ascending {
+			slices.Reverse(chirps)
+		}
+		var rows [][]driver.Value
+		for _, chirp := range chirps {
+			if args[0] != nil && chirp.UserID != argUUID(args[0]) {
+				continue
+			}
+			if args[1] != nil {
+				c := compareChirpKeys(chirp, args[1].(time.Time), argUUID(args[3]))
+				if ascending && c <= 0 || !ascending && c >= 0 {
+					continue
+				}
+			}
+			rows = append(rows, chirpRow(chirp))
+		}
+		rows = rows[min(int(args[5].(int64)), len(rows)):]
+		if args[4] != nil {
+			rows = rows[:min(int(args[4].(int64)), len(rows))]
+		}
+		return rows, 0, nil
+	case "GetChirpByID":
+		for _, chirp := range db.chirps {
+			if chirp.ID == argUUID(args[0]) {
+				return [][]driver.Value{chirpRow(chirp)}, 0, nil
+			}
+		}
+	case "UpdateChirpBody":
+		for i := range db.chirps {
+			if db.chirps[i].ID == argUUID(args[0]) {
+				db.chirps[i].Body, db.chirps[i].UpdatedAt = args[1].(string), now
+				return [][]driver.Value{chirpRow(db.chirps[i])}, 1, nil
+			}
+		}
+	case "DeleteChirp":
+		before := len(db.chirps)
+		db.chirps = slices.DeleteFunc(db.chirps, func(c database.Chirp) bool { return c.ID == argUUID(args[0]) })
+		db.pins = slices.DeleteFunc(db.pins, func(p database.PinnedChirp) bool { return p.ChirpID == argUUID(args[0]) })
+		return nil, int64(before - len(db.chirps)), nil
+	case "PinChirp":
+		if db.pinned(argUUID(args[0]), argUUID(args[1])) {
+			return nil, 0, nil
+		}
+		db.pins = append(db.pins, database.PinnedChirp{UserID: argUUID(args[0]), ChirpID: argUUID(args[1]), CreatedAt: now})
+		return nil, 1, nil
+	case "IsChirpPinned":
+		return [][]driver.Value{{db.pinned(argUUID(args[0]), argUUID(args[1]))}}, 0, nil
+	case "CountPinnedChirps":
+		var count int64
+		for _, pin := range db.pins {
+			if pin.UserID == argUUID(args[0]) {
+				count++
+			}
+		}
+		return [][]driver.Value{{count}}, 0, nil
+	case "UnpinChirp":
+		before := len(db.pins)
+		db.pins = slices.DeleteFunc(db.pins, func(p database.PinnedChirp) bool {
+			return p.UserID == argUUID(args[0]) && p.ChirpID == argUUID(args[1])
+		})
+		return nil, int64(before - len(db.pins)), nil
+	case "GetPinnedChirps":
+		var rows [][]driver.Value
+		for _, pin := range slices.Backward(db.pins) {
+			if pin.UserID != argUUID(args[0]) {
+				continue
+			}
+			for _, chirp := range db.chirps {
+				if chirp.ID == pin.ChirpID {
+					rows = append(rows, chirpRow(chirp))
+				}
+			}
+		}
+		return rows, 0, nil
+	case "UpsertSubscription":
+		userID := argUUID(args[1])
+		i := slices.IndexFunc(db.subscriptions, func(s database.Subscription) bool { return s.UserID == userID })
+		if i < 0 {
+			db.subscriptions = append(db.subscriptions, database.Subscription{ID: argUUID(args[0]), CreatedAt: now, UserID: userID})
+			i = len(db.subscriptions) - 1
+		}
+		sub := &db.subscriptions[i]
+		sub.Plan, sub.Status, sub.CurrentPeriodEnd, sub.GracePeriodEnd, sub.UpdatedAt = args[2].(string), subscriptionActive, args[3].(time.Time), sql.NullTime{}, now
+		return [][]driver.Value{subscriptionRow(*sub)}, 1, nil
+	case "MarkSubscriptionPastDue":
+		for i := range db.subscriptions {
+			sub := &db.subscriptions[i]
+			if sub.UserID != argUUID(args[0]) || (sub.Status != subscriptionActive && sub.Status != subscriptionPastDue) {
+				continue
+			}
+			if !sub.GracePeriodEnd.Valid {
+				sub.GracePeriodEnd = sql.NullTime{Time: args[1].(time.Time), Valid: true}
+			}
+			sub.Status, sub.UpdatedAt = subscriptionPastDue, now
+			return [][]driver.Value{subscriptionRow(*sub)}, 1, nil
+		}
+	case "EndSubscription":
+		for i := range db.subscriptions {
+			sub := &db.subscriptions[i]
+			if sub.UserID == argUUID(args[0]) {
+				sub.Status, sub.GracePeriodEnd, sub.UpdatedAt = args[1].(string), sql.NullTime{}, now
+				return [][]driver.Value{subscriptionRow(*sub)}, 1, nil
+			}
+		}
+	case "ExpireLapsedSubscriptions":
+		var rows [][]driver.Value
+		for i := range db.subscriptions {
+			sub := &db.subscriptions[i]
+			lapsed := (sub.Status == subscriptionActive && !sub.CurrentPeriodEnd.After(now)) ||
+				(sub.Status == subscriptionPastDue && sub.GracePeriodEnd.Valid && !sub.GracePeriodEnd.Time.After(now))
+			if lapsed {
+				sub.Status, sub.UpdatedAt = subscriptionExpired, now
+				rows = append(rows, []driver.Value{sub.UserID.String()})
+			}
+		}
+		return rows, int64(len(rows)), nil
+	case "SyncUserChirpyRed":
+		for i := range db.users {
+			user := &db.users[i]
+			if user.ID != argUUID(args[0]) {
+				continue
+			}
+			user.IsChirpyRed = slices.ContainsFunc(db.subscriptions, func(s database.Subscription) bool {
+				return s.UserID == user.ID &&
+					((s.Status == subscriptionActive && s.CurrentPeriodEnd.After(now)) ||
+						(s.Status == subscriptionPastDue && s.GracePeriodEnd.Valid && s.GracePeriodEnd.Time.After(now)))
+			})
+			user.UpdatedAt = now
+			return [][]driver.Value{userRow(*user)}, 1, nil
+		}
+	case "AddAnalyticsHits":
+		if db.analyticsHits == nil {
+			db.analyticsHits = map[string]int64{}
+		}
+		db.analyticsHits[args[1].(string)] += args[2].(int64)
+		return nil, 1, nil
+	case "CountAnalyticsHits", "DeleteAnalyticsHits":
+		var hits int64
+		for path, count := range db.analyticsHits {
+			if strings.HasPrefix(path, args[0].(string)) {
+				hits += count
+				if name == "DeleteAnalyticsHits" {
+					delete(db.analyticsHits, path)
+				}
+			}
+		}
+		return [][]driver.Value{{hits}}, 0, nil
+	case "CreateWebhookEvent":
+		provider, eventID := args[1].(string), args[2].(string)
+		if slices.ContainsFunc(db.webhookEvents, func(e database.WebhookEvent) bool { return e.Provider == provider && e.EventID == eventID }) {
+			return nil, 0, nil
+		}
+		event := database.WebhookEvent{ID: argUUID(args[0]), CreatedAt: now, UpdatedAt: now, Provider: provider, EventID: eventID, EventType: args[3].(string), Payload: args[4].([]byte), Status: webhookEventReceived}
+		db.webhookEvents = append(db.webhookEvents, event)
+		return [][]driver.Value{webhookEventRow(event)}, 1, nil
+	case "GetWebhookEvent":
+		for _, event := range db.webhookEvents {
+			if event.Provider == args[0].(string) && event.EventID == args[1].(string) {
+				return [][]driver.Value{webhookEventRow(event)}, 0, nil
+			}
+		}
+	case "GetWebhookEventByID":
+		for _, event := range db.webhookEvents {
+			if event.ID == argUUID(args[0]) {
+				return [][]driver.Value{webhookEventRow(event)}, 0, nil
+			}
+		}
+	case "ListWebhookEvents":
+		var rows [][]driver.Value
+		for _, event := range slices.Backward(db.webhookEvents) {
+			if (args[0] == nil || event.Status == args[0].(string)) && int64(len(rows)) < args[1].(int64) {
+				rows = append(rows, webhookEventRow(event))
+			}
+		}
+		return rows, 0, nil
+	case "ClaimWebhookEvent":
+		var statuses pq.StringArray
+		if err := statuses.Scan(args[1]); err != nil {
+			return nil, 0, err
+		}
+		for i := range db.webhookEvents {
+			event := &db.webhookEvents[i]
+			if event.ID != argUUID(args[0]) {
+				continue
+			}
+			stale := event.Status == webhookEventProcessing && event.UpdatedAt.Before(now.Add(-5*time.Minute))
+			if !slices.Contains(statuses, event.Status) && !stale {
+				return nil, 0, nil
+			}
+			event.Status, event.Attempts, event.UpdatedAt = webhookEventProcessing, event.Attempts+1, now
+			return [][]driver.Value{webhookEventRow(*event)}, 1, nil
+		}
+	case "MarkWebhookEventProcessed":
+		for i := range db.webhookEvents {
+			event := &db.webhookEvents[i]
+			if event.ID == argUUID(args[0]) {
+				event.Status, event.Error, event.ProcessedAt, event.UpdatedAt = args[1].(string), sql.NullString{}, sql.NullTime{Time: now, Valid: true}, now
+				return [][]driver.Value{webhookEventRow(*event)}, 1, nil
+			}
+		}
+	case "MarkWebhookEventFailed":
+		for i := range db.webhookEvents {
+			event := &db.webhookEvents[i]
+			if event.ID == argUUID(args[0]) {
+				message, _ := args[1].(string)
+				event.Status, event.Error, event.UpdatedAt = webhookEventFailed, sql.NullString{String: message, Valid: args[1] != nil}, now
+				return [][]driver.Value{webhookEventRow(*event)}, 1, nil
+			}
+		}
+	}
+	return nil, 0, nil
+}
+
+// count returns how many times the query called name ran.
+func (db *fakeDB) count(name string) int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.queries[name]
+}
+
+func (db *fakeDB) pinned(userID, chirpID uuid.UUID) bool {
+	return slices.ContainsFunc(db.pins, func(p database.PinnedChirp) bool {
+		return p.UserID == userID && p.ChirpID == chirpID
+	})
+}
+
+func argUUID(v driver.Value) uuid.UUID {
+	var id uuid.UUID
+	id.Scan(v)
+	return id
+}
+
+// compareChirpKeys orders chirp against (createdAt, id) the way Postgres
+// compares the row values.
+func compareChirpKeys(chirp database.Chirp, createdAt time.Time, id uuid.UUID) int {
+	return cmp.Or(chirp.CreatedAt.Compare(createdAt), bytes.Compare(chirp.ID[:], id[:]))
+}
+
+func nullTime(t sql.NullTime) driver.Value {
+	if !t.Valid {
+		return nil
+	}
+	return t.Time
+}
+
+func userRow(u database.User) []driver.Value {
+	return []driver.Value{u.ID.String(), u.CreatedAt, u.UpdatedAt, u.Email, u.Password, u.IsChirpyRed, nullTime(u.DisabledAt)}
+}
+
+func refreshTokenRow(t database.RefreshToken) []driver.Value {
+	return []driver.Value{t.Token, t.CreatedAt, t.UpdatedAt, t.UserID.String(), t.ExpiresAt, nullTime(t.Revoked)}
+}
+
+func oauthClientRow(c database.OauthClient) []driver.Value {
+	var secretHash driver.Value
+	if c.SecretHash.Valid {
+		secretHash = c.SecretHash.String
+	}
+	redirectURIs, _ := pq.StringArray(c.RedirectUris).Value()
+	return []driver.Value{c.ID, c.CreatedAt, c.UpdatedAt, c.OwnerID.String(), c.Name, secretHash, redirectURIs}
+}
+
+func oauthRefreshTokenRow(t database.OauthRefreshToken) []driver.Value {
+	return []driver.Value{t.Token, t.CreatedAt, t.UpdatedAt, t.ClientID, t.UserID.String(), t.Scope, t.ExpiresAt, nullTime(t.RevokedAt)}
+}
+
+func userIdentityRow(i database.UserIdentity) []driver.Value {
+	return []driver.Value{i.ID.String(), i.CreatedAt, i.UpdatedAt, i.UserID.String(), i.Provider, i.Subject, i.Email}
+}
+
+func chirpRow(c database.Chirp) []driver.Value {
+	mediaURLs, _ := pq.StringArray(c.MediaUrls).Value()
+	return []driver.Value{c.ID.String(), c.CreatedAt, c.UpdatedAt, c.UserID.String(), c.Body, mediaURLs, c.ViewCount}
+}
+
+func subscriptionRow(s database.Subscription) []driver.Value {
+	return []driver.Value{s.ID.String(), s.CreatedAt, s.UpdatedAt, s.UserID.String(), s.Plan, s.Status, s.CurrentPeriodEnd, nullTime(s.GracePeriodEnd)}
+}
+
+func webhookEventRow(e database.WebhookEvent) []driver.Value {
+	var message driver.Value
+	if e.Error.Valid {
+		message = e.Error.String
+	}
+	return []driver.Value{e.ID.String(), e.CreatedAt, e.UpdatedAt, e.Provider, e.EventID, e.EventType, e.Payload, e.Status, message, int64(e.Attempts), nullTime(e.ProcessedAt)}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/ireoluwa12345/chirpy/internal/health"
+)
+
+var errShuttingDown = errors.New("shutting down")
+
+// checkMigrations fails until the database schema is at least at the
+// version of the newest migration built into the binary. A newer schema
+// passes: during a rolling deploy the new release migrates first, and the
+// old replicas must keep serving until they are replaced.
+func (cfg *apiConfig) checkMigrations(ctx context.Context) error {
+	version, err := cfg.migrator.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if want := cfg.migrator.Latest(); version < want {
+		return fmt.Errorf("schema is at version %d, want %d", version, want)
+	}
+	return nil
+}
+
+// readinessChecker checks everything the server needs to serve traffic: the
+// database, its schema, the background workers and that it isn't shutting
+// down.
+func (cfg *apiConfig) readinessChecker(heartbeats map[string]*health.Heartbeat) *health.Checker {
+	checker := health.NewChecker(2 * time.Second)
+	checker.Add("database", cfg.dbPool.PingContext)
+	checker.Add("migrations", cfg.checkMigrations)
+	for name, heartbeat := range heartbeats {
+		checker.Add("worker:"+name, heartbeat.Check)
+	}
+	checker.Add("shutdown", func(ctx context.Context) error {
+		if cfg.draining.Load() {
+			return errShuttingDown
+		}
+		return nil
+	})
+	return checker
+}
+
+// startupChecker passes once migrations have finished. It stops checking
+// after its first success, since startup probes stop running then.
+func (cfg *apiConfig) startupChecker() *health.Checker {
+	var started atomic.Bool
+
+	checker := health.NewChecker(2 * time.Second)
+	checker.Add("migrations", func(ctx context.Context) error {
+		if started.Load() {
+			return nil
+		}
+		if err := cfg.checkMigrations(ctx); err != nil {
+			return err
+		}
+		started.Store(true)
+		return nil
+	})
+	return checker
+}
+
+// HandleLivez reports that the process is up and serving. It doesn't check
+// dependencies, so an outage of one doesn't get every replica restarted.
+func HandleLivez(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
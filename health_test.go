@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCheckMigrations(t *testing.T) {
+	cfg, _ := newTestAPI(t, sql.OpenDB(&fakeDB{}))
+	latest := cfg.migrator.Latest()
+
+	tests := []struct {
+		name    string
+		version int64
+		wantErr bool
+	}{
+		{name: "behind", version: latest - 1, wantErr: true},
+		{name: "empty", version: 0, wantErr: true},
+		{name: "current", version: latest},
+		{name: "ahead during a rolling deploy", version: latest + 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, _ := newTestAPI(t, sql.OpenDB(&fakeDB{schemaVersion: tc.version}))
+
+			err := cfg.checkMigrations(context.Background())
+			if (err != nil) != tc.wantErr {
+				t.Errorf("checkMigrations() at version %d error = %v, wantErr %t", tc.version, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestDrain(t *testing.T) {
+	db := &fakeDB{}
+	cfg, handler := newTestAPI(t, sql.OpenDB(db))
+	db.schemaVersion = cfg.migrator.Latest()
+
+	// A request in flight when draining starts holds the server open until
+	// it's released.
+	started, release := make(chan struct{}), make(chan struct{})
+	mux := http.NewServeMux()
+	mux.Handle("/", handler)
+	mux.HandleFunc("GET /slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(listener)
+	base := "http://" + listener.Addr().String()
+
+	readyz := func() int {
+		t.Helper()
+		resp, err := http.Get(base + "/readyz")
+		if err != nil {
+			t.Fatalf("GET /readyz: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := readyz(); code != http.StatusOK {
+		t.Fatalf("readyz before shutdown = %d, want 200", code)
+	}
+
+	slow := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(base + "/slow")
+		if err == nil {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if string(body) != "done" {
+				err = fmt.Errorf("body = %q", body)
+			}
+		}
+		slow <- err
+	}()
+	<-started
+
+	drained := make(chan error, 1)
+	go func() { drained <- cfg.drain(srv, 200*time.Millisecond, 5*time.Second) }()
+
+	// Readiness fails while the server still accepts connections, so load
+	// balancers can take it out of rotation.
+	for deadline := time.Now().Add(5 * time.Second); readyz() != http.StatusServiceUnavailable; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("readyz didn't fail while draining")
+		}
+	}
+
+	select {
+	case err := <-drained:
+		t.Fatalf("drain returned %v with a request in flight", err)
+	case <-time.After(300 * time.Millisecond):
+	}
+	close(release)
+	if err := <-slow; err != nil {
+		t.Errorf("request in flight: %v", err)
+	}
+	if err := <-drained; err != nil {
+		t.Errorf("drain() error = %v", err)
+	}
+	if _, err := http.Get(base + "/readyz"); err == nil {
+		t.Error("server accepted a connection after draining")
+	}
+}
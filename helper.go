@@ -1 +1,105 @@
 package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Error codes sent as the "code" member of problem responses. Clients match
+// on them, so they must not change once released.
+const (
+	errCodeInvalidJSON         = "invalid_json"
+	errCodeInvalidRequest      = "invalid_request"
+	errCodeInvalidID           = "invalid_id"
+	errCodeUnauthorized        = "unauthorized"
+	errCodeInvalidToken        = "invalid_token"
+	errCodeInvalidCredentials  = "invalid_credentials"
+	errCodeInsufficientScope   = "insufficient_scope"
+	errCodeForbidden           = "forbidden"
+	errCodeAccountDisabled     = "account_disabled"
+	errCodeEntitlementRequired = "entitlement_required"
+	errCodeNotFound            = "not_found"
+	errCodeConflict            = "conflict"
+	errCodeChirpTooLong        = "chirp_too_long"
+	errCodeLimitExceeded       = "limit_exceeded"
+	errCodePasswordPolicy      = "password_policy"
+	errCodeRateLimited         = "rate_limited"
+	errCodeInternal            = "internal_error"
+	errCodeTimeout             = "timeout"
+	errCodeUnavailable         = "service_unavailable"
+)
+
+const problemContentType = "application/problem+json"
+
+// problem is an RFC 7807 problem details object. Code identifies the error
+// for programs and Detail explains it to people; Extensions are sent as
+// extra members, such as the limit a request exceeded.
+type problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Code       string
+	Extensions map[string]any
+}
+
+func (p problem) MarshalJSON() ([]byte, error) {
+	members := make(map[string]any, len(p.Extensions)+5)
+	for name, value := range p.Extensions {
+		members[name] = value
+	}
+	members["type"] = p.Type
+	members["title"] = p.Title
+	members["status"] = p.Status
+	members["code"] = p.Code
+	if p.Detail != "" {
+		members["detail"] = p.Detail
+	}
+	return json.Marshal(members)
+}
+
+// respondProblem writes p as an application/problem+json response. Type
+// defaults to about:blank and Title to the status text, as RFC 7807
+// prescribes for problems without their own type.
+func respondProblem(w http.ResponseWriter, p problem) {
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		// Only an unencodable extension gets here; drop them.
+		p.Extensions = nil
+		body, _ = json.Marshal(p)
+	}
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(p.Status)
+	w.Write(body)
+}
+
+// respondError writes a problem response with status, code and detail.
+func respondError(w http.ResponseWriter, status int, code, detail string) {
+	respondProblem(w, problem{Status: status, Code: code, Detail: detail})
+}
+
+// respondJSON writes v as a JSON response with status.
+func respondJSON(w http.ResponseWriter, status int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't encode response")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// respondNoContent writes an empty 204 response.
+func respondNoContent(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestRespondProblem(t *testing.T) {
+	tests := []struct {
+		name    string
+		problem problem
+		want    map[string]any
+	}{
+		{
+			name:    "defaults",
+			problem: problem{Status: http.StatusNotFound, Code: errCodeNotFound, Detail: "chirp not found"},
+			want: map[string]any{
+				"type":   "about:blank",
+				"title":  "Not Found",
+				"status": float64(404),
+				"code":   "not_found",
+				"detail": "chirp not found",
+			},
+		},
+		{
+			name: "extensions",
+			problem: problem{
+				Status:     http.StatusBadRequest,
+				Code:       errCodeChirpTooLong,
+				Detail:     "Chirp is too long",
+				Extensions: map[string]any{"max_length": 140},
+			},
+			want: map[string]any{
+				"type":       "about:blank",
+				"title":      "Bad Request",
+				"status":     float64(400),
+				"code":       "chirp_too_long",
+				"detail":     "Chirp is too long",
+				"max_length": float64(140),
+			},
+		},
+		{
+			name: "standard members win over extensions",
+			problem: problem{
+				Status:     http.StatusForbidden,
+				Code:       errCodeForbidden,
+				Extensions: map[string]any{"status": 200, "code": "ok"},
+			},
+			want: map[string]any{
+				"type":   "about:blank",
+				"title":  "Forbidden",
+				"status": float64(403),
+				"code":   "forbidden",
+			},
+		},
+		{
+			name: "unencodable extension",
+			problem: problem{
+				Status:     http.StatusInternalServerError,
+				Code:       errCodeInternal,
+				Extensions: map[string]any{"bad": math.Inf(1)},
+			},
+			want: map[string]any{
+				"type":   "about:blank",
+				"title":  "Internal Server Error",
+				"status": float64(500),
+				"code":   "internal_error",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			respondProblem(rec, tc.problem)
+
+			if rec.Code != tc.problem.Status {
+				t.Errorf("status = %d, want %d", rec.Code, tc.problem.Status)
+			}
+			if got := rec.Header().Get("Content-Type"); got != problemContentType {
+				t.Errorf("Content-Type = %q, want %q", got, problemContentType)
+			}
+
+			var got map[string]any
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("body %q is not JSON: %v", rec.Body, err)
+			}
+			if len(got) != len(tc.want) {
+				t.Errorf("body = %v, want %v", got, tc.want)
+			}
+			for name, want := range tc.want {
+				if got[name] != want {
+					t.Errorf("%s = %v, want %v", name, got[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestRespondJSON(t *testing.T) {
+	t.Run("encodes value", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		respondJSON(rec, http.StatusCreated, map[string]string{"id": "1"})
+
+		if rec.Code != http.StatusCreated {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusCreated)
+		}
+		if got := rec.Header().Get("Content-Type"); got != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", got)
+		}
+		if got := rec.Body.String(); got != `{"id":"1"}` {
+			t.Errorf("body = %s", got)
+		}
+	})
+
+	t.Run("unencodable value", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		respondJSON(rec, http.StatusOK, math.NaN())
+
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+		}
+		if got := rec.Header().Get("Content-Type"); got != problemContentType {
+			t.Errorf("Content-Type = %q, want %q", got, problemContentType)
+		}
+	})
+}
+
+// TestErrorResponses drives the real routes into their error paths and
+// checks every error body is a problem document with the expected code.
+func TestErrorResponses(t *testing.T) {
+	spec := loadOpenAPI(t)
+	token := testToken(t)
+	chirpID := uuid.NewString()
+
+	tests := []struct {
+		name     string
+		dbErr    error
+		method   string
+		path     string
+		body     string
+		header   http.Header
+		repeat   int
+		wantCode int
+		wantErr  string
+	}{
+		{name: "validate chirp bad json", method: "POST", path: "/api/validate_chirp", body: "{", wantCode: 400, wantErr: errCodeInvalidJSON},
+		{name: "validate chirp too long", method: "POST", path: "/api/validate_chirp", body: `{"body":"` + strings.Repeat("a", 141) + `"}`, wantCode: 400, wantErr: errCodeChirpTooLong},
+		{name: "create user bad json", method: "POST", path: "/api/users", body: "{", wantCode: 400, wantErr: errCodeInvalidJSON},
+		{name: "create user missing fields", method: "POST", path: "/api/users", body: `{}`, wantCode: 400, wantErr: errCodeInvalidRequest},
+		{name: "create user weak password", method: "POST", path: "/api/users", body: `{"email":"a@example.com","password":"short"}`, wantCode: 400, wantErr: errCodePasswordPolicy},
+		{name: "update user without token", method: "PUT", path: "/api/users", body: `{}`, wantCode: 401, wantErr: errCodeUnauthorized},
+		{name: "update user with bad token", method: "PUT", path: "/api/users", body: `{}`, header: bearer("not-a-jwt"), wantCode: 401, wantErr: errCodeInvalidToken},
+		{name: "update user bad json", method: "PUT", path: "/api/users", body: "{", header: bearer(token), wantCode: 400, wantErr: errCodeInvalidJSON},
+		{name: "login bad json", method: "POST", path: "/api/login", body: "{", wantCode: 400, wantErr: errCodeInvalidJSON},
+		{name: "login unknown user", method: "POST", path: "/api/login", body: `{"email":"a@example.com","password":"pw"}`, wantCode: 401, wantErr: errCodeInvalidCredentials},
+		{name: "login rate limited", method: "POST", path: "/api/login", body: "{", repeat: 4, wantCode: 429, wantErr: errCodeRateLimited},
+		{name: "create chirp without token", method: "POST", path: "/api/chirps", body: `{"body":"hi"}`, wantCode: 401, wantErr: errCodeUnauthorized},
+		{name: "create chirp bad json", method: "POST", path: "/api/chirps", body: "{", header: bearer(token), wantCode: 400, wantErr: errCodeInvalidJSON},
+		{name: "get chirp invalid id", method: "GET", path: "/api/chirps/nope", wantCode: 400, wantErr: errCodeInvalidID},
+		{name: "get chirps invalid author", method: "GET", path: "/api/chirps?author_id=nope", wantCode: 400, wantErr: errCodeInvalidID},
+		{name: "get chirps limit too large", method: "GET", path: "/api/chirps?limit=101", wantCode: 400, wantErr: errCodeInvalidRequest},
+		{name: "get chirps invalid after", method: "GET", path: "/api/chirps?after=nope", wantCode: 400, wantErr: errCodeInvalidID},
+		{name: "get chirps unknown after", method: "GET", path: "/api/chirps?after=" + chirpID, wantCode: 400, wantErr: errCodeInvalidRequest},
+		{name: "get chirps negative offset", method: "GET", path: "/api/chirps?offset=-1", wantCode: 400, wantErr: errCodeInvalidRequest},
+		{name: "get chirp not found", method: "GET", path: "/api/chirps/" + chirpID, wantCode: 404, wantErr: errCodeNotFound},
+		{name: "update chirp bad json", method: "PUT", path: "/api/chirps/" + chirpID, body: "{", header: bearer(token), wantCode: 400, wantErr: errCodeInvalidJSON},
+		{name: "delete chirp without token", method: "DELETE", path: "/api/chirps/" + chirpID, wantCode: 401, wantErr: errCodeUnauthorized},
+		{name: "delete chirp not found", method: "DELETE", path: "/api/chirps/" + chirpID, header: bearer(token), wantCode: 404, wantErr: errCodeNotFound},
+		{name: "pin chirp not found", method: "POST", path: "/api/chirps/" + chirpID + "/pin", header: bearer(token), wantCode: 404, wantErr: errCodeNotFound},
+		{name: "unpin chirp not pinned", method: "DELETE", path: "/api/chirps/" + chirpID + "/pin", header: bearer(token), wantCode: 404, wantErr: errCodeNotFound},
+		{name: "pinned chirps invalid id", method: "GET", path: "/api/users/nope/pinned", wantCode: 400, wantErr: errCodeInvalidID},
+		{name: "refresh without token", method: "POST", path: "/api/refresh", wantCode: 401, wantErr: errCodeUnauthorized},
+		{name: "refresh unknown token", method: "POST", path: "/api/refresh", header: bearer("unknown"), wantCode: 401, wantErr: errCodeInvalidToken},
+		{name: "revoke without token", method: "POST", path: "/api/revoke", wantCode: 401, wantErr: errCodeUnauthorized},
+		{name: "unknown billing provider", method: "POST", path: "/api/billing/nope/webhooks", body: "{}", wantCode: 404, wantErr: errCodeNotFound},
+		{name: "polka webhook unauthenticated", method: "POST", path: "/api/polka/webhooks", body: "{}", wantCode: 401, wantErr: errCodeUnauthorized},
+		{name: "unknown identity provider", method: "GET", path: "/api/auth/nope/login", wantCode: 404, wantErr: errCodeNotFound},
+		{name: "oauth client without token", method: "POST", path: "/api/oauth/clients", body: "{}", wantCode: 401, wantErr: errCodeUnauthorized},
+		{name: "create webhook bad url", method: "POST", path: "/api/webhooks", body: `{"url":"http://example.com","events":["chirp.created"]}`, header: bearer(token), wantCode: 400, wantErr: errCodeInvalidRequest},
+		{name: "create webhook loopback url", method: "POST", path: "/api/webhooks", body: `{"url":"https://127.0.0.1/","events":["chirp.created"]}`, header: bearer(token), wantCode: 400, wantErr: errCodeInvalidRequest},
+		{name: "create webhook IPv6 loopback url", method: "POST", path: "/api/webhooks", body: `{"url":"https://[::1]/","events":["chirp.created"]}`, header: bearer(token), wantCode: 400, wantErr: errCodeInvalidRequest},
+		{name: "create webhook link-local metadata url", method: "POST", path: "/api/webhooks", body: `{"url":"https://169.254.169.254/","events":["chirp.created"]}`, header: bearer(token), wantCode: 400, wantErr: errCodeInvalidRequest},
+		{name: "create webhook private url", method: "POST", path: "/api/webhooks", body: `{"url":"https://10.0.0.1/","events":["chirp.created"]}`, header: bearer(token), wantCode: 400, wantErr: errCodeInvalidRequest},
+		{name: "create webhook unspecified url", method: "POST", path: "/api/webhooks", body: `{"url":"https://0.0.0.0/","events":["chirp.created"]}`, header: bearer(token), wantCode: 400, wantErr: errCodeInvalidRequest},
+		{name: "create webhook localhost url", method: "POST", path: "/api/webhooks", body: `{"url":"https://localhost/","events":["chirp.created"]}`, header: bearer(token), wantCode: 400, wantErr: errCodeInvalidRequest},
+		{name: "admin webhook private url", method: "POST", path: "/admin/webhooks/endpoints", body: `{"url":"https://192.168.0.1/","events":["chirp.created"]}`, header: http.Header{"Authorization": {"ApiKey " + testAdminKey}}, wantCode: 400, wantErr: errCodeInvalidRequest},
+		{name: "admin without key", method: "GET", path: "/admin/analytics", wantCode: 401, wantErr: errCodeUnauthorized},
+		{name: "admin grant bad json", method: "POST", path: "/admin/entitlements", body: "{", header: http.Header{"Authorization": {"ApiKey " + testAdminKey}}, wantCode: 400, wantErr: errCodeInvalidJSON},
+		{name: "get chirps database down", dbErr: errDatabaseDown, method: "GET", path: "/api/chirps", wantCode: 500, wantErr: errCodeInternal},
+		{name: "get chirp database down", dbErr: errDatabaseDown, method: "GET", path: "/api/chirps/" + chirpID, wantCode: 500, wantErr: errCodeInternal},
+		{name: "login database down", dbErr: errDatabaseDown, method: "POST", path: "/api/login", body: `{"email":"a@example.com","password":"pw"}`, wantCode: 500, wantErr: errCodeInternal},
+		{name: "pinned chirps database down", dbErr: errDatabaseDown, method: "GET", path: "/api/users/" + chirpID + "/pinned", wantCode: 500, wantErr: errCodeInternal},
+		{name: "webhook events database down", dbErr: errDatabaseDown, method: "GET", path: "/admin/webhooks/events", header: http.Header{"Authorization": {"ApiKey " + testAdminKey}}, wantCode: 500, wantErr: errCodeInternal},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, handler := newTestAPI(t, sql.OpenDB(stubConnector{err: tc.dbErr}))
+
+			var req *http.Request
+			var rec *httptest.ResponseRecorder
+			for range max(tc.repeat, 1) {
+				req = httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+				for name, values := range tc.header {
+					req.Header[name] = values
+				}
+				rec = httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+			}
+
+			if rec.Code != tc.wantCode {
+				t.Errorf("status = %d, want %d; body %s", rec.Code, tc.wantCode, rec.Body)
+			}
+			assertProblem(t, rec, tc.wantErr)
+			assertMatchesSpec(t, spec, req, rec)
+		})
+	}
+}
+
+func TestTimeoutResponse(t *testing.T) {
+	cfg, _ := newTestAPI(t, sql.OpenDB(stubConnector{}))
+	handler := cfg.timeout(10*time.Millisecond, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "too slow")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/chirps", nil))
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusGatewayTimeout)
+	}
+	assertProblem(t, rec, errCodeTimeout)
+}
+
+func TestSuccessResponsesAreJSON(t *testing.T) {
+	_, handler := newTestAPI(t, sql.OpenDB(stubConnector{}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/chirps", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	if got := strings.TrimSpace(rec.Body.String()); got != "[]" {
+		t.Errorf("body = %s, want []", got)
+	}
+}
+
+func bearer(token string) http.Header {
+	return http.Header{"Authorization": {"Bearer " + token}}
+}
+
+// assertProblem checks rec holds a problem document with code.
+func assertProblem(t *testing.T, rec *httptest.ResponseRecorder, code string) {
+	t.Helper()
+
+	if got := rec.Header().Get("Content-Type"); got != problemContentType {
+		t.Errorf("Content-Type = %q, want %q", got, problemContentType)
+	}
+
+	var body struct {
+		Type   string `json:"type"`
+		Title  string `json:"title"`
+		Status int    `json:"status"`
+		Code   string `json:"code"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body %q is not JSON: %v", rec.Body, err)
+	}
+	if body.Type == "" || body.Title == "" {
+		t.Errorf("body %s lacks type or title", rec.Body)
+	}
+	if body.Status != rec.Code {
+		t.Errorf("body status = %d, want %d", body.Status, rec.Code)
+	}
+	if body.Code != code {
+		t.Errorf("code = %q, want %q", body.Code, code)
+	}
+}
@@ -0,0 +1,180 @@
+// Package analytics counts hits per path in time buckets. Hits are buffered
+// in memory and flushed to Postgres in per-minute buckets; a retention job
+// later rolls old minutes into hours and old hours into days.
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ireoluwa12345/chirpy/internal/database"
+)
+
+// Granularity is the width of a bucket.
+type Granularity string
+
+const (
+	Minute Granularity = "minute"
+	Hour   Granularity = "hour"
+	Day    Granularity = "day"
+)
+
+// ParseGranularity accepts "minute", "hour" or "day".
+func ParseGranularity(s string) (Granularity, error) {
+	switch g := Granularity(s); g {
+	case Minute, Hour, Day:
+		return g, nil
+	}
+	return "", fmt.Errorf("unknown granularity %q: expected minute, hour or day", s)
+}
+
+// Store persists minute buckets.
+type Store interface {
+	AddHits(ctx context.Context, bucketStart time.Time, path string, hits int64) error
+}
+
+type bucketKey struct {
+	start time.Time
+	path  string
+}
+
+// Buffer aggregates hits in memory until they are flushed, so recording a
+// hit never touches the database.
+type Buffer struct {
+	store Store
+	now   func() time.Time
+
+	mu     sync.Mutex
+	counts map[bucketKey]int64
+}
+
+func NewBuffer(store Store) *Buffer {
+	return &Buffer{
+		store:  store,
+		now:    time.Now,
+		counts: map[bucketKey]int64{},
+	}
+}
+
+// Record counts one hit on path in the current minute.
+func (b *Buffer) Record(path string) {
+	key := bucketKey{start: b.now().UTC().Truncate(time.Minute), path: path}
+
+	b.mu.Lock()
+	b.counts[key]++
+	b.mu.Unlock()
+}
+
+// Flush writes the buffered hits to the store. Hits that could not be
+// written stay buffered for the next flush.
+func (b *Buffer) Flush(ctx context.Context) error {
+	b.mu.Lock()
+	counts := b.counts
+	b.counts = map[bucketKey]int64{}
+	b.mu.Unlock()
+
+	var errs []error
+	failed := map[bucketKey]int64{}
+	for key, hits := range counts {
+		if err := b.store.AddHits(ctx, key.start, key.path, hits); err != nil {
+			errs = append(errs, err)
+			failed[key] = hits
+		}
+	}
+
+	if len(failed) > 0 {
+		b.mu.Lock()
+		for key, hits := range failed {
+			b.counts[key] += hits
+		}
+		b.mu.Unlock()
+	}
+
+	return errors.Join(errs...)
+}
+
+// Run flushes every interval until ctx is cancelled, then flushes once more
+// so hits recorded during shutdown aren't lost.
+func (b *Buffer) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := b.Flush(flushCtx); err != nil {
+				onError(err)
+			}
+			return
+		case <-ticker.C:
+			if err := b.Flush(ctx); err != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// PostgresStore writes minute buckets to the analytics_hits table.
+type PostgresStore struct {
+	db *database.Queries
+}
+
+func NewPostgresStore(db *database.Queries) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) AddHits(ctx context.Context, bucketStart time.Time, path string, hits int64) error {
+	return s.db.AddAnalyticsHits(ctx, database.AddAnalyticsHitsParams{
+		BucketStart: bucketStart,
+		Path:        path,
+		Hits:        hits,
+	})
+}
+
+// CountHits returns the hits on paths starting with prefix, across every
+// granularity.
+func (s *PostgresStore) CountHits(ctx context.Context, prefix string) (int64, error) {
+	return s.db.CountAnalyticsHits(ctx, prefix)
+}
+
+// DeleteHits deletes the hits on paths starting with prefix and returns how
+// many there were.
+func (s *PostgresStore) DeleteHits(ctx context.Context, prefix string) (int64, error) {
+	return s.db.DeleteAnalyticsHits(ctx, prefix)
+}
+
+// Retention is how long fine-grained buckets are kept before being rolled
+// into coarser ones. Day buckets are kept forever.
+type Retention struct {
+	Minutes time.Duration
+	Hours   time.Duration
+}
+
+// RollUp applies the retention policy as of now.
+func (s *PostgresStore) RollUp(ctx context.Context, retention Retention, now time.Time) error {
+	_, err := s.db.RollUpAnalyticsHits(ctx, database.RollUpAnalyticsHitsParams{
+		FromGranularity: string(Minute),
+		Before:          now.Add(-retention.Minutes).Truncate(time.Hour),
+		ToGranularity:   string(Hour),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.RollUpAnalyticsHits(ctx, database.RollUpAnalyticsHitsParams{
+		FromGranularity: string(Hour),
+		Before:          truncateDay(now.Add(-retention.Hours)),
+		ToGranularity:   string(Day),
+	})
+	return err
+}
+
+func truncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,99 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeStore struct {
+	hits map[bucketKey]int64
+	err  error
+}
+
+func (s *fakeStore) AddHits(ctx context.Context, bucketStart time.Time, path string, hits int64) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.hits[bucketKey{start: bucketStart, path: path}] += hits
+	return nil
+}
+
+func TestBufferFlush(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := &fakeStore{hits: map[bucketKey]int64{}}
+	buffer := NewBuffer(store)
+
+	now := start
+	buffer.now = func() time.Time { return now }
+
+	buffer.Record("/api/chirps")
+	now = start.Add(30 * time.Second)
+	buffer.Record("/api/chirps")
+	buffer.Record("/app/index.html")
+	now = start.Add(90 * time.Second)
+	buffer.Record("/api/chirps")
+
+	// A failed flush keeps the hits for the next one.
+	store.err = errors.New("database unavailable")
+	if err := buffer.Flush(context.Background()); err == nil {
+		t.Fatal("Flush() error = nil, want the store error")
+	}
+	store.err = nil
+	if err := buffer.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		start time.Time
+		path  string
+		want  int64
+	}{
+		{name: "Hits in the same minute are aggregated", start: start, path: "/api/chirps", want: 2},
+		{name: "Paths are counted separately", start: start, path: "/app/index.html", want: 1},
+		{name: "The next minute is its own bucket", start: start.Add(time.Minute), path: "/api/chirps", want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := store.hits[bucketKey{start: tt.start, path: tt.path}]; got != tt.want {
+				t.Errorf("hits = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	if err := buffer.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if got := store.hits[bucketKey{start: start, path: "/api/chirps"}]; got != 2 {
+		t.Errorf("hits after an empty Flush() = %d, want 2", got)
+	}
+}
+
+func TestParseGranularity(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Granularity
+		wantErr bool
+	}{
+		{input: "minute", want: Minute},
+		{input: "hour", want: Hour},
+		{input: "day", want: Day},
+		{input: "week", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseGranularity(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseGranularity(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseGranularity(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
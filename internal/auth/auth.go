@@ -1,10 +1,14 @@
 package auth
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
@@ -13,6 +17,7 @@ import (
 	"github.com/alexedwards/argon2id"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type TokenType string
@@ -22,59 +27,172 @@ const (
 	TokenTypeAccess TokenType = "chirpy-access"
 )
 
+// Scopes OAuth clients can be granted.
+const (
+	ScopeChirpsRead   = "chirps:read"
+	ScopeChirpsWrite  = "chirps:write"
+	ScopeProfileWrite = "profile:write"
+	// ScopeAccount covers managing the account itself: linking identities,
+	// registering and revoking OAuth apps. It is never granted to OAuth
+	// clients, so only first-party tokens have it.
+	ScopeAccount = "account"
+)
+
+// GrantableScopes are the scopes a user can grant an OAuth client.
+var GrantableScopes = []string{ScopeChirpsRead, ScopeChirpsWrite, ScopeProfileWrite}
+
+// DefaultPasswordParams are the Argon2id parameters used for new password
+// hashes unless SetPasswordParams overrides them.
+var DefaultPasswordParams = argon2id.Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+var passwordParams = DefaultPasswordParams
+
+// SetPasswordParams changes the Argon2id parameters used by HashPassword.
+// Hashes created with other parameters are reported by NeedsRehash.
+func SetPasswordParams(params argon2id.Params) error {
+	if params.Memory < 8*uint32(params.Parallelism) {
+		return errors.New("argon2id memory must be at least 8 KiB per thread")
+	}
+	if params.Iterations < 1 || params.Parallelism < 1 {
+		return errors.New("argon2id iterations and parallelism must be at least 1")
+	}
+	if params.SaltLength < 8 || params.KeyLength < 16 {
+		return errors.New("argon2id salt must be at least 8 bytes and key at least 16 bytes")
+	}
+
+	passwordParams = params
+	return nil
+}
+
 func HashPassword(password string) (string, error) {
-	hash, err := argon2id.CreateHash(password, &argon2id.Params{
-		Memory:      64 * 1024,
-		Iterations:  3,
-		Parallelism: 2,
-		SaltLength:  16,
-		KeyLength:   32,
-	})
+	params := passwordParams
+	hash, err := argon2id.CreateHash(password, &params)
 	return hash, err
 }
 
+// VerifyPassword checks password against an Argon2id hash, or a bcrypt hash
+// imported from the legacy system.
 func VerifyPassword(password, hashedPassword string) (bool, error) {
+	if isBcryptHash(hashedPassword) {
+		err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
 	return argon2id.ComparePasswordAndHash(password, hashedPassword)
 }
 
+// NeedsRehash reports whether hashedPassword should be replaced with a fresh
+// HashPassword hash: it is a legacy bcrypt hash, or an Argon2id hash created
+// with different parameters.
+func NeedsRehash(hashedPassword string) bool {
+	if isBcryptHash(hashedPassword) {
+		return true
+	}
+
+	params, salt, _, err := argon2id.DecodeHash(hashedPassword)
+	if err != nil {
+		return true
+	}
+	params.SaltLength = uint32(len(salt))
+
+	return *params != passwordParams
+}
+
+func isBcryptHash(hashedPassword string) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if strings.HasPrefix(hashedPassword, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Claims are the claims carried by Chirpy access tokens. Tokens issued to
+// third-party OAuth clients carry the client's ID and the granted scopes;
+// first-party tokens carry neither and may do anything.
+type Claims struct {
+	jwt.RegisteredClaims
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+}
+
+// Allows reports whether the token grants scope.
+func (c *Claims) Allows(scope string) bool {
+	if c.ClientID == "" {
+		return true
+	}
+	return slices.Contains(strings.Fields(c.Scope), scope)
+}
+
+// UserID returns the user the token was issued to.
+func (c *Claims) UserID() (uuid.UUID, error) {
+	id, err := uuid.Parse(c.Subject)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+	return id, nil
+}
+
 func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Subject:   userID.String(),
-		Issuer:    string(TokenTypeAccess),
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+	return MakeScopedJWT(userID, tokenSecret, expiresIn, "", "")
+}
+
+// MakeScopedJWT creates an access token for an OAuth client limited to the
+// space separated scope.
+func MakeScopedJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration, clientID, scope string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID.String(),
+			Issuer:    string(TokenTypeAccess),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+		},
+		ClientID: clientID,
+		Scope:    scope,
 	})
 	return token.SignedString([]byte(tokenSecret))
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	claimsStruct := &jwt.RegisteredClaims{}
-	token, err := jwt.ParseWithClaims(tokenString, claimsStruct, func(token *jwt.Token) (interface{}, error) {
-		return []byte(tokenSecret), nil
-	})
-
+	claims, err := ParseJWT(tokenString, tokenSecret)
 	if err != nil {
 		return uuid.Nil, err
 	}
+	return claims.UserID()
+}
+
+// ParseJWT validates an access token and returns its claims.
+func ParseJWT(tokenString, tokenSecret string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(tokenSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 
-	userIDString, err := token.Claims.GetSubject()
 	if err != nil {
-		return uuid.Nil, err
+		return nil, err
 	}
 
 	issuer, err := token.Claims.GetIssuer()
 	if err != nil {
-		return uuid.Nil, err
+		return nil, err
 	}
 	if issuer != string(TokenTypeAccess) {
-		return uuid.Nil, errors.New("invalid issuer")
+		return nil, errors.New("invalid issuer")
 	}
 
-	id, err := uuid.Parse(userIDString)
-	if err != nil {
-		return uuid.Nil, fmt.Errorf("invalid user ID: %w", err)
+	if _, err := claims.UserID(); err != nil {
+		return nil, err
 	}
-	return id, nil
+	return claims, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -112,3 +230,10 @@ func GetAPIKey(headers http.Header) (string, error) {
 
 	return authToken, nil
 }
+
+// VerifyPKCE reports whether verifier matches an S256 PKCE code challenge.
+func VerifyPKCE(verifier, challenge string) bool {
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
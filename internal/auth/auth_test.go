@@ -5,7 +5,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/alexedwards/argon2id"
 	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestCheckPasswordHash(t *testing.T) {
@@ -159,3 +161,139 @@ func TestGetBearerToken(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyLegacyBcryptPassword(t *testing.T) {
+	password := "correctPassword123!"
+	legacyHash, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+
+	tests := []struct {
+		name          string
+		password      string
+		wantErr       bool
+		matchPassword bool
+	}{
+		{
+			name:          "Correct password",
+			password:      password,
+			wantErr:       false,
+			matchPassword: true,
+		},
+		{
+			name:          "Incorrect password",
+			password:      "wrongPassword",
+			wantErr:       false,
+			matchPassword: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := VerifyPassword(tt.password, string(legacyHash))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyPassword() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if match != tt.matchPassword {
+				t.Errorf("VerifyPassword() expects %v, got %v", tt.matchPassword, match)
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	currentHash, _ := HashPassword("password")
+	legacyHash, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+
+	weakerParams := DefaultPasswordParams
+	weakerParams.Iterations = 1
+	outdatedHash, _ := argon2id.CreateHash("password", &weakerParams)
+
+	tests := []struct {
+		name            string
+		hash            string
+		wantNeedsRehash bool
+	}{
+		{
+			name:            "Current parameters",
+			hash:            currentHash,
+			wantNeedsRehash: false,
+		},
+		{
+			name:            "Outdated parameters",
+			hash:            outdatedHash,
+			wantNeedsRehash: true,
+		},
+		{
+			name:            "Legacy bcrypt hash",
+			hash:            string(legacyHash),
+			wantNeedsRehash: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NeedsRehash(tt.hash); got != tt.wantNeedsRehash {
+				t.Errorf("NeedsRehash() = %v, want %v", got, tt.wantNeedsRehash)
+			}
+		})
+	}
+}
+
+func TestScopedJWT(t *testing.T) {
+	userID := uuid.New()
+	firstPartyToken, _ := MakeJWT(userID, "secret", time.Hour)
+	clientToken, _ := MakeScopedJWT(userID, "secret", time.Hour, "client-id", ScopeChirpsWrite)
+
+	tests := []struct {
+		name        string
+		tokenString string
+		scope       string
+		wantAllowed bool
+	}{
+		{
+			name:        "First-party token allows any scope",
+			tokenString: firstPartyToken,
+			scope:       ScopeAccount,
+			wantAllowed: true,
+		},
+		{
+			name:        "Client token allows granted scope",
+			tokenString: clientToken,
+			scope:       ScopeChirpsWrite,
+			wantAllowed: true,
+		},
+		{
+			name:        "Client token denies other scopes",
+			tokenString: clientToken,
+			scope:       ScopeAccount,
+			wantAllowed: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := ParseJWT(tt.tokenString, "secret")
+			if err != nil {
+				t.Fatalf("ParseJWT() error = %v", err)
+			}
+			if got := claims.Allows(tt.scope); got != tt.wantAllowed {
+				t.Errorf("Allows(%q) = %v, want %v", tt.scope, got, tt.wantAllowed)
+			}
+			if gotUserID, _ := claims.UserID(); gotUserID != userID {
+				t.Errorf("UserID() = %v, want %v", gotUserID, userID)
+			}
+		})
+	}
+}
+
+func TestVerifyPKCE(t *testing.T) {
+	// Example from RFC 7636 appendix B.
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	if !VerifyPKCE(verifier, challenge) {
+		t.Errorf("VerifyPKCE() rejected the RFC 7636 example")
+	}
+	if VerifyPKCE("wrong-verifier", challenge) {
+		t.Errorf("VerifyPKCE() accepted the wrong verifier")
+	}
+}
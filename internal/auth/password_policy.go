@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// PasswordPolicy decides whether a new password is acceptable. It applies to
+// every place a password is chosen: sign-up, password change and reset.
+type PasswordPolicy struct {
+	MinLength int
+	// MinScore is the lowest acceptable PasswordScore, from 0 to 4.
+	MinScore int
+	// Breached, when set, rejects passwords found in a breach corpus.
+	Breached *BreachedPasswords
+}
+
+// FieldError describes why one request field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// PolicyError lists every rule a password broke.
+type PolicyError struct {
+	Errors []FieldError
+}
+
+func (e *PolicyError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, fieldErr := range e.Errors {
+		messages = append(messages, fieldErr.Field+": "+fieldErr.Message)
+	}
+	return "password policy violated: " + strings.Join(messages, "; ")
+}
+
+// Validate returns a *PolicyError if password breaks the policy for the
+// account identified by email.
+func (p PasswordPolicy) Validate(email, password string) error {
+	var errs []FieldError
+	fail := func(message string) {
+		errs = append(errs, FieldError{Field: "password", Message: message})
+	}
+
+	if length := len([]rune(password)); length < p.MinLength {
+		fail(fmt.Sprintf("must be at least %d characters long", p.MinLength))
+	}
+
+	if containsEmail(password, email) {
+		fail("must not contain your email address")
+	}
+
+	if PasswordScore(password) < p.MinScore {
+		fail("is too easy to guess; try a longer passphrase or mix in other characters")
+	}
+
+	if p.Breached != nil && p.Breached.Contains(password) {
+		fail("has appeared in a data breach and can't be used")
+	}
+
+	if len(errs) > 0 {
+		return &PolicyError{Errors: errs}
+	}
+	return nil
+}
+
+func containsEmail(password, email string) bool {
+	password = strings.ToLower(password)
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return false
+	}
+
+	localPart, _, _ := strings.Cut(email, "@")
+	if len(localPart) >= 3 && strings.Contains(password, localPart) {
+		return true
+	}
+	return strings.Contains(password, email)
+}
+
+var commonPasswords = []string{
+	"password", "123456", "qwerty", "letmein", "welcome", "admin", "iloveyou",
+	"monkey", "dragon", "football", "baseball", "sunshine", "princess",
+	"master", "shadow", "superman", "trustno1", "abc123", "login", "chirpy",
+}
+
+// PasswordScore estimates how hard password is to guess on zxcvbn's 0 (too
+// guessable) to 4 (very unguessable) scale. Runs, sequences and common words
+// count for little; the remaining characters count by the size of the
+// character classes in use.
+func PasswordScore(password string) int {
+	bits := passwordEntropy(password)
+
+	switch {
+	case bits < 10: // < 10^3 guesses
+		return 0
+	case bits < 20: // < 10^6 guesses
+		return 1
+	case bits < 26.6: // < 10^8 guesses
+		return 2
+	case bits < 33.2: // < 10^10 guesses
+		return 3
+	default:
+		return 4
+	}
+}
+
+func passwordEntropy(password string) float64 {
+	lower := strings.ToLower(password)
+	for rank, word := range commonPasswords {
+		if strings.Contains(lower, word) {
+			// Guessing the word costs about its rank in the list; the rest of
+			// the password is scored on its own.
+			rest := strings.Replace(lower, word, "", 1)
+			return math.Log2(float64(rank+2)) + passwordEntropy(rest)
+		}
+	}
+
+	runes := []rune(password)
+	var hasLower, hasUpper, hasDigit, hasOther bool
+	effectiveLength := 0.0
+
+	for i, r := range runes {
+		switch {
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		default:
+			hasOther = true
+		}
+
+		if i > 0 {
+			diff := unicode.ToLower(r) - unicode.ToLower(runes[i-1])
+			if diff >= -1 && diff <= 1 {
+				// Repeats and "abc"/"321" sequences add little.
+				effectiveLength += 0.25
+				continue
+			}
+		}
+		effectiveLength++
+	}
+
+	charset := 0
+	if hasLower {
+		charset += 26
+	}
+	if hasUpper {
+		charset += 26
+	}
+	if hasDigit {
+		charset += 10
+	}
+	if hasOther {
+		charset += 33
+	}
+	if charset == 0 {
+		return 0
+	}
+
+	return effectiveLength * math.Log2(float64(charset))
+}
+
+// BreachedPasswords is a local corpus of breached password hashes, indexed
+// by the 5 character SHA-1 prefix used by k-anonymity range lookups.
+type BreachedPasswords struct {
+	ranges map[string]map[string]struct{}
+}
+
+// LoadBreachedPasswords reads a corpus in the Have I Been Pwned download
+// format: one uppercase hex SHA-1 hash per line, optionally followed by
+// ":<count>".
+func LoadBreachedPasswords(path string) (*BreachedPasswords, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	breached := &BreachedPasswords{ranges: map[string]map[string]struct{}{}}
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		hash, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if hash == "" {
+			continue
+		}
+		if len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("%s:%d: invalid SHA-1 hash", path, line)
+		}
+		breached.add(strings.ToUpper(hash))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return breached, nil
+}
+
+func (b *BreachedPasswords) add(hash string) {
+	prefix, suffix := hash[:5], hash[5:]
+	if b.ranges[prefix] == nil {
+		b.ranges[prefix] = map[string]struct{}{}
+	}
+	b.ranges[prefix][suffix] = struct{}{}
+}
+
+// Contains reports whether password is in the corpus.
+func (b *BreachedPasswords) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	_, ok := b.ranges[hash[:5]][hash[5:]]
+	return ok
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPasswordScore(t *testing.T) {
+	tests := []struct {
+		name        string
+		password    string
+		wantBelow   int
+		wantAtLeast int
+	}{
+		{
+			name:      "Common password",
+			password:  "password1",
+			wantBelow: 2,
+		},
+		{
+			name:      "Keyboard sequence",
+			password:  "abcdefgh",
+			wantBelow: 2,
+		},
+		{
+			name:        "Long passphrase",
+			password:    "correct horse battery staple",
+			wantBelow:   5,
+			wantAtLeast: 4,
+		},
+		{
+			name:        "Mixed characters",
+			password:    "Tr0ub4dor&3",
+			wantBelow:   5,
+			wantAtLeast: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := PasswordScore(tt.password)
+			if score >= tt.wantBelow || score < tt.wantAtLeast {
+				t.Errorf("PasswordScore() = %v, want in [%v, %v)", score, tt.wantAtLeast, tt.wantBelow)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	breachedPassword := "Purple-Elephant-42"
+	sum := sha1.Sum([]byte(breachedPassword))
+	corpus := filepath.Join(t.TempDir(), "breached.txt")
+	os.WriteFile(corpus, []byte(strings.ToUpper(hex.EncodeToString(sum[:]))+":12\n"), 0o600)
+
+	breached, err := LoadBreachedPasswords(corpus)
+	if err != nil {
+		t.Fatalf("LoadBreachedPasswords() error = %v", err)
+	}
+
+	policy := PasswordPolicy{MinLength: 10, MinScore: 2, Breached: breached}
+
+	tests := []struct {
+		name       string
+		email      string
+		password   string
+		wantFields int
+	}{
+		{
+			name:       "Acceptable password",
+			email:      "walt@example.com",
+			password:   "Blue-Canyon-Rain-7",
+			wantFields: 0,
+		},
+		{
+			name:       "Too short and weak",
+			email:      "walt@example.com",
+			password:   "abc",
+			wantFields: 2,
+		},
+		{
+			name:       "Contains email",
+			email:      "walter@example.com",
+			password:   "Walter-Canyon-Rain-7",
+			wantFields: 1,
+		},
+		{
+			name:       "Breached password",
+			email:      "walt@example.com",
+			password:   breachedPassword,
+			wantFields: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.email, tt.password)
+			if tt.wantFields == 0 {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+
+			var policyErr *PolicyError
+			if !errors.As(err, &policyErr) {
+				t.Fatalf("Validate() error = %v, want *PolicyError", err)
+			}
+			if len(policyErr.Errors) != tt.wantFields {
+				t.Errorf("Validate() got %d field errors, want %d: %v", len(policyErr.Errors), tt.wantFields, policyErr)
+			}
+		})
+	}
+}
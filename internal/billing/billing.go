@@ -0,0 +1,59 @@
+// Package billing normalizes webhooks from payment providers into the few
+// events Chirpy acts on, so adding a processor doesn't touch the handlers.
+package billing
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUnauthorized   = errors.New("billing webhook failed authentication")
+	ErrInvalidPayload = errors.New("invalid billing webhook payload")
+)
+
+// EventType is a provider-independent billing event.
+type EventType string
+
+const (
+	// SubscriptionActivated starts or renews a subscription.
+	SubscriptionActivated EventType = "subscription.activated"
+	// PaymentFailed puts a subscription into its grace period.
+	PaymentFailed EventType = "payment.failed"
+	// SubscriptionCanceled ends a subscription immediately.
+	SubscriptionCanceled EventType = "subscription.canceled"
+	// SubscriptionRefunded ends a subscription and records the refund.
+	SubscriptionRefunded EventType = "subscription.refunded"
+)
+
+// Event is a normalized billing event.
+type Event struct {
+	// ID is the provider's ID for the event, used to deduplicate
+	// deliveries. It's empty if the provider didn't send one.
+	ID string `json:"id"`
+	// Type is empty for events Chirpy doesn't act on.
+	Type EventType `json:"type"`
+	// RawType is the provider's own name for the event.
+	RawType string    `json:"raw_type"`
+	UserID  uuid.UUID `json:"user_id"`
+	// Plan and CurrentPeriodEnd are set on SubscriptionActivated events when
+	// the provider sends them.
+	Plan             string    `json:"plan,omitempty"`
+	CurrentPeriodEnd time.Time `json:"current_period_end,omitempty"`
+}
+
+// Provider is a payment processor that sends Chirpy webhooks.
+type Provider interface {
+	// Name is used in the webhook URL, /api/billing/{name}/webhooks, and
+	// in the event log.
+	Name() string
+	// VerifyWebhook authenticates a delivery, returning ErrUnauthorized
+	// (possibly wrapped) if it can't be trusted.
+	VerifyWebhook(r *http.Request, body []byte) error
+	// ParseEvent normalizes a delivery. Stored payloads are parsed again on
+	// replay, when header is nil.
+	ParseEvent(header http.Header, body []byte) (Event, error)
+}
@@ -0,0 +1,62 @@
+// Package billingtest provides a fake billing provider for tests.
+package billingtest
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+
+	"github.com/ireoluwa12345/chirpy/internal/billing"
+)
+
+// SecretHeader carries the fake provider's shared secret.
+const SecretHeader = "X-Fake-Billing-Secret"
+
+// Fake is a billing provider whose payloads are normalized billing.Events
+// encoded as JSON, authenticated by a shared secret.
+type Fake struct {
+	Secret string
+}
+
+var _ billing.Provider = (*Fake)(nil)
+
+// NewFake returns a fake provider named "fake".
+func NewFake(secret string) *Fake {
+	return &Fake{Secret: secret}
+}
+
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+func (f *Fake) VerifyWebhook(r *http.Request, body []byte) error {
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(SecretHeader)), []byte(f.Secret)) != 1 {
+		return billing.ErrUnauthorized
+	}
+	return nil
+}
+
+func (f *Fake) ParseEvent(header http.Header, body []byte) (billing.Event, error) {
+	var event billing.Event
+	if err := json.Unmarshal(body, &event); err != nil || event.ID == "" {
+		return billing.Event{}, billing.ErrInvalidPayload
+	}
+	return event, nil
+}
+
+// NewRequest builds an authenticated delivery of event to url.
+func (f *Fake) NewRequest(url string, event billing.Event) (*http.Request, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SecretHeader, f.Secret)
+	return req, nil
+}
@@ -0,0 +1,157 @@
+package billing
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ireoluwa12345/chirpy/internal/webhook"
+)
+
+var _ Provider = (*Polka)(nil)
+
+func TestPolkaParseEvent(t *testing.T) {
+	userID := uuid.MustParse("3311741c-680c-4546-99f3-fc9efac2036c")
+
+	tests := []struct {
+		name    string
+		header  http.Header
+		body    string
+		want    Event
+		wantErr error
+	}{
+		{
+			name:   "Upgrade with event ID header",
+			header: http.Header{PolkaEventIDHeader: {"evt_1"}},
+			body:   `{"event":"user.upgraded","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c"}}`,
+			want:   Event{ID: "evt_1", Type: SubscriptionActivated, RawType: "user.upgraded", UserID: userID},
+		},
+		{
+			name: "Renewal with plan and period end",
+			body: `{"id":"evt_2","event":"subscription.renewed","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c","plan":"chirpy_red_yearly","current_period_end":"2026-01-01T00:00:00Z"}}`,
+			want: Event{
+				ID:               "evt_2",
+				Type:             SubscriptionActivated,
+				RawType:          "subscription.renewed",
+				UserID:           userID,
+				Plan:             "chirpy_red_yearly",
+				CurrentPeriodEnd: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "Payment failed",
+			body: `{"id":"evt_3","event":"payment.failed","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c"}}`,
+			want: Event{ID: "evt_3", Type: PaymentFailed, RawType: "payment.failed", UserID: userID},
+		},
+		{
+			name: "Downgrade",
+			body: `{"id":"evt_4","event":"user.downgraded","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c"}}`,
+			want: Event{ID: "evt_4", Type: SubscriptionCanceled, RawType: "user.downgraded", UserID: userID},
+		},
+		{
+			name: "Unknown events are returned without a type",
+			body: `{"id":"evt_5","event":"invoice.created","data":{}}`,
+			want: Event{ID: "evt_5", RawType: "invoice.created"},
+		},
+		{
+			name:    "Missing user ID",
+			body:    `{"id":"evt_6","event":"user.upgraded","data":{}}`,
+			wantErr: ErrInvalidPayload,
+		},
+		{
+			name:    "Malformed period end",
+			body:    `{"id":"evt_7","event":"user.upgraded","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c","current_period_end":"tomorrow"}}`,
+			wantErr: ErrInvalidPayload,
+		},
+		{
+			name:    "Malformed JSON",
+			body:    `{"event":`,
+			wantErr: ErrInvalidPayload,
+		},
+	}
+
+	polka := NewPolka("api-key", nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := polka.ParseEvent(tt.header, []byte(tt.body))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseEvent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseEvent() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolkaParseEventWithoutID(t *testing.T) {
+	body := []byte(`{"event":"user.upgraded","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c"}}`)
+
+	got, err := NewPolka("", nil).ParseEvent(nil, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != "" {
+		t.Errorf("ParseEvent() ID = %q, want empty so repeated events aren't deduplicated", got.ID)
+	}
+}
+
+func TestPolkaVerifyWebhook(t *testing.T) {
+	body := []byte(`{"event":"user.upgraded","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c"}}`)
+
+	tests := []struct {
+		name    string
+		polka   *Polka
+		header  http.Header
+		wantErr bool
+	}{
+		{
+			name:    "Matching API key",
+			polka:   NewPolka("api-key", nil),
+			header:  http.Header{"Authorization": {"ApiKey api-key"}},
+			wantErr: false,
+		},
+		{
+			name:    "Wrong API key",
+			polka:   NewPolka("api-key", nil),
+			header:  http.Header{"Authorization": {"ApiKey wrong"}},
+			wantErr: true,
+		},
+		{
+			name:    "No API key configured",
+			polka:   NewPolka("", nil),
+			header:  http.Header{"Authorization": {"ApiKey "}},
+			wantErr: true,
+		},
+		{
+			name:    "Valid signature",
+			polka:   NewPolka("", []string{"secret"}),
+			header:  http.Header{PolkaSignatureHeader: {webhook.Sign("secret", time.Now(), body)}},
+			wantErr: false,
+		},
+		{
+			name:    "API key is not accepted once signing is configured",
+			polka:   NewPolka("api-key", []string{"secret"}),
+			header:  http.Header{"Authorization": {"ApiKey api-key"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/billing/polka/webhooks", nil)
+			r.Header = tt.header
+
+			err := tt.polka.VerifyWebhook(r, body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyWebhook() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrUnauthorized) {
+				t.Errorf("VerifyWebhook() error = %v, want ErrUnauthorized", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,527 @@
+// Package config loads chirpy's configuration from defaults, an optional
+// JSON config file, environment variables and command-line flags, in
+// increasing order of precedence, and validates it before the server
+// starts.
+//
+// Every setting has a key such as "jwt_secret". It is read from the config
+// file under that key, from the environment variable JWT_SECRET and from
+// the flag -jwt-secret.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ireoluwa12345/chirpy/internal/ratelimit"
+)
+
+// Minimum lengths of secrets, in bytes.
+const (
+	MinJWTSecretLength = 32
+	MinAPIKeyLength    = 16
+)
+
+// Config is the server's configuration. Lists are comma separated in the
+// environment and in flags.
+type Config struct {
+	Port int `config:"port" default:"8080" help:"port to listen on"`
+
+	DBURL       string `config:"db_url" secret:"url" help:"Postgres connection URL (required)"`
+	AutoMigrate bool   `config:"auto_migrate" default:"false" help:"apply pending migrations on start"`
+	JWTSecret   string `config:"jwt_secret" secret:"true" help:"key signing access tokens (required)"`
+
+	AccessTokenExpiry  time.Duration `config:"access_token_expiry" default:"24h" help:"lifetime of access tokens"`
+	RefreshTokenExpiry time.Duration `config:"refresh_token_expiry" default:"1440h" help:"lifetime of refresh tokens"`
+
+	AdminAPIKey         string   `config:"admin_api_key" secret:"true" help:"key for the admin API; it is disabled when unset"`
+	PolkaKey            string   `config:"polka_key" secret:"true" help:"API key Polka sends with webhooks"`
+	PolkaWebhookSecrets []string `config:"polka_webhook_secrets" secret:"true" help:"secrets Polka may sign webhooks with"`
+	TrustProxyHeaders   bool     `config:"trust_proxy_headers" default:"false" help:"trust the X-Forwarded-For and X-Forwarded-Proto entries appended by a single reverse proxy"`
+
+	LogLevel         string  `config:"log_level" default:"info" help:"debug, info, warn or error"`
+	LogFormat        string  `config:"log_format" default:"json" help:"json or text"`
+	TraceExporter    string  `config:"trace_exporter" default:"none" help:"none, stderr, otlp-file, or stdout with log_format text"`
+	TraceFile        string  `config:"trace_file" default:"traces.jsonl" help:"file the otlp-file exporter appends to"`
+	TraceSampleRatio float64 `config:"trace_sample_ratio" default:"1" help:"fraction of new traces recorded"`
+
+	Argon2MemoryKiB       uint32 `config:"argon2_memory_kib" default:"65536" help:"Argon2id memory per password hash"`
+	Argon2Iterations      uint32 `config:"argon2_iterations" default:"3" help:"Argon2id iterations per password hash"`
+	Argon2Parallelism     uint8  `config:"argon2_parallelism" default:"2" help:"Argon2id threads per password hash"`
+	PasswordMinLength     int    `config:"password_min_length" default:"8" help:"minimum password length"`
+	PasswordMinScore      int    `config:"password_min_score" default:"2" help:"minimum password strength score, 0 to 4"`
+	BreachedPasswordsFile string `config:"breached_passwords_file" help:"file of breached passwords to reject"`
+
+	RateLimitBackend     string `config:"rate_limit_backend" default:"memory" help:"memory or postgres"`
+	RateLimitDefault     string `config:"rate_limit_default" default:"120/1m" help:"rate limit of API requests"`
+	RateLimitLogin       string `config:"rate_limit_login" default:"5/1m" help:"rate limit of logins"`
+	RateLimitCreateChirp string `config:"rate_limit_create_chirp" default:"10/1m" help:"rate limit of new chirps"`
+
+	OIDCProviders []string `config:"oidc_providers" help:"identity providers to discover, configured by OIDC_<NAME>_* variables"`
+
+	SubscriptionGracePeriod  time.Duration `config:"subscription_grace_period" default:"168h" help:"how long failed payments keep Chirpy Red"`
+	WebhookDeliveryInterval  time.Duration `config:"webhook_delivery_interval" default:"5s" help:"how often due webhooks are sent"`
+	AnalyticsFlushInterval   time.Duration `config:"analytics_flush_interval" default:"10s" help:"how often hits are written"`
+	AnalyticsMinuteRetention time.Duration `config:"analytics_minute_retention" default:"48h" help:"how long minute buckets are kept"`
+	AnalyticsHourRetention   time.Duration `config:"analytics_hour_retention" default:"2160h" help:"how long hour buckets are kept"`
+	ViewDedupWindow          time.Duration `config:"view_dedup_window" default:"30m" help:"how long repeat views aren't counted"`
+	ViewDedupMaxEntries      int           `config:"view_dedup_max_entries" default:"100000" help:"most views remembered for deduplication, and most chirp days of views kept unflushed"`
+	ViewFlushInterval        time.Duration `config:"view_flush_interval" default:"10s" help:"how often views are written"`
+
+	RequestTimeout        time.Duration `config:"request_timeout" default:"10s" help:"deadline of each request"`
+	HTTPReadHeaderTimeout time.Duration `config:"http_read_header_timeout" default:"5s" help:"time allowed to read request headers"`
+	HTTPReadTimeout       time.Duration `config:"http_read_timeout" default:"15s" help:"time allowed to read a request"`
+	HTTPWriteTimeout      time.Duration `config:"http_write_timeout" default:"15s" help:"time allowed to write a response; longer than request_timeout"`
+	HTTPIdleTimeout       time.Duration `config:"http_idle_timeout" default:"60s" help:"how long idle connections are kept"`
+	ShutdownDrainDelay    time.Duration `config:"shutdown_drain_delay" default:"5s" help:"how long readiness fails before draining"`
+	ShutdownTimeout       time.Duration `config:"shutdown_timeout" default:"30s" help:"time allowed to drain requests"`
+	ShutdownWorkerTimeout time.Duration `config:"shutdown_worker_timeout" default:"10s" help:"time allowed after draining to stop workers, and again to flush traces"`
+
+	// OIDC holds the settings of each provider in OIDCProviders. In the
+	// config file they are under "oidc"; in the environment a provider
+	// "google" is configured by OIDC_GOOGLE_ISSUER_URL and so on.
+	OIDC map[string]OIDCProvider `config:"-"`
+}
+
+// OIDCProvider configures an OpenID Connect identity provider.
+type OIDCProvider struct {
+	IssuerURL    string `json:"issuer_url"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RedirectURL  string `json:"redirect_url"`
+}
+
+// field is a setting of Config found through its struct tags.
+type field struct {
+	key    string
+	value  reflect.Value
+	def    string
+	help   string
+	secret string
+}
+
+func (f field) env() string {
+	return strings.ToUpper(f.key)
+}
+
+func (f field) flag() string {
+	return strings.ReplaceAll(f.key, "_", "-")
+}
+
+func (c *Config) fields() []field {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+
+	var fields []field
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		key := sf.Tag.Get("config")
+		if key == "" || key == "-" {
+			continue
+		}
+		fields = append(fields, field{
+			key:    key,
+			value:  v.Field(i),
+			def:    sf.Tag.Get("default"),
+			help:   sf.Tag.Get("help"),
+			secret: sf.Tag.Get("secret"),
+		})
+	}
+	return fields
+}
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+// set parses s into the field.
+func (f field) set(s string) error {
+	v := f.value
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case v.Kind() == reflect.Uint8 || v.Kind() == reflect.Uint32:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case v.Kind() == reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+// Load reads the configuration and validates it. args are the command-line
+// arguments without the program name, and getenv looks up environment
+// variables; an empty variable counts as unset. The config file is named by
+// -config or CONFIG_FILE.
+func Load(args []string, getenv func(string) string) (*Config, error) {
+	cfg, err := Parse(args, getenv)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Parse reads the configuration like Load but doesn't validate it, for
+// commands that need only some of the settings.
+func Parse(args []string, getenv func(string) string) (*Config, error) {
+	cfg := &Config{OIDC: map[string]OIDCProvider{}}
+	fields := cfg.fields()
+
+	for _, f := range fields {
+		if f.def == "" {
+			continue
+		}
+		if err := f.set(f.def); err != nil {
+			return nil, fmt.Errorf("default %s: %w", f.key, err)
+		}
+	}
+
+	// Flags are collected first and applied last, so they override the
+	// config file they may have named.
+	fs := flag.NewFlagSet("chirpy", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	configFile := fs.String("config", getenv("CONFIG_FILE"), "JSON config file")
+	flagValues := map[string]string{}
+	for _, f := range fields {
+		fs.Func(f.flag(), f.help, func(s string) error {
+			flagValues[f.key] = s
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile, fields); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, f := range fields {
+		if s := getenv(f.env()); s != "" {
+			if err := f.set(s); err != nil {
+				return nil, fmt.Errorf("%s: %w", f.env(), err)
+			}
+		}
+	}
+	cfg.loadOIDCEnv(getenv)
+
+	for _, f := range fields {
+		if s, ok := flagValues[f.key]; ok {
+			if err := f.set(s); err != nil {
+				return nil, fmt.Errorf("-%s: %w", f.flag(), err)
+			}
+		}
+	}
+
+	return cfg, nil
+}
+
+// Usage writes the flags Load accepts to w.
+func Usage(w io.Writer) {
+	fs := flag.NewFlagSet("chirpy", flag.ContinueOnError)
+	fs.SetOutput(w)
+	fs.String("config", "", "JSON config file (env CONFIG_FILE)")
+	for _, f := range (&Config{}).fields() {
+		help := fmt.Sprintf("%s (env %s)", f.help, f.env())
+		if f.def != "" {
+			help += fmt.Sprintf(" (default %s)", f.def)
+		}
+		fs.Func(f.flag(), help, func(string) error { return nil })
+	}
+	fs.PrintDefaults()
+}
+
+// loadFile reads settings from a JSON object keyed by setting. Values may
+// be strings or, where it makes sense, numbers, booleans and arrays of
+// strings.
+func (c *Config) loadFile(path string, fields []field) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if raw, ok := values["oidc"]; ok {
+		if err := json.Unmarshal(raw, &c.OIDC); err != nil {
+			return fmt.Errorf("%s: oidc: %w", path, err)
+		}
+		delete(values, "oidc")
+	}
+
+	for _, f := range fields {
+		raw, ok := values[f.key]
+		if !ok {
+			continue
+		}
+		delete(values, f.key)
+
+		s, err := fileValue(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", path, f.key, err)
+		}
+		if err := f.set(s); err != nil {
+			return fmt.Errorf("%s: %s: %w", path, f.key, err)
+		}
+	}
+
+	for key := range values {
+		return fmt.Errorf("%s: unknown setting %q", path, key)
+	}
+	return nil
+}
+
+// fileValue turns a JSON value into the text form settings are parsed
+// from.
+func fileValue(raw json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+
+	var items []string
+	if err := json.Unmarshal(raw, &items); err == nil {
+		return strings.Join(items, ","), nil
+	}
+
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return "", err
+	}
+	switch v.(type) {
+	case float64, bool:
+		return string(raw), nil
+	}
+	return "", errors.New("expected a string, number, boolean or array of strings")
+}
+
+// loadOIDCEnv reads the OIDC_<NAME>_* variables of each provider in
+// OIDCProviders, overriding the config file.
+func (c *Config) loadOIDCEnv(getenv func(string) string) {
+	for _, name := range c.OIDCProviders {
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		provider := c.OIDC[name]
+		for _, setting := range []struct {
+			env   string
+			value *string
+		}{
+			{"ISSUER_URL", &provider.IssuerURL},
+			{"CLIENT_ID", &provider.ClientID},
+			{"CLIENT_SECRET", &provider.ClientSecret},
+			{"REDIRECT_URL", &provider.RedirectURL},
+		} {
+			if s := getenv(prefix + setting.env); s != "" {
+				*setting.value = s
+			}
+		}
+		c.OIDC[name] = provider
+	}
+}
+
+// Validate checks that required settings are present, secrets are long
+// enough and values are in range.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port must be between 1 and 65535"))
+	}
+	if c.DBURL == "" {
+		errs = append(errs, errors.New("db_url is required"))
+	}
+	if c.JWTSecret == "" {
+		errs = append(errs, errors.New("jwt_secret is required"))
+	} else if len(c.JWTSecret) < MinJWTSecretLength {
+		errs = append(errs, fmt.Errorf("jwt_secret must be at least %d bytes", MinJWTSecretLength))
+	}
+	if c.AdminAPIKey != "" && len(c.AdminAPIKey) < MinAPIKeyLength {
+		errs = append(errs, fmt.Errorf("admin_api_key must be at least %d bytes", MinAPIKeyLength))
+	}
+	if c.PolkaKey != "" && len(c.PolkaKey) < MinAPIKeyLength {
+		errs = append(errs, fmt.Errorf("polka_key must be at least %d bytes", MinAPIKeyLength))
+	}
+	for _, secret := range c.PolkaWebhookSecrets {
+		if len(secret) < MinAPIKeyLength {
+			errs = append(errs, fmt.Errorf("polka_webhook_secrets must each be at least %d bytes", MinAPIKeyLength))
+			break
+		}
+	}
+
+	for key, d := range map[string]time.Duration{
+		"access_token_expiry":        c.AccessTokenExpiry,
+		"refresh_token_expiry":       c.RefreshTokenExpiry,
+		"webhook_delivery_interval":  c.WebhookDeliveryInterval,
+		"analytics_flush_interval":   c.AnalyticsFlushInterval,
+		"analytics_minute_retention": c.AnalyticsMinuteRetention,
+		"analytics_hour_retention":   c.AnalyticsHourRetention,
+		"view_flush_interval":        c.ViewFlushInterval,
+		"request_timeout":            c.RequestTimeout,
+		"http_read_header_timeout":   c.HTTPReadHeaderTimeout,
+		"http_read_timeout":          c.HTTPReadTimeout,
+		"http_write_timeout":         c.HTTPWriteTimeout,
+		"shutdown_timeout":           c.ShutdownTimeout,
+		"shutdown_worker_timeout":    c.ShutdownWorkerTimeout,
+	} {
+		if d <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", key))
+		}
+	}
+	// Spans on stdout would be interleaved with the JSON log lines there,
+	// breaking log pipelines that parse them.
+	if c.TraceExporter == "stdout" && c.LogFormat == "json" {
+		errs = append(errs, errors.New("trace_exporter stdout can't be used with log_format json; use stderr or otlp-file"))
+	}
+	if c.ViewDedupMaxEntries < 1 {
+		errs = append(errs, errors.New("view_dedup_max_entries must be positive"))
+	}
+	if c.RefreshTokenExpiry > 0 && c.RefreshTokenExpiry <= c.AccessTokenExpiry {
+		errs = append(errs, errors.New("refresh_token_expiry must be longer than access_token_expiry"))
+	}
+	if c.HTTPWriteTimeout > 0 && c.HTTPWriteTimeout <= c.RequestTimeout {
+		errs = append(errs, errors.New("http_write_timeout must be longer than request_timeout"))
+	}
+	if c.TraceSampleRatio < 0 || c.TraceSampleRatio > 1 {
+		errs = append(errs, errors.New("trace_sample_ratio must be between 0 and 1"))
+	}
+
+	switch c.RateLimitBackend {
+	case "memory", "postgres":
+	default:
+		errs = append(errs, fmt.Errorf("unknown rate_limit_backend %q", c.RateLimitBackend))
+	}
+	for key, rule := range map[string]string{
+		"rate_limit_default":      c.RateLimitDefault,
+		"rate_limit_login":        c.RateLimitLogin,
+		"rate_limit_create_chirp": c.RateLimitCreateChirp,
+	} {
+		if _, err := ratelimit.ParseRule(key, rule); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// LogValue lists every setting with secrets redacted, so the effective
+// configuration can be logged at startup.
+func (c *Config) LogValue() slog.Value {
+	var attrs []slog.Attr
+	for _, f := range c.fields() {
+		attrs = append(attrs, slog.Any(f.key, f.redacted()))
+	}
+
+	providers := make([]slog.Attr, 0, len(c.OIDC))
+	for name, provider := range c.OIDC {
+		providers = append(providers, slog.Group(name,
+			"issuer_url", provider.IssuerURL,
+			"client_id", provider.ClientID,
+			"client_secret", redact(provider.ClientSecret != ""),
+			"redirect_url", provider.RedirectURL,
+		))
+	}
+	attrs = append(attrs, slog.Attr{Key: "oidc", Value: slog.GroupValue(providers...)})
+
+	return slog.GroupValue(attrs...)
+}
+
+func (f field) redacted() any {
+	switch f.secret {
+	case "true":
+		return redact(!f.value.IsZero())
+	case "url":
+		return redactURL(f.value.String())
+	}
+	if f.value.Type() == durationType {
+		return time.Duration(f.value.Int()).String()
+	}
+	return f.value.Interface()
+}
+
+// redact hides a secret, showing only whether it is set.
+func redact(set bool) string {
+	if set {
+		return "[redacted]"
+	}
+	return ""
+}
+
+// dsnPassword matches the password of a key=value connection string, quoted
+// or not.
+var dsnPassword = regexp.MustCompile(`(^|\s)(password\s*=\s*)('(?:[^'\\]|\\.)*'|\S*)`)
+
+// redactURL hides the password of a connection URL, wherever it is given.
+// Connection strings in key=value form, without a scheme, are handled too.
+func redactURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	if !strings.Contains(raw, "://") {
+		return dsnPassword.ReplaceAllString(raw, "${1}${2}xxxxx")
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redact(true)
+	}
+
+	query := u.Query()
+	if query.Has("password") {
+		query.Set("password", "xxxxx")
+		u.RawQuery = query.Encode()
+	}
+	return u.Redacted()
+}
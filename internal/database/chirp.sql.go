@@ -7,26 +7,46 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const countPinnedChirps = `-- name: CountPinnedChirps :one
+SELECT COUNT(*) FROM pinned_chirps
+WHERE user_id = $1
+`
+
+func (q *Queries) CountPinnedChirps(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countPinnedChirps, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, user_id, body)
+INSERT INTO chirps (id, created_at, updated_at, user_id, body, media_urls)
 VALUES (
-    $1, NOW(), NOW(), $2, $3
+    $1, NOW(), NOW(), $2, $3, $4
 )
-RETURNING id, created_at, updated_at, user_id, body
+RETURNING id, created_at, updated_at, user_id, body, media_urls, view_count
 `
 
 type CreateChirpParams struct {
-	ID     uuid.UUID `json:"id"`
-	UserID uuid.UUID `json:"user_id"`
-	Body   string    `json:"body"`
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Body      string    `json:"body"`
+	MediaUrls []string  `json:"media_urls"`
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.ID, arg.UserID, arg.Body)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.ID,
+		arg.UserID,
+		arg.Body,
+		pq.Array(arg.MediaUrls),
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -34,6 +54,8 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.Body,
+		pq.Array(&i.MediaUrls),
+		&i.ViewCount,
 	)
 	return i, err
 }
@@ -48,8 +70,37 @@ func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const deleteChirpsByUser = `-- name: DeleteChirpsByUser :many
+DELETE FROM chirps
+WHERE user_id = $1
+RETURNING id
+`
+
+func (q *Queries) DeleteChirpsByUser(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, deleteChirpsByUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getChirpByID = `-- name: GetChirpByID :one
-SELECT id, created_at, updated_at, user_id, body
+SELECT id, created_at, updated_at, user_id, body, media_urls, view_count
 FROM chirps
 WHERE id = $1
 `
@@ -63,17 +114,46 @@ func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.Body,
+		pq.Array(&i.MediaUrls),
+		&i.ViewCount,
 	)
 	return i, err
 }
 
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, user_id, body
-FROM chirps ORDER BY created_at DESC
+SELECT id, created_at, updated_at, user_id, body, media_urls, view_count
+FROM chirps
+WHERE ($1::uuid IS NULL OR user_id = $1::uuid)
+  AND ($2::timestamptz IS NULL
+       OR ($3::bool AND (created_at, id) > ($2::timestamptz, $4::uuid))
+       OR (NOT $3::bool AND (created_at, id) < ($2::timestamptz, $4::uuid)))
+ORDER BY
+    CASE WHEN $3::bool THEN created_at END ASC,
+    CASE WHEN $3::bool THEN id END ASC,
+    created_at DESC,
+    id DESC
+LIMIT $5::int
+OFFSET $6::int
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+type GetChirpsParams struct {
+	AuthorID       uuid.NullUUID `json:"author_id"`
+	AfterCreatedAt sql.NullTime  `json:"after_created_at"`
+	Ascending      bool          `json:"ascending"`
+	AfterID        uuid.NullUUID `json:"after_id"`
+	RowLimit       sql.NullInt32 `json:"row_limit"`
+	RowOffset      int32         `json:"row_offset"`
+}
+
+func (q *Queries) GetChirps(ctx context.Context, arg GetChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirps,
+		arg.AuthorID,
+		arg.AfterCreatedAt,
+		arg.Ascending,
+		arg.AfterID,
+		arg.RowLimit,
+		arg.RowOffset,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -87,6 +167,8 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.UserID,
 			&i.Body,
+			pq.Array(&i.MediaUrls),
+			&i.ViewCount,
 		); err != nil {
 			return nil, err
 		}
@@ -100,3 +182,122 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 	}
 	return items, nil
 }
+
+const getPinnedChirps = `-- name: GetPinnedChirps :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.user_id, chirps.body, chirps.media_urls, chirps.view_count
+FROM chirps
+JOIN pinned_chirps ON pinned_chirps.chirp_id = chirps.id
+WHERE pinned_chirps.user_id = $1
+ORDER BY pinned_chirps.created_at DESC
+`
+
+func (q *Queries) GetPinnedChirps(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getPinnedChirps, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Body,
+			pq.Array(&i.MediaUrls),
+			&i.ViewCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const isChirpPinned = `-- name: IsChirpPinned :one
+SELECT EXISTS (
+    SELECT 1 FROM pinned_chirps
+    WHERE user_id = $1 AND chirp_id = $2
+)
+`
+
+type IsChirpPinnedParams struct {
+	UserID  uuid.UUID `json:"user_id"`
+	ChirpID uuid.UUID `json:"chirp_id"`
+}
+
+func (q *Queries) IsChirpPinned(ctx context.Context, arg IsChirpPinnedParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isChirpPinned, arg.UserID, arg.ChirpID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const pinChirp = `-- name: PinChirp :exec
+INSERT INTO pinned_chirps (user_id, chirp_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING
+`
+
+type PinChirpParams struct {
+	UserID  uuid.UUID `json:"user_id"`
+	ChirpID uuid.UUID `json:"chirp_id"`
+}
+
+func (q *Queries) PinChirp(ctx context.Context, arg PinChirpParams) error {
+	_, err := q.db.ExecContext(ctx, pinChirp, arg.UserID, arg.ChirpID)
+	return err
+}
+
+const unpinChirp = `-- name: UnpinChirp :execrows
+DELETE FROM pinned_chirps
+WHERE user_id = $1 AND chirp_id = $2
+`
+
+type UnpinChirpParams struct {
+	UserID  uuid.UUID `json:"user_id"`
+	ChirpID uuid.UUID `json:"chirp_id"`
+}
+
+func (q *Queries) UnpinChirp(ctx context.Context, arg UnpinChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unpinChirp, arg.UserID, arg.ChirpID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, user_id, body, media_urls, view_count
+`
+
+type UpdateChirpBodyParams struct {
+	ID   uuid.UUID `json:"id"`
+	Body string    `json:"body"`
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.ID, arg.Body)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Body,
+		pq.Array(&i.MediaUrls),
+		&i.ViewCount,
+	)
+	return i, err
+}
@@ -6,17 +6,108 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type AnalyticsHit struct {
+	Granularity string    `json:"granularity"`
+	BucketStart time.Time `json:"bucket_start"`
+	Path        string    `json:"path"`
+	Hits        int64     `json:"hits"`
+}
+
 type Chirp struct {
 	ID        uuid.UUID `json:"id"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	UserID    uuid.UUID `json:"user_id"`
 	Body      string    `json:"body"`
+	MediaUrls []string  `json:"media_urls"`
+	ViewCount int64     `json:"view_count"`
+}
+
+type ChirpDailyView struct {
+	ChirpID uuid.UUID `json:"chirp_id"`
+	Day     time.Time `json:"day"`
+	Views   int64     `json:"views"`
+}
+
+type EntitlementGrant struct {
+	ID        uuid.UUID    `json:"id"`
+	CreatedAt time.Time    `json:"created_at"`
+	UserID    uuid.UUID    `json:"user_id"`
+	Feature   string       `json:"feature"`
+	Reason    string       `json:"reason"`
+	ExpiresAt sql.NullTime `json:"expires_at"`
+	RevokedAt sql.NullTime `json:"revoked_at"`
+}
+
+type OauthAuthorizationCode struct {
+	Code          string       `json:"code"`
+	CreatedAt     time.Time    `json:"created_at"`
+	ClientID      string       `json:"client_id"`
+	UserID        uuid.UUID    `json:"user_id"`
+	RedirectUri   string       `json:"redirect_uri"`
+	Scope         string       `json:"scope"`
+	CodeChallenge string       `json:"code_challenge"`
+	ExpiresAt     time.Time    `json:"expires_at"`
+	UsedAt        sql.NullTime `json:"used_at"`
+}
+
+type OauthClient struct {
+	ID           string         `json:"id"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	OwnerID      uuid.UUID      `json:"owner_id"`
+	Name         string         `json:"name"`
+	SecretHash   sql.NullString `json:"secret_hash"`
+	RedirectUris []string       `json:"redirect_uris"`
+}
+
+type OauthGrant struct {
+	ClientID  string       `json:"client_id"`
+	UserID    uuid.UUID    `json:"user_id"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+	Scope     string       `json:"scope"`
+	RevokedAt sql.NullTime `json:"revoked_at"`
+}
+
+type OauthRefreshToken struct {
+	Token     string       `json:"token"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+	ClientID  string       `json:"client_id"`
+	UserID    uuid.UUID    `json:"user_id"`
+	Scope     string       `json:"scope"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	RevokedAt sql.NullTime `json:"revoked_at"`
+}
+
+type OidcLinkState struct {
+	State        string    `json:"state"`
+	CreatedAt    time.Time `json:"created_at"`
+	UserID       uuid.UUID `json:"user_id"`
+	Provider     string    `json:"provider"`
+	Nonce        string    `json:"nonce"`
+	CodeVerifier string    `json:"code_verifier"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+type PinnedChirp struct {
+	UserID    uuid.UUID `json:"user_id"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type RateLimitBucket struct {
+	Key       string    `json:"key"`
+	Tokens    float64   `json:"tokens"`
+	Allowed   bool      `json:"allowed"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type RefreshToken struct {
@@ -28,11 +119,75 @@ type RefreshToken struct {
 	Revoked   sql.NullTime `json:"revoked"`
 }
 
+type Subscription struct {
+	ID               uuid.UUID    `json:"id"`
+	CreatedAt        time.Time    `json:"created_at"`
+	UpdatedAt        time.Time    `json:"updated_at"`
+	UserID           uuid.UUID    `json:"user_id"`
+	Plan             string       `json:"plan"`
+	Status           string       `json:"status"`
+	CurrentPeriodEnd time.Time    `json:"current_period_end"`
+	GracePeriodEnd   sql.NullTime `json:"grace_period_end"`
+}
+
 type User struct {
-	ID          uuid.UUID `json:"id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Email       string    `json:"email"`
-	Password    string    `json:"password"`
-	IsChirpyRed bool      `json:"is_chirpy_red"`
+	ID          uuid.UUID    `json:"id"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	Email       string       `json:"email"`
+	Password    string       `json:"password"`
+	IsChirpyRed bool         `json:"is_chirpy_red"`
+	DisabledAt  sql.NullTime `json:"disabled_at"`
+}
+
+type UserIdentity struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uuid.UUID `json:"user_id"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	EndpointID     uuid.UUID       `json:"endpoint_id"`
+	EventID        uuid.UUID       `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatusCode sql.NullInt32   `json:"last_status_code"`
+	LastError      sql.NullString  `json:"last_error"`
+	DeliveredAt    sql.NullTime    `json:"delivered_at"`
+	TraceContext   json.RawMessage `json:"trace_context"`
+}
+
+type WebhookEndpoint struct {
+	ID        uuid.UUID     `json:"id"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	OwnerID   uuid.NullUUID `json:"owner_id"`
+	Url       string        `json:"url"`
+	Secret    string        `json:"secret"`
+	Events    []string      `json:"events"`
+	Active    bool          `json:"active"`
+}
+
+type WebhookEvent struct {
+	ID          uuid.UUID       `json:"id"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	Provider    string          `json:"provider"`
+	EventID     string          `json:"event_id"`
+	EventType   string          `json:"event_type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Error       sql.NullString  `json:"error"`
+	Attempts    int32           `json:"attempts"`
+	ProcessedAt sql.NullTime    `json:"processed_at"`
 }
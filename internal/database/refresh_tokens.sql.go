@@ -69,3 +69,17 @@ func (q *Queries) RevokeRefreshToken(ctx context.Context, token string) error {
 	_, err := q.db.ExecContext(ctx, revokeRefreshToken, token)
 	return err
 }
+
+const revokeRefreshTokensForUser = `-- name: RevokeRefreshTokensForUser :execrows
+UPDATE refresh_tokens
+SET revoked = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked IS NULL
+`
+
+func (q *Queries) RevokeRefreshTokensForUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeRefreshTokensForUser, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -16,7 +16,7 @@ INSERT INTO users (id, created_at, updated_at, email, password)
 VALUES (
     $1, NOW(), NOW(), $2, $3
 )
-RETURNING id, created_at, updated_at, email, password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, password, is_chirpy_red, disabled_at
 `
 
 type CreateUserParams struct {
@@ -35,12 +35,57 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.Password,
 		&i.IsChirpyRed,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const disableUser = `-- name: DisableUser :one
+UPDATE users
+SET updated_at = NOW(), disabled_at = COALESCE(disabled_at, NOW())
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, password, is_chirpy_red, disabled_at
+`
+
+func (q *Queries) DisableUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, disableUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.Password,
+		&i.IsChirpyRed,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const enableUser = `-- name: EnableUser :one
+UPDATE users
+SET updated_at = NOW(), disabled_at = NULL
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, password, is_chirpy_red, disabled_at
+`
+
+func (q *Queries) EnableUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, enableUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.Password,
+		&i.IsChirpyRed,
+		&i.DisabledAt,
 	)
 	return i, err
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, password, is_chirpy_red
+SELECT id, created_at, updated_at, email, password, is_chirpy_red, disabled_at
 FROM users
 WHERE email = $1
 `
@@ -55,6 +100,57 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.Password,
 		&i.IsChirpyRed,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, password, is_chirpy_red, disabled_at
+FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.Password,
+		&i.IsChirpyRed,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const syncUserChirpyRed = `-- name: SyncUserChirpyRed :one
+UPDATE users
+SET updated_at = NOW(), is_chirpy_red = EXISTS (
+    SELECT 1 FROM subscriptions
+    WHERE subscriptions.user_id = users.id
+      AND (
+        (subscriptions.status = 'active' AND subscriptions.current_period_end > NOW())
+        OR (subscriptions.status = 'past_due' AND subscriptions.grace_period_end > NOW())
+      )
+)
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, password, is_chirpy_red, disabled_at
+`
+
+func (q *Queries) SyncUserChirpyRed(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, syncUserChirpyRed, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.Password,
+		&i.IsChirpyRed,
+		&i.DisabledAt,
 	)
 	return i, err
 }
@@ -63,7 +159,7 @@ const updateUser = `-- name: UpdateUser :one
 UPDATE users
 SET updated_at = NOW(), email = $1, password = $2
 WHERE id = $3
-RETURNING id, created_at, updated_at, email, password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, password, is_chirpy_red, disabled_at
 `
 
 type UpdateUserParams struct {
@@ -82,27 +178,23 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Email,
 		&i.Password,
 		&i.IsChirpyRed,
+		&i.DisabledAt,
 	)
 	return i, err
 }
 
-const upgradeUser = `-- name: UpgradeUser :one
+const updateUserPassword = `-- name: UpdateUserPassword :exec
 UPDATE users
-SET updated_at = NOW(), is_chirpy_red = TRUE
+SET updated_at = NOW(), password = $2
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, password, is_chirpy_red
 `
 
-func (q *Queries) UpgradeUser(ctx context.Context, id uuid.UUID) (User, error) {
-	row := q.db.QueryRowContext(ctx, upgradeUser, id)
-	var i User
-	err := row.Scan(
-		&i.ID,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.Email,
-		&i.Password,
-		&i.IsChirpyRed,
-	)
-	return i, err
+type UpdateUserPasswordParams struct {
+	ID       uuid.UUID `json:"id"`
+	Password string    `json:"password"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.ID, arg.Password)
+	return err
 }
@@ -0,0 +1,87 @@
+// Package entitlements decides what a user is allowed to do based on their
+// Chirpy Red status and any features granted to them by hand.
+package entitlements
+
+// Feature is something that can be granted to a user individually.
+type Feature string
+
+const (
+	// ChirpyRed grants every premium feature, as a subscription does.
+	ChirpyRed        Feature = "chirpy_red"
+	LongChirps       Feature = "long_chirps"
+	EditChirps       Feature = "edit_chirps"
+	HigherRateLimits Feature = "higher_rate_limits"
+	ExtraPins        Feature = "extra_pins"
+	MediaAttachments Feature = "media_attachments"
+)
+
+// Features lists every grantable feature.
+var Features = []Feature{ChirpyRed, LongChirps, EditChirps, HigherRateLimits, ExtraPins, MediaAttachments}
+
+// Valid reports whether f is a known feature.
+func Valid(f Feature) bool {
+	for _, feature := range Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// Entitlements are the limits that apply to one user.
+type Entitlements struct {
+	MaxChirpLength      int  `json:"max_chirp_length"`
+	CanEditChirps       bool `json:"can_edit_chirps"`
+	RateLimitMultiplier int  `json:"rate_limit_multiplier"`
+	MaxPinnedChirps     int  `json:"max_pinned_chirps"`
+	MaxMediaAttachments int  `json:"max_media_attachments"`
+}
+
+// Free is what every user gets.
+var Free = Entitlements{
+	MaxChirpLength:      140,
+	CanEditChirps:       false,
+	RateLimitMultiplier: 1,
+	MaxPinnedChirps:     1,
+	MaxMediaAttachments: 0,
+}
+
+// Premium is what Chirpy Red members get.
+var Premium = Entitlements{
+	MaxChirpLength:      1000,
+	CanEditChirps:       true,
+	RateLimitMultiplier: 5,
+	MaxPinnedChirps:     5,
+	MaxMediaAttachments: 4,
+}
+
+// Resolve combines a user's Chirpy Red status with their granted features.
+func Resolve(chirpyRed bool, grants []Feature) Entitlements {
+	ent := Free
+
+	for _, grant := range grants {
+		if grant == ChirpyRed {
+			chirpyRed = true
+		}
+	}
+	if chirpyRed {
+		return Premium
+	}
+
+	for _, grant := range grants {
+		switch grant {
+		case LongChirps:
+			ent.MaxChirpLength = Premium.MaxChirpLength
+		case EditChirps:
+			ent.CanEditChirps = Premium.CanEditChirps
+		case HigherRateLimits:
+			ent.RateLimitMultiplier = Premium.RateLimitMultiplier
+		case ExtraPins:
+			ent.MaxPinnedChirps = Premium.MaxPinnedChirps
+		case MediaAttachments:
+			ent.MaxMediaAttachments = Premium.MaxMediaAttachments
+		}
+	}
+
+	return ent
+}
@@ -0,0 +1,48 @@
+package entitlements
+
+import "testing"
+
+func TestResolve(t *testing.T) {
+	editOnly := Free
+	editOnly.CanEditChirps = true
+
+	tests := []struct {
+		name      string
+		chirpyRed bool
+		grants    []Feature
+		want      Entitlements
+	}{
+		{
+			name: "Free user",
+			want: Free,
+		},
+		{
+			name:      "Chirpy Red member",
+			chirpyRed: true,
+			want:      Premium,
+		},
+		{
+			name:   "Chirpy Red granted by support",
+			grants: []Feature{ChirpyRed},
+			want:   Premium,
+		},
+		{
+			name:   "Single feature granted",
+			grants: []Feature{EditChirps},
+			want:   editOnly,
+		},
+		{
+			name:   "Unknown features are ignored",
+			grants: []Feature{"teleportation"},
+			want:   Free,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Resolve(tt.chirpyRed, tt.grants); got != tt.want {
+				t.Errorf("Resolve() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
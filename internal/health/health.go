@@ -0,0 +1,146 @@
+// Package health runs the dependency checks behind the readiness and
+// startup probes and tracks whether background workers are still making
+// progress.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Check statuses.
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Check reports a dependency as unhealthy by returning an error.
+type Check func(ctx context.Context) error
+
+// Result is the outcome of one check.
+type Result struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the outcome of every check. Its status is StatusOK only when
+// every check passed.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Checker runs a set of named checks concurrently, each with a timeout.
+type Checker struct {
+	timeout time.Duration
+	checks  map[string]Check
+}
+
+// NewChecker returns a Checker that fails checks taking longer than
+// timeout.
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout, checks: map[string]Check{}}
+}
+
+// Add registers check under name. It must not be called while the Checker
+// is serving.
+func (c *Checker) Add(name string, check Check) {
+	c.checks[name] = check
+}
+
+// Run runs every check and reports their outcomes.
+func (c *Checker) Run(ctx context.Context) Report {
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(c.checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range c.checks {
+		wg.Go(func() {
+			result := c.run(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		})
+	}
+	wg.Wait()
+
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := Result{
+		Status:    StatusOK,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// ServeHTTP runs the checks and writes the report, with status 200 if they
+// all passed and 503 otherwise.
+func (c *Checker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	report := c.Run(r.Context())
+
+	resp, err := json.Marshal(report)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status == StatusOK {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(resp)
+}
+
+// Heartbeat tracks a background worker's progress. The worker beats after
+// every run; the check fails if it hasn't beaten for longer than maxAge.
+type Heartbeat struct {
+	maxAge time.Duration
+	now    func() time.Time
+	last   atomic.Int64
+}
+
+// NewHeartbeat returns a Heartbeat that counts as fresh for maxAge from now,
+// giving the worker time to complete its first run.
+func NewHeartbeat(maxAge time.Duration) *Heartbeat {
+	h := &Heartbeat{maxAge: maxAge, now: time.Now}
+	h.Beat()
+	return h
+}
+
+// Beat records that the worker made progress.
+func (h *Heartbeat) Beat() {
+	h.last.Store(h.now().UnixNano())
+}
+
+// Check fails if the worker hasn't beaten within maxAge.
+func (h *Heartbeat) Check(ctx context.Context) error {
+	since := h.now().Sub(time.Unix(0, h.last.Load()))
+	if since > h.maxAge {
+		return fmt.Errorf("no progress for %s", since.Round(time.Second))
+	}
+	return nil
+}
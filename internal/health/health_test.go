@@ -0,0 +1,96 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestChecker(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New("connection refused") }
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	tests := []struct {
+		name       string
+		checks     map[string]Check
+		wantCode   int
+		wantStatus string
+		wantFailed []string
+	}{
+		{name: "No checks", checks: map[string]Check{}, wantCode: http.StatusOK, wantStatus: StatusOK},
+		{name: "All passing", checks: map[string]Check{"database": ok, "migrations": ok}, wantCode: http.StatusOK, wantStatus: StatusOK},
+		{name: "One failing", checks: map[string]Check{"database": failing, "migrations": ok}, wantCode: http.StatusServiceUnavailable, wantStatus: StatusFail, wantFailed: []string{"database"}},
+		{name: "Timed out", checks: map[string]Check{"database": slow}, wantCode: http.StatusServiceUnavailable, wantStatus: StatusFail, wantFailed: []string{"database"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewChecker(20 * time.Millisecond)
+			for name, check := range tt.checks {
+				checker.Add(name, check)
+			}
+
+			rec := httptest.NewRecorder()
+			checker.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("status code = %d, want %d", rec.Code, tt.wantCode)
+			}
+
+			var report Report
+			if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+				t.Fatalf("body isn't a report: %v", err)
+			}
+			if report.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", report.Status, tt.wantStatus)
+			}
+			if len(report.Checks) != len(tt.checks) {
+				t.Errorf("got %d checks, want %d", len(report.Checks), len(tt.checks))
+			}
+			for _, name := range tt.wantFailed {
+				if result := report.Checks[name]; result.Status != StatusFail || result.Error == "" {
+					t.Errorf("check %s = %+v, want a failure with an error", name, result)
+				}
+			}
+		})
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	tests := []struct {
+		name    string
+		beat    bool
+		advance time.Duration
+		wantErr bool
+	}{
+		{name: "Just started", advance: 0},
+		{name: "Within max age", advance: 59 * time.Second},
+		{name: "Stale", advance: 2 * time.Minute, wantErr: true},
+		{name: "Beat after stalling", advance: 2 * time.Minute, beat: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+			heartbeat := &Heartbeat{maxAge: time.Minute, now: func() time.Time { return now }}
+			heartbeat.Beat()
+
+			now = now.Add(tt.advance)
+			if tt.beat {
+				heartbeat.Beat()
+			}
+
+			if err := heartbeat.Check(context.Background()); (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		level   string
+		format  string
+		wantErr bool
+	}{
+		{name: "JSON at info", level: "info", format: "json"},
+		{name: "Text at debug", level: "DEBUG", format: "text"},
+		{name: "Level with offset", level: "warn+2", format: "json"},
+		{name: "Unknown level", level: "verbose", format: "json", wantErr: true},
+		{name: "Unknown format", level: "info", format: "xml", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(&bytes.Buffer{}, tt.level, tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRequestIDInLogs(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{name: "With request ID", ctx: WithRequestID(context.Background(), "req-123"), want: "req-123"},
+		{name: "Without request ID", ctx: context.Background(), want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger, err := New(&buf, "info", "json")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			logger.With("component", "test").InfoContext(tt.ctx, "hello")
+
+			var record map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+				t.Fatalf("log line isn't JSON: %v", err)
+			}
+			got, _ := record["request_id"].(string)
+			if got != tt.want {
+				t.Errorf("request_id = %q, want %q", got, tt.want)
+			}
+			if record["component"] != "test" {
+				t.Errorf("component = %v, want test", record["component"])
+			}
+		})
+	}
+}
+
+func TestLevelFiltering(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "warn", "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger.Info("dropped")
+	logger.Warn("kept")
+
+	if strings.Contains(buf.String(), "dropped") || !strings.Contains(buf.String(), "kept") {
+		t.Errorf("output = %q, want only the warning", buf.String())
+	}
+}
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		want bool
+	}{
+		{name: "UUID", id: NewRequestID(), want: true},
+		{name: "Opaque token", id: "abc-123_XYZ.9", want: true},
+		{name: "Empty", id: "", want: false},
+		{name: "Too long", id: strings.Repeat("a", 129), want: false},
+		{name: "Contains spaces", id: "a b", want: false},
+		{name: "Contains newline", id: "a\nfake=log", want: false},
+		{name: "Non-ASCII", id: "réq", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidRequestID(tt.id); got != tt.want {
+				t.Errorf("ValidRequestID(%q) = %v, want %v", tt.id, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTraceInLogs(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+
+	var buf bytes.Buffer
+	logger, err := New(&buf, "info", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.InfoContext(ctx, "hello")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("log line isn't JSON: %v", err)
+	}
+	if record["trace_id"] != traceID.String() || record["span_id"] != spanID.String() {
+		t.Errorf("trace_id, span_id = %v, %v, want %s, %s", record["trace_id"], record["span_id"], traceID, spanID)
+	}
+}
@@ -0,0 +1,129 @@
+// Package metrics exposes Chirpy's Prometheus metrics.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "chirpy"
+
+// Metrics holds every collector. Business counters are exported so
+// handlers can increment them directly.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests     *prometheus.CounterVec
+	httpDuration     *prometheus.HistogramVec
+	httpResponseSize *prometheus.HistogramVec
+
+	ChirpsCreated prometheus.Counter
+	Logins        prometheus.Counter
+	FailedLogins  prometheus.Counter
+	// WebhookEvents is labelled by provider and the processing status.
+	WebhookEvents *prometheus.CounterVec
+}
+
+// New registers Chirpy's collectors along with the Go runtime and process
+// collectors on a fresh registry.
+func New() *Metrics {
+	httpLabels := []string{"method", "route", "status"}
+
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route pattern and status code.",
+		}, httpLabels),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method, route pattern and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, httpLabels),
+		httpResponseSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_response_size_bytes",
+			Help:      "HTTP response body size by method, route pattern and status code.",
+			Buckets:   prometheus.ExponentialBuckets(64, 4, 8),
+		}, httpLabels),
+
+		ChirpsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "chirps_created_total",
+			Help:      "Chirps created.",
+		}),
+		Logins: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "logins_total",
+			Help:      "Successful password logins.",
+		}),
+		FailedLogins: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "failed_logins_total",
+			Help:      "Password logins rejected for an unknown email or wrong password.",
+		}),
+		WebhookEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "webhook_events_total",
+			Help:      "Incoming webhook events processed, by provider and outcome.",
+		}, []string{"provider", "status"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.httpResponseSize,
+		m.ChirpsCreated,
+		m.Logins,
+		m.FailedLogins,
+		m.WebhookEvents,
+	)
+
+	return m
+}
+
+// RegisterDB exposes the connection pool statistics of db.
+func (m *Metrics) RegisterDB(db *sql.DB, name string) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// ObserveRequest records one served HTTP request.
+func (m *Metrics) ObserveRequest(method, route string, status int, duration time.Duration, size int) {
+	labels := prometheus.Labels{
+		"method": methodLabel(method),
+		"route":  route,
+		"status": strconv.Itoa(status),
+	}
+
+	m.httpRequests.With(labels).Inc()
+	m.httpDuration.With(labels).Observe(duration.Seconds())
+	m.httpResponseSize.With(labels).Observe(float64(size))
+}
+
+// methodLabel is method if it is a standard HTTP method and "OTHER"
+// otherwise, so clients sending made-up methods can't blow up the label
+// cardinality.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler(t *testing.T) {
+	m := New()
+	m.ObserveRequest(http.MethodGet, "/api/chirps/{chirpID}", http.StatusOK, 20*time.Millisecond, 512)
+	m.ObserveRequest(http.MethodGet, "/api/chirps/{chirpID}", http.StatusNotFound, time.Millisecond, 30)
+	m.ObserveRequest("FROBNICATE", "unmatched", http.StatusNotFound, time.Millisecond, 19)
+	m.ObserveRequest("get", "unmatched", http.StatusNotFound, time.Millisecond, 19)
+	m.ChirpsCreated.Inc()
+	m.FailedLogins.Add(2)
+	m.WebhookEvents.WithLabelValues("polka", "processed").Inc()
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+
+	if contentType := rec.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want the Prometheus text format", contentType)
+	}
+
+	tests := []struct {
+		name string
+		line string
+	}{
+		{
+			name: "Request count by route and status",
+			line: `chirpy_http_requests_total{method="GET",route="/api/chirps/{chirpID}",status="200"} 1`,
+		},
+		{
+			name: "Latency histogram",
+			line: `chirpy_http_request_duration_seconds_count{method="GET",route="/api/chirps/{chirpID}",status="404"} 1`,
+		},
+		{
+			name: "Response size histogram",
+			line: `chirpy_http_response_size_bytes_sum{method="GET",route="/api/chirps/{chirpID}",status="200"} 512`,
+		},
+		{
+			name: "Non-standard methods share a label",
+			line: `chirpy_http_requests_total{method="OTHER",route="unmatched",status="404"} 2`,
+		},
+		{
+			name: "Chirps created",
+			line: `chirpy_chirps_created_total 1`,
+		},
+		{
+			name: "Failed logins",
+			line: `chirpy_failed_logins_total 2`,
+		},
+		{
+			name: "Webhook events",
+			line: `chirpy_webhook_events_total{provider="polka",status="processed"} 1`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(string(body), tt.line+"\n") {
+				t.Errorf("metrics output is missing %q", tt.line)
+			}
+		})
+	}
+}
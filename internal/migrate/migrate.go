@@ -0,0 +1,149 @@
+// Package migrate applies the goose migrations in sql/schema. Commands that
+// change the schema hold a Postgres advisory lock, so replicas migrating on
+// start don't race each other.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+// ErrSchemaTooNew means the database has migrations applied that this build
+// doesn't know about, so it may not work against it.
+var ErrSchemaTooNew = errors.New("database schema is newer than this build")
+
+// Migrator runs a set of migrations against a database.
+type Migrator struct {
+	db       *sql.DB
+	provider *goose.Provider
+	latest   int64
+}
+
+// New returns a Migrator for the .sql migrations at the root of fsys.
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	latest, err := Latest(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, err
+	}
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, fsys,
+		goose.WithSessionLocker(locker),
+		goose.WithDisableGlobalRegistry(true),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, provider: provider, latest: latest}, nil
+}
+
+// Latest returns the version of the newest migration in fsys, taken from
+// the numeric prefix of its file name.
+func Latest(fsys fs.FS) (int64, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return 0, err
+	}
+
+	var latest int64
+	for _, name := range names {
+		prefix, _, ok := strings.Cut(path.Base(name), "_")
+		if !ok {
+			return 0, fmt.Errorf("migration %s has no version prefix", name)
+		}
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("migration %s: %w", name, err)
+		}
+		latest = max(latest, version)
+	}
+	if latest == 0 {
+		return 0, goose.ErrNoMigrations
+	}
+	return latest, nil
+}
+
+// Latest returns the version the migrations bring the schema to.
+func (m *Migrator) Latest() int64 {
+	return m.latest
+}
+
+// Version returns the newest migration applied to the database: the highest
+// version whose latest goose_db_version row is an up migration. It is 0 when
+// nothing has been applied. Unlike the other methods it doesn't take the
+// lock, so it can be used by health checks while a migration runs.
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	var version int64
+	err := m.db.QueryRowContext(ctx, `
+SELECT COALESCE(MAX(version_id), 0) FROM (
+    SELECT DISTINCT ON (version_id) version_id, is_applied
+    FROM goose_db_version
+    ORDER BY version_id, id DESC
+) latest
+WHERE is_applied`).Scan(&version)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "42P01" { // undefined_table
+		return 0, nil
+	}
+	return version, err
+}
+
+// Check fails with ErrSchemaTooNew if the database has migrations applied
+// beyond Latest. A schema that is behind passes, since Up can fix that.
+func (m *Migrator) Check(ctx context.Context) error {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if version > m.latest {
+		return fmt.Errorf("%w: schema is at version %d, this build knows up to %d", ErrSchemaTooNew, version, m.latest)
+	}
+	return nil
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up(ctx context.Context) ([]*goose.MigrationResult, error) {
+	if err := m.Check(ctx); err != nil {
+		return nil, err
+	}
+	return m.provider.Up(ctx)
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) (*goose.MigrationResult, error) {
+	return m.provider.Down(ctx)
+}
+
+// Redo rolls back the most recently applied migration and applies it again.
+func (m *Migrator) Redo(ctx context.Context) ([]*goose.MigrationResult, error) {
+	down, err := m.provider.Down(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	up, err := m.provider.ApplyVersion(ctx, down.Source.Version, true)
+	if err != nil {
+		return []*goose.MigrationResult{down}, err
+	}
+	return []*goose.MigrationResult{down, up}, nil
+}
+
+// Status lists every migration and whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]*goose.MigrationStatus, error) {
+	return m.provider.Status(ctx)
+}
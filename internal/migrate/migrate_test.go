@@ -0,0 +1,69 @@
+package migrate
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/lib/pq"
+)
+
+func TestLatest(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string
+		want    int64
+		wantErr bool
+	}{
+		{name: "Ordered", files: []string{"001_users.sql", "002_chirp.sql"}, want: 2},
+		{name: "Gaps and padding", files: []string{"00001_users.sql", "010_analytics.sql", "007_oauth.sql"}, want: 10},
+		{name: "Ignores other files", files: []string{"001_users.sql", "README.md"}, want: 1},
+		{name: "No migrations", files: []string{"README.md"}, wantErr: true},
+		{name: "Missing version", files: []string{"users.sql"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{}
+			for _, name := range tt.files {
+				fsys[name] = &fstest.MapFile{Data: []byte("-- +goose Up\n")}
+			}
+
+			got, err := Latest(fsys)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Latest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Latest() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewCollectsSchema(t *testing.T) {
+	// sql.Open doesn't connect, so the migrations can be collected without
+	// a database.
+	db, err := sql.Open("postgres", "postgres://localhost/chirpy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	fsys := os.DirFS("../../sql/schema")
+	entries, err := os.ReadDir("../../sql/schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := New(db, fsys)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if got := m.Latest(); got != int64(len(entries)) {
+		t.Errorf("Latest() = %d, want one version per file (%d)", got, len(entries))
+	}
+	if sources := m.provider.ListSources(); len(sources) != len(entries) {
+		t.Errorf("collected %d migrations, want %d", len(sources), len(entries))
+	}
+}
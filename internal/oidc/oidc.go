@@ -0,0 +1,353 @@
+// Package oidc implements the OpenID Connect authorization code flow with
+// PKCE against an external identity provider.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Config describes a relying party registration with one provider.
+type Config struct {
+	Name         string
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Provider is a discovered OpenID Connect provider.
+type Provider struct {
+	config Config
+	client *http.Client
+
+	issuer                string
+	authorizationEndpoint string
+	tokenEndpoint         string
+	jwksURI               string
+
+	mu          sync.Mutex
+	keys        map[string]*rsa.PublicKey
+	keysFetched time.Time
+}
+
+// IDToken holds the verified claims Chirpy uses from an ID token.
+type IDToken struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// Discover fetches the provider's metadata from its well-known
+// configuration document.
+func Discover(ctx context.Context, config Config, client *http.Client) (*Provider, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	wellKnown := strings.TrimSuffix(config.IssuerURL, "/") + "/.well-known/openid-configuration"
+	var metadata struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	if err := getJSON(ctx, client, wellKnown, &metadata); err != nil {
+		return nil, fmt.Errorf("discovering %s: %w", config.Name, err)
+	}
+
+	if metadata.Issuer != config.IssuerURL {
+		return nil, fmt.Errorf("discovering %s: issuer %q does not match %q", config.Name, metadata.Issuer, config.IssuerURL)
+	}
+
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email"}
+	}
+
+	return &Provider{
+		config:                config,
+		client:                client,
+		issuer:                metadata.Issuer,
+		authorizationEndpoint: metadata.AuthorizationEndpoint,
+		tokenEndpoint:         metadata.TokenEndpoint,
+		jwksURI:               metadata.JWKSURI,
+	}, nil
+}
+
+// Lazy is a provider that is discovered when it is first needed. A failed
+// discovery is retried on a later use, at most once every retryAfter, so a
+// provider that was unreachable at startup isn't lost until a restart.
+type Lazy struct {
+	config     Config
+	client     *http.Client
+	retryAfter time.Duration
+
+	mu       sync.Mutex
+	provider *Provider
+	err      error
+	failedAt time.Time
+}
+
+// NewLazy returns a provider that is discovered on the first call to
+// Provider.
+func NewLazy(config Config, client *http.Client, retryAfter time.Duration) *Lazy {
+	return &Lazy{config: config, client: client, retryAfter: retryAfter}
+}
+
+func (l *Lazy) Name() string {
+	return l.config.Name
+}
+
+// Provider returns the discovered provider, discovering it first if that
+// hasn't succeeded yet. Within retryAfter of a failure it returns the same
+// error without trying again.
+func (l *Lazy) Provider(ctx context.Context) (*Provider, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.provider != nil {
+		return l.provider, nil
+	}
+	if l.err != nil && time.Since(l.failedAt) < l.retryAfter {
+		return nil, l.err
+	}
+
+	provider, err := Discover(ctx, l.config, l.client)
+	if err != nil {
+		// A caller that gave up says nothing about the provider.
+		if ctx.Err() == nil {
+			l.err, l.failedAt = err, time.Now()
+		}
+		return nil, err
+	}
+	l.provider, l.err = provider, nil
+	return provider, nil
+}
+
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+// AuthCodeURL returns the URL to send the user to. codeChallenge is the S256
+// challenge of the verifier later passed to Exchange.
+func (p *Provider) AuthCodeURL(state, nonce, codeChallenge string) string {
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(p.authorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return p.authorizationEndpoint + separator + query.Encode()
+}
+
+// Exchange redeems an authorization code and returns the raw ID token.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.tokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var tokenResponse struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
+		return "", fmt.Errorf("decoding token response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned %d: %s %s", resp.StatusCode, tokenResponse.Error, tokenResponse.ErrorDescription)
+	}
+	if tokenResponse.IDToken == "" {
+		return "", errors.New("token response has no id_token")
+	}
+
+	return tokenResponse.IDToken, nil
+}
+
+// VerifyIDToken checks the ID token's signature against the provider's JWKS
+// and validates its issuer, audience, expiry and nonce.
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (IDToken, error) {
+	claims := &idTokenClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithIssuer(p.issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return IDToken{}, err
+	}
+
+	if claims.Nonce != nonce {
+		return IDToken{}, errors.New("id token nonce does not match")
+	}
+
+	return IDToken{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+	}, nil
+}
+
+// key returns the signing key kid, refetching the JWKS when the provider
+// has rotated to a key we haven't seen.
+func (p *Provider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	// Unknown kids are attacker controlled; don't let them hammer the
+	// provider.
+	if time.Since(p.keysFetched) < time.Minute && p.keys != nil {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	keys, err := p.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+	p.keysFetched = time.Now()
+
+	key, ok := p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+func (p *Provider) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := getJSON(ctx, p.client, p.jwksURI, &jwks); err != nil {
+		return nil, fmt.Errorf("fetching jwks: %w", err)
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("jwk %q: invalid modulus: %w", jwk.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, fmt.Errorf("jwk %q: invalid exponent: %w", jwk.Kid, err)
+		}
+
+		keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	return keys, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// NewPKCE returns a random code verifier and its S256 code challenge.
+func NewPKCE() (verifier, challenge string, err error) {
+	verifier, err = randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	return verifier, S256Challenge(verifier), nil
+}
+
+// S256Challenge derives the PKCE S256 code challenge from a verifier.
+func S256Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,211 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ireoluwa12345/chirpy/internal/oidc/oidctest"
+)
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	user := oidctest.User{Subject: "user-123", Email: "walt@example.com", EmailVerified: true}
+	server := oidctest.NewServer("chirpy", "client-secret", user)
+	defer server.Close()
+
+	provider, err := Discover(context.Background(), Config{
+		Name:         "mock",
+		IssuerURL:    server.URL,
+		ClientID:     "chirpy",
+		ClientSecret: "client-secret",
+		RedirectURL:  "http://localhost:8080/api/auth/mock/callback",
+	}, server.Client())
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+
+	state, err := NewState("mock", uuid.Nil)
+	if err != nil {
+		t.Fatalf("NewState() error = %v", err)
+	}
+
+	code := authorize(t, server, provider.AuthCodeURL(state.State, state.Nonce, S256Challenge(state.CodeVerifier)), state.State)
+
+	tests := []struct {
+		name     string
+		code     string
+		verifier string
+		nonce    string
+		wantErr  bool
+	}{
+		{
+			name:     "Wrong PKCE verifier",
+			code:     code,
+			verifier: "not-the-verifier",
+			nonce:    state.Nonce,
+			wantErr:  true,
+		},
+		{
+			name:     "Valid code and verifier",
+			code:     authorize(t, server, provider.AuthCodeURL(state.State, state.Nonce, S256Challenge(state.CodeVerifier)), state.State),
+			verifier: state.CodeVerifier,
+			nonce:    state.Nonce,
+			wantErr:  false,
+		},
+		{
+			name:     "Wrong nonce",
+			code:     authorize(t, server, provider.AuthCodeURL(state.State, state.Nonce, S256Challenge(state.CodeVerifier)), state.State),
+			verifier: state.CodeVerifier,
+			nonce:    "other-nonce",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rawIDToken, err := provider.Exchange(context.Background(), tt.code, tt.verifier)
+			if err == nil {
+				var idToken IDToken
+				idToken, err = provider.VerifyIDToken(context.Background(), rawIDToken, tt.nonce)
+				if err == nil && idToken.Subject != user.Subject {
+					t.Errorf("VerifyIDToken() subject = %v, want %v", idToken.Subject, user.Subject)
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("flow error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenRejectsOtherAudience(t *testing.T) {
+	server := oidctest.NewServer("someone-else", "secret", oidctest.User{Subject: "user-123"})
+	defer server.Close()
+
+	provider, err := Discover(context.Background(), Config{Name: "mock", IssuerURL: server.URL, ClientID: "chirpy"}, server.Client())
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+
+	idToken, _ := server.SignIDToken(oidctest.User{Subject: "user-123"}, "nonce", time.Hour)
+	if _, err := provider.VerifyIDToken(context.Background(), idToken, "nonce"); err == nil {
+		t.Errorf("VerifyIDToken() accepted a token issued to another client")
+	}
+}
+
+// flakyTransport fails every request while down is set.
+type flakyTransport struct {
+	next http.RoundTripper
+	down atomic.Bool
+}
+
+func (f *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if f.down.Load() {
+		return nil, errors.New("connection refused")
+	}
+	return f.next.RoundTrip(req)
+}
+
+func TestLazyRetriesDiscovery(t *testing.T) {
+	server := oidctest.NewServer("chirpy", "client-secret", oidctest.User{Subject: "user-123"})
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		retryAfter time.Duration
+		wantErr    bool
+	}{
+		{name: "Retried after the delay", retryAfter: 0},
+		{name: "Not retried within the delay", retryAfter: time.Hour, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			transport := &flakyTransport{next: server.Client().Transport}
+			transport.down.Store(true)
+			lazy := NewLazy(Config{Name: "mock", IssuerURL: server.URL, ClientID: "chirpy"}, &http.Client{Transport: transport}, tc.retryAfter)
+
+			if _, err := lazy.Provider(context.Background()); err == nil {
+				t.Fatal("Provider() succeeded while the provider was down")
+			}
+
+			transport.down.Store(false)
+			provider, err := lazy.Provider(context.Background())
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Provider() after recovery error = %v, wantErr %t", err, tc.wantErr)
+			}
+			if err == nil && provider.Name() != "mock" {
+				t.Errorf("Name() = %q, want mock", provider.Name())
+			}
+		})
+	}
+}
+
+func TestSealState(t *testing.T) {
+	state, _ := NewState("mock", uuid.New())
+	sealed, _ := SealState(state, "secret", time.Minute)
+
+	tests := []struct {
+		name    string
+		sealed  string
+		secret  string
+		wantErr bool
+	}{
+		{
+			name:    "Valid state",
+			sealed:  sealed,
+			secret:  "secret",
+			wantErr: false,
+		},
+		{
+			name:    "Wrong secret",
+			sealed:  sealed,
+			secret:  "wrong_secret",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := OpenState(tt.sealed, tt.secret)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("OpenState() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got != state {
+				t.Errorf("OpenState() = %v, want %v", got, state)
+			}
+		})
+	}
+}
+
+// authorize follows the provider's authorization endpoint and returns the
+// code it redirects back with.
+func authorize(t *testing.T, server *oidctest.Server, authURL, wantState string) string {
+	t.Helper()
+
+	client := *server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("authorize: invalid redirect: %v", err)
+	}
+	if location.Query().Get("state") != wantState {
+		t.Fatalf("authorize: state = %q, want %q", location.Query().Get("state"), wantState)
+	}
+	return location.Query().Get("code")
+}
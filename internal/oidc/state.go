@@ -0,0 +1,87 @@
+package oidc
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const stateIssuer = "chirpy-oidc-state"
+
+// State is what Chirpy remembers between redirecting the user to the
+// provider and handling the callback. A login's is sealed into a short-lived
+// cookie.
+type State struct {
+	Provider     string `json:"provider"`
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	// LinkUserID is set when a logged-in user is linking a new identity
+	// rather than logging in.
+	LinkUserID uuid.UUID `json:"link_user_id,omitempty"`
+}
+
+type stateClaims struct {
+	jwt.RegisteredClaims
+	State
+}
+
+// NewState starts a flow with provider, generating the state, nonce and
+// PKCE verifier.
+func NewState(provider string, linkUserID uuid.UUID) (State, error) {
+	state, err := randomString(16)
+	if err != nil {
+		return State{}, err
+	}
+	nonce, err := randomString(16)
+	if err != nil {
+		return State{}, err
+	}
+	verifier, _, err := NewPKCE()
+	if err != nil {
+		return State{}, err
+	}
+
+	return State{
+		Provider:     provider,
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		LinkUserID:   linkUserID,
+	}, nil
+}
+
+// SealState signs state so it can be stored client side.
+func SealState(state State, secret string, expiresIn time.Duration) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, stateClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    stateIssuer,
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+		},
+		State: state,
+	})
+	return token.SignedString([]byte(secret))
+}
+
+// OpenState verifies and decodes a value produced by SealState.
+func OpenState(sealed, secret string) (State, error) {
+	claims := &stateClaims{}
+	_, err := jwt.ParseWithClaims(sealed, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	},
+		jwt.WithValidMethods([]string{"HS256"}),
+		jwt.WithIssuer(stateIssuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return State{}, err
+	}
+	if claims.State.State == "" {
+		return State{}, errors.New("empty oidc state")
+	}
+
+	return claims.State, nil
+}
@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// MemoryLimiter keeps buckets in process memory. Limits are not shared
+// between replicas.
+type MemoryLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+func (l *MemoryLimiter) Allow(ctx context.Context, key string, rule Rule) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	capacity := float64(rule.Limit)
+	key = rule.Name + ":" + key
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updatedAt: now}
+		l.buckets[key] = b
+	}
+
+	elapsed := now.Sub(b.updatedAt).Seconds()
+	b.tokens = min(capacity, b.tokens+elapsed*rule.refillPerSecond())
+	b.updatedAt = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+
+	return newResult(rule, b.tokens, allowed), nil
+}
+
+// Prune drops buckets that have not been touched since before. A bucket that
+// old has refilled completely, so forgetting it does not change any limit.
+func (l *MemoryLimiter) Prune(ctx context.Context, before time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for key, b := range l.buckets {
+		if b.updatedAt.Before(before) {
+			delete(l.buckets, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryLimiterAllow(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewMemoryLimiter()
+	limiter.now = func() time.Time { return now }
+
+	rule := Rule{Name: "test", Limit: 2, Period: 10 * time.Second}
+
+	tests := []struct {
+		name          string
+		advance       time.Duration
+		key           string
+		wantAllowed   bool
+		wantRemaining int
+		wantRetry     time.Duration
+	}{
+		{
+			name:          "First request",
+			key:           "a",
+			wantAllowed:   true,
+			wantRemaining: 1,
+		},
+		{
+			name:          "Second request uses the burst",
+			key:           "a",
+			wantAllowed:   true,
+			wantRemaining: 0,
+		},
+		{
+			name:          "Third request is limited",
+			key:           "a",
+			wantAllowed:   false,
+			wantRemaining: 0,
+			wantRetry:     5 * time.Second,
+		},
+		{
+			name:          "Other keys have their own bucket",
+			key:           "b",
+			wantAllowed:   true,
+			wantRemaining: 1,
+		},
+		{
+			name:          "Bucket refills over time",
+			advance:       5 * time.Second,
+			key:           "a",
+			wantAllowed:   true,
+			wantRemaining: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.advance)
+			res, err := limiter.Allow(context.Background(), tt.key, rule)
+			if err != nil {
+				t.Fatalf("Allow() error = %v", err)
+			}
+			if res.Allowed != tt.wantAllowed {
+				t.Errorf("Allow() allowed = %v, want %v", res.Allowed, tt.wantAllowed)
+			}
+			if res.Remaining != tt.wantRemaining {
+				t.Errorf("Allow() remaining = %v, want %v", res.Remaining, tt.wantRemaining)
+			}
+			if res.RetryAfter != tt.wantRetry {
+				t.Errorf("Allow() retryAfter = %v, want %v", res.RetryAfter, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestMemoryLimiterPrune(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewMemoryLimiter()
+	limiter.now = func() time.Time { return now }
+
+	rule := Rule{Name: "test", Limit: 1, Period: time.Minute}
+	limiter.Allow(context.Background(), "a", rule)
+
+	if err := limiter.Prune(context.Background(), now.Add(time.Second)); err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if len(limiter.buckets) != 0 {
+		t.Errorf("Prune() left %d buckets, want 0", len(limiter.buckets))
+	}
+}
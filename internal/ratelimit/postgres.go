@@ -0,0 +1,36 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/ireoluwa12345/chirpy/internal/database"
+)
+
+// PostgresLimiter keeps buckets in the rate_limit_buckets table so every
+// replica sees the same limits.
+type PostgresLimiter struct {
+	db *database.Queries
+}
+
+func NewPostgresLimiter(db *database.Queries) *PostgresLimiter {
+	return &PostgresLimiter{db: db}
+}
+
+func (l *PostgresLimiter) Allow(ctx context.Context, key string, rule Rule) (Result, error) {
+	row, err := l.db.TakeRateLimitToken(ctx, database.TakeRateLimitTokenParams{
+		Key:             rule.Name + ":" + key,
+		Capacity:        float64(rule.Limit),
+		RefillPerSecond: rule.refillPerSecond(),
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	return newResult(rule, row.Tokens, row.Allowed), nil
+}
+
+// Prune deletes buckets that have not been touched since before.
+func (l *PostgresLimiter) Prune(ctx context.Context, before time.Time) error {
+	return l.db.DeleteStaleRateLimitBuckets(ctx, before)
+}
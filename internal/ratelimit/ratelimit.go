@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rule describes a token bucket: Limit requests may be made in a burst and
+// the bucket refills at Limit tokens per Period.
+type Rule struct {
+	Name   string
+	Limit  int
+	Period time.Duration
+}
+
+func (r Rule) refillPerSecond() float64 {
+	return float64(r.Limit) / r.Period.Seconds()
+}
+
+// timeUntil returns how long it takes the bucket to refill from tokens up to
+// want tokens.
+func (r Rule) timeUntil(tokens, want float64) time.Duration {
+	if tokens >= want {
+		return 0
+	}
+	seconds := (want - tokens) / r.refillPerSecond()
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
+}
+
+// Result is the outcome of taking a token from a bucket.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next request would be allowed. It is
+	// zero when the request was allowed.
+	RetryAfter time.Duration
+}
+
+func newResult(rule Rule, tokens float64, allowed bool) Result {
+	res := Result{
+		Allowed:   allowed,
+		Limit:     rule.Limit,
+		Remaining: max(int(math.Floor(tokens)), 0),
+		Reset:     rule.timeUntil(tokens, float64(rule.Limit)),
+	}
+	if !allowed {
+		res.RetryAfter = rule.timeUntil(tokens, 1)
+	}
+	return res
+}
+
+// Limiter takes tokens from the bucket identified by key under rule.
+type Limiter interface {
+	Allow(ctx context.Context, key string, rule Rule) (Result, error)
+	// Prune forgets buckets that have not been used since before.
+	Prune(ctx context.Context, before time.Time) error
+}
+
+// ParseRule parses a limit written as "<requests>/<period>", such as "5/1m".
+func ParseRule(name, s string) (Rule, error) {
+	limitString, periodString, ok := strings.Cut(s, "/")
+	if !ok {
+		return Rule{}, fmt.Errorf("invalid rate limit %q: expected <requests>/<period>", s)
+	}
+
+	limit, err := strconv.Atoi(limitString)
+	if err != nil || limit < 1 {
+		return Rule{}, fmt.Errorf("invalid rate limit %q: requests must be a positive integer", s)
+	}
+
+	period, err := time.ParseDuration(periodString)
+	if err != nil || period <= 0 {
+		return Rule{}, fmt.Errorf("invalid rate limit %q: period must be a positive duration", s)
+	}
+
+	return Rule{Name: name, Limit: limit, Period: period}, nil
+}
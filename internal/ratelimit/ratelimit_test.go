@@ -0,0 +1,50 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantRule Rule
+		wantErr  bool
+	}{
+		{
+			name:     "Valid rule",
+			input:    "5/1m",
+			wantRule: Rule{Name: "login", Limit: 5, Period: time.Minute},
+			wantErr:  false,
+		},
+		{
+			name:    "Missing period",
+			input:   "5",
+			wantErr: true,
+		},
+		{
+			name:    "Zero requests",
+			input:   "0/1m",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid period",
+			input:   "5/often",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRule("login", tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseRule() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if rule != tt.wantRule {
+				t.Errorf("ParseRule() rule = %v, want %v", rule, tt.wantRule)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/ireoluwa12345/chirpy/internal/database"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DB wraps a database.DBTX so every query runs in a span named after the
+// sqlc query. Queries are only traced inside an existing span, so
+// background work that isn't traced doesn't produce a trace per query.
+//
+// Spans cover executing the query; scanning its rows happens after the span
+// ends.
+type DB struct {
+	db database.DBTX
+}
+
+// WrapDB traces queries run through db.
+func WrapDB(db database.DBTX) *DB {
+	return &DB{db: db}
+}
+
+func (d *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span, ok := startQuerySpan(ctx, query)
+	if !ok {
+		return d.db.ExecContext(ctx, query, args...)
+	}
+	defer span.End()
+
+	result, err := d.db.ExecContext(ctx, query, args...)
+	recordError(span, err)
+	return result, err
+}
+
+func (d *DB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	ctx, span, ok := startQuerySpan(ctx, query)
+	if !ok {
+		return d.db.PrepareContext(ctx, query)
+	}
+	defer span.End()
+
+	stmt, err := d.db.PrepareContext(ctx, query)
+	recordError(span, err)
+	return stmt, err
+}
+
+func (d *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span, ok := startQuerySpan(ctx, query)
+	if !ok {
+		return d.db.QueryContext(ctx, query, args...)
+	}
+	defer span.End()
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	recordError(span, err)
+	return rows, err
+}
+
+func (d *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span, ok := startQuerySpan(ctx, query)
+	if !ok {
+		return d.db.QueryRowContext(ctx, query, args...)
+	}
+	defer span.End()
+
+	row := d.db.QueryRowContext(ctx, query, args...)
+	recordError(span, row.Err())
+	return row
+}
+
+func startQuerySpan(ctx context.Context, query string) (context.Context, trace.Span, bool) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, nil, false
+	}
+
+	name := QueryName(query)
+	ctx, span := Tracer().Start(ctx, "db "+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system.name", "postgresql"),
+			attribute.String("db.operation.name", name),
+		),
+	)
+	return ctx, span, true
+}
+
+func recordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// QueryName returns the name sqlc gives a query in the "-- name: GetUser
+// :one" comment it starts with, or "query" for SQL not written by sqlc.
+func QueryName(query string) string {
+	comment, _, _ := strings.Cut(query, "\n")
+	rest, ok := strings.CutPrefix(comment, "-- name: ")
+	if !ok {
+		return "query"
+	}
+	name, _, _ := strings.Cut(rest, " ")
+	return name
+}
@@ -0,0 +1,114 @@
+package tracing
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// fileClient writes each batch of spans to a file as one line of OTLP/JSON,
+// the format of the OpenTelemetry Collector's file exporter, so traces can
+// be collected without running a collector.
+type fileClient struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+func newFileClient(path string) *fileClient {
+	return &fileClient{path: path}
+}
+
+func (c *fileClient) Start(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	file, err := os.OpenFile(c.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	c.file = file
+	return nil
+}
+
+func (c *fileClient) Stop(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return nil
+	}
+	err := c.file.Close()
+	c.file = nil
+	return err
+}
+
+func (c *fileClient) UploadTraces(ctx context.Context, protoSpans []*tracepb.ResourceSpans) error {
+	line, err := encodeResourceSpans(protoSpans)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return errors.New("otlp file exporter is stopped")
+	}
+	_, err = c.file.Write(append(line, '\n'))
+	return err
+}
+
+// encodeResourceSpans encodes spans as an OTLP/JSON ExportTraceServiceRequest.
+func encodeResourceSpans(protoSpans []*tracepb.ResourceSpans) ([]byte, error) {
+	opts := protojson.MarshalOptions{UseEnumNumbers: true}
+
+	resourceSpans := make([]any, 0, len(protoSpans))
+	for _, rs := range protoSpans {
+		b, err := opts.Marshal(rs)
+		if err != nil {
+			return nil, err
+		}
+
+		var v any
+		if err := json.Unmarshal(b, &v); err != nil {
+			return nil, err
+		}
+		resourceSpans = append(resourceSpans, hexIDs(v))
+	}
+
+	return json.Marshal(map[string]any{"resourceSpans": resourceSpans})
+}
+
+// hexIDs rewrites trace and span IDs, which protojson encodes as base64, as
+// the hex strings OTLP/JSON requires.
+func hexIDs(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			switch key {
+			case "traceId", "spanId", "parentSpanId":
+				if s, ok := value.(string); ok {
+					if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+						v[key] = hex.EncodeToString(b)
+						continue
+					}
+				}
+			}
+			v[key] = hexIDs(value)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = hexIDs(value)
+		}
+	}
+	return v
+}
@@ -0,0 +1,122 @@
+// Package tracing sets up OpenTelemetry tracing: the tracer provider and
+// exporter, W3C trace-context propagation, and spans around database
+// queries.
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Name identifies chirpy's instrumentation to the tracer provider.
+const Name = "github.com/ireoluwa12345/chirpy"
+
+// Exporters.
+const (
+	ExporterNone     = "none"
+	ExporterStdout   = "stdout"
+	ExporterStderr   = "stderr"
+	ExporterOTLPFile = "otlp-file"
+)
+
+// Config selects where spans are exported.
+type Config struct {
+	// Exporter is ExporterNone, ExporterStdout, ExporterStderr or
+	// ExporterOTLPFile. Spans written to stdout are interleaved with
+	// anything else written there, such as logs.
+	Exporter string
+	// Path is the file ExporterOTLPFile appends to.
+	Path        string
+	ServiceName string
+	// SampleRatio is the fraction of new traces recorded. Traces started
+	// by a caller follow the caller's sampling decision.
+	SampleRatio float64
+}
+
+// Setup installs the global tracer provider and W3C trace-context
+// propagator. The returned function flushes buffered spans and stops the
+// exporter.
+//
+// With ExporterNone no spans are recorded, but trace context received from
+// callers is still propagated to outbound requests.
+func Setup(ctx context.Context, cfg Config) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	switch cfg.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterStderr:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stderr))
+	case ExporterOTLPFile:
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("the %s exporter needs a file path", ExporterOTLPFile)
+		}
+		exporter, err = otlptrace.New(ctx, newFileClient(cfg.Path))
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", cfg.ServiceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Tracer returns chirpy's tracer from the global provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(Name)
+}
+
+// MarshalContext encodes the trace context of ctx, so work done later, such
+// as a queued webhook delivery, can continue the trace.
+func MarshalContext(ctx context.Context) json.RawMessage {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+
+	b, err := json.Marshal(carrier)
+	if err != nil {
+		return json.RawMessage(`{}`)
+	}
+	return b
+}
+
+// UnmarshalContext returns ctx carrying the trace context encoded by
+// MarshalContext. Malformed input leaves ctx unchanged.
+func UnmarshalContext(ctx context.Context, data json.RawMessage) context.Context {
+	carrier := propagation.MapCarrier{}
+	if err := json.Unmarshal(data, &carrier); err != nil {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
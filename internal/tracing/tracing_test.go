@@ -0,0 +1,144 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestQueryName(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{name: "sqlc query", query: "-- name: GetUserByID :one\nSELECT * FROM users WHERE id = $1\n", want: "GetUserByID"},
+		{name: "sqlc query on one line", query: "-- name: DeleteUsers :exec", want: "DeleteUsers"},
+		{name: "Plain SQL", query: "SELECT 1", want: "query"},
+		{name: "Other comment", query: "-- goose up\nSELECT 1", want: "query"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := QueryName(tt.query); got != tt.want {
+				t.Errorf("QueryName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "Disabled", cfg: Config{Exporter: ExporterNone}},
+		{name: "Default is disabled", cfg: Config{}},
+		{name: "Stdout", cfg: Config{Exporter: ExporterStdout, ServiceName: "chirpy", SampleRatio: 1}},
+		{name: "Stderr", cfg: Config{Exporter: ExporterStderr, ServiceName: "chirpy", SampleRatio: 1}},
+		{name: "OTLP file without a path", cfg: Config{Exporter: ExporterOTLPFile}, wantErr: true},
+		{name: "Unknown exporter", cfg: Config{Exporter: "jaeger"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shutdown, err := Setup(context.Background(), tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Setup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				shutdown(context.Background())
+			}
+		})
+	}
+}
+
+func TestOTLPFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	shutdown, err := Setup(context.Background(), Config{
+		Exporter:    ExporterOTLPFile,
+		Path:        path,
+		ServiceName: "chirpy",
+		SampleRatio: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, span := Tracer().Start(context.Background(), "GET /api/chirps")
+	traceID := span.SpanContext().TraceID().String()
+	spanID := span.SpanContext().SpanID().String()
+	span.End()
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var request struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID string `json:"traceId"`
+					SpanID  string `json:"spanId"`
+					Name    string `json:"name"`
+					Kind    int    `json:"kind"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.Unmarshal(data, &request); err != nil {
+		t.Fatalf("exported line isn't JSON: %v", err)
+	}
+	if len(request.ResourceSpans) != 1 || len(request.ResourceSpans[0].ScopeSpans) != 1 || len(request.ResourceSpans[0].ScopeSpans[0].Spans) != 1 {
+		t.Fatalf("exported %s, want one span", data)
+	}
+
+	got := request.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	if got.Name != "GET /api/chirps" || got.TraceID != traceID || got.SpanID != spanID {
+		t.Errorf("exported span %+v, want %s with trace %s and span %s", got, "GET /api/chirps", traceID, spanID)
+	}
+}
+
+func TestMarshalContext(t *testing.T) {
+	if _, err := Setup(context.Background(), Config{}); err != nil {
+		t.Fatal(err)
+	}
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	want := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+
+	tests := []struct {
+		name string
+		data json.RawMessage
+		want trace.SpanContext
+	}{
+		{name: "Round trip", data: MarshalContext(trace.ContextWithRemoteSpanContext(context.Background(), want)), want: want},
+		{name: "No trace", data: MarshalContext(context.Background()), want: trace.SpanContext{}},
+		{name: "Malformed", data: json.RawMessage(`"not an object"`), want: trace.SpanContext{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := trace.SpanContextFromContext(UnmarshalContext(context.Background(), tt.data))
+			if !got.Equal(tt.want) {
+				t.Errorf("UnmarshalContext() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+// Package views counts chirp views. A viewer is counted at most once per
+// chirp within a window, and counts are batched in memory and flushed
+// periodically so serving a chirp never writes to the database.
+//
+// Deduplication is per process: with several replicas a viewer may be
+// counted once by each replica within the same window.
+package views
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ireoluwa12345/chirpy/internal/database"
+)
+
+// Store adds views to a chirp's totals on day.
+type Store interface {
+	AddViews(ctx context.Context, chirpID uuid.UUID, day time.Time, views int64) error
+}
+
+type seenKey struct {
+	chirpID uuid.UUID
+	viewer  string
+}
+
+type seenEntry struct {
+	key seenKey
+	at  time.Time
+}
+
+type pendingKey struct {
+	chirpID uuid.UUID
+	day     time.Time
+}
+
+// Counter deduplicates and batches views.
+type Counter struct {
+	store  Store
+	window time.Duration
+	now    func() time.Time
+
+	mu sync.Mutex
+	// seen holds the counted views still within the window, oldest first,
+	// and seenIndex finds them. At most maxEntries are kept.
+	seen       *list.List
+	seenIndex  map[seenKey]*list.Element
+	maxEntries int
+	// pending holds views not flushed yet, for at most maxEntries chirp
+	// days. Views that don't fit are counted in dropped.
+	pending map[pendingKey]int64
+	dropped int64
+}
+
+// NewCounter counts each viewer once per chirp per window. It remembers at
+// most maxEntries views to deduplicate against, forgetting the oldest
+// first, so a flood of viewers can't exhaust memory; a forgotten viewer may
+// be counted again within the window. Likewise it keeps views pending for
+// at most maxEntries chirp days, so flushes failing for a long time drop
+// new views rather than grow without bound.
+func NewCounter(store Store, window time.Duration, maxEntries int) *Counter {
+	return &Counter{
+		store:      store,
+		window:     window,
+		now:        time.Now,
+		seen:       list.New(),
+		seenIndex:  map[seenKey]*list.Element{},
+		maxEntries: maxEntries,
+		pending:    map[pendingKey]int64{},
+	}
+}
+
+// Record counts a view of chirpID by viewer, which identifies a user or
+// client IP, unless the same viewer was counted within the window.
+func (c *Counter) Record(chirpID uuid.UUID, viewer string) {
+	now := c.now()
+	key := seenKey{chirpID: chirpID, viewer: viewer}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.seenIndex[key]; ok {
+		if now.Sub(elem.Value.(*seenEntry).at) < c.window {
+			return
+		}
+		c.seen.Remove(elem)
+	}
+	c.seenIndex[key] = c.seen.PushBack(&seenEntry{key: key, at: now})
+	for c.seen.Len() > c.maxEntries {
+		c.forget(c.seen.Front())
+	}
+	c.addPending(pendingKey{chirpID: chirpID, day: truncateDay(now)}, 1)
+}
+
+// addPending adds views to key's pending count, or drops them when
+// maxEntries other chirp days are already pending. c.mu must be held.
+func (c *Counter) addPending(key pendingKey, views int64) {
+	if _, ok := c.pending[key]; !ok && len(c.pending) >= c.maxEntries {
+		c.dropped += views
+		return
+	}
+	c.pending[key] += views
+}
+
+func (c *Counter) forget(elem *list.Element) {
+	c.seen.Remove(elem)
+	delete(c.seenIndex, elem.Value.(*seenEntry).key)
+}
+
+// Pending returns the views of chirpID not flushed yet.
+func (c *Counter) Pending(chirpID uuid.UUID) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var views int64
+	for key, n := range c.pending {
+		if key.chirpID == chirpID {
+			views += n
+		}
+	}
+	return views
+}
+
+// Flush writes pending views to the store and forgets viewers whose window
+// has passed. Views that could not be written stay pending, as far as they
+// fit; it reports the views dropped since the last flush as an error.
+func (c *Counter) Flush(ctx context.Context) error {
+	now := c.now()
+
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = map[pendingKey]int64{}
+	dropped := c.dropped
+	c.dropped = 0
+	for elem := c.seen.Front(); elem != nil; elem = c.seen.Front() {
+		if now.Sub(elem.Value.(*seenEntry).at) < c.window {
+			break
+		}
+		c.forget(elem)
+	}
+	c.mu.Unlock()
+
+	var errs []error
+	if dropped > 0 {
+		errs = append(errs, fmt.Errorf("dropped %d views: %d chirp days already pending", dropped, c.maxEntries))
+	}
+	failed := map[pendingKey]int64{}
+	for key, views := range pending {
+		if err := c.store.AddViews(ctx, key.chirpID, key.day, views); err != nil {
+			errs = append(errs, err)
+			failed[key] = views
+		}
+	}
+
+	if len(failed) > 0 {
+		c.mu.Lock()
+		for key, views := range failed {
+			c.addPending(key, views)
+		}
+		c.mu.Unlock()
+	}
+
+	return errors.Join(errs...)
+}
+
+// Run flushes every interval until ctx is cancelled, then flushes once more.
+func (c *Counter) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := c.Flush(flushCtx); err != nil {
+				onError(err)
+			}
+			return
+		case <-ticker.C:
+			if err := c.Flush(ctx); err != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+func truncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// PostgresStore adds views to chirps.view_count and chirp_daily_views.
+type PostgresStore struct {
+	db *database.Queries
+}
+
+func NewPostgresStore(db *database.Queries) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) AddViews(ctx context.Context, chirpID uuid.UUID, day time.Time, views int64) error {
+	return s.db.AddChirpViews(ctx, database.AddChirpViewsParams{
+		Views:   views,
+		ChirpID: chirpID,
+		Day:     day,
+	})
+}
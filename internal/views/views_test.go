@@ -0,0 +1,150 @@
+package views
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type fakeStore struct {
+	views map[pendingKey]int64
+	err   error
+}
+
+func (s *fakeStore) AddViews(ctx context.Context, chirpID uuid.UUID, day time.Time, views int64) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.views[pendingKey{chirpID: chirpID, day: day}] += views
+	return nil
+}
+
+func TestCounter(t *testing.T) {
+	start := time.Date(2025, 1, 1, 23, 50, 0, 0, time.UTC)
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	chirpA := uuid.MustParse("0b5e3c4f-6a3e-4f0e-9a53-0f9f3f4f5e0a")
+	chirpB := uuid.MustParse("3311741c-680c-4546-99f3-fc9efac2036c")
+
+	tests := []struct {
+		name    string
+		advance time.Duration
+		chirpID uuid.UUID
+		viewer  string
+	}{
+		{name: "First view", chirpID: chirpA, viewer: "user:alice"},
+		{name: "Repeat view within the window", advance: 5 * time.Minute, chirpID: chirpA, viewer: "user:alice"},
+		{name: "Another viewer", chirpID: chirpA, viewer: "ip:203.0.113.7"},
+		{name: "Same viewer, another chirp", chirpID: chirpB, viewer: "user:alice"},
+		{name: "Repeat view after the window, on the next day", advance: 30 * time.Minute, chirpID: chirpA, viewer: "user:alice"},
+	}
+
+	store := &fakeStore{views: map[pendingKey]int64{}}
+	counter := NewCounter(store, 30*time.Minute, 100)
+	now := start
+	counter.now = func() time.Time { return now }
+
+	for _, tt := range tests {
+		now = now.Add(tt.advance)
+		counter.Record(tt.chirpID, tt.viewer)
+	}
+
+	if got := counter.Pending(chirpA); got != 3 {
+		t.Errorf("Pending(chirpA) = %d, want 3", got)
+	}
+
+	store.err = errors.New("database unavailable")
+	if err := counter.Flush(context.Background()); err == nil {
+		t.Fatal("Flush() error = nil, want the store error")
+	}
+	store.err = nil
+	if err := counter.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	want := map[pendingKey]int64{
+		{chirpID: chirpA, day: day}:                     2,
+		{chirpID: chirpA, day: day.Add(24 * time.Hour)}: 1,
+		{chirpID: chirpB, day: day}:                     1,
+	}
+	for key, views := range want {
+		if got := store.views[key]; got != views {
+			t.Errorf("views of %s on %s = %d, want %d", key.chirpID, key.day.Format(time.DateOnly), got, views)
+		}
+	}
+
+	if got := counter.Pending(chirpA); got != 0 {
+		t.Errorf("Pending(chirpA) after Flush() = %d, want 0", got)
+	}
+}
+
+func TestCounterForgetsOldestViews(t *testing.T) {
+	chirpID := uuid.MustParse("0b5e3c4f-6a3e-4f0e-9a53-0f9f3f4f5e0a")
+	counter := NewCounter(&fakeStore{views: map[pendingKey]int64{}}, 30*time.Minute, 2)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	counter.now = func() time.Time { return now }
+
+	for _, viewer := range []string{"user:alice", "user:bob", "user:carol"} {
+		now = now.Add(time.Minute)
+		counter.Record(chirpID, viewer)
+	}
+	if got := len(counter.seenIndex); got != 2 {
+		t.Fatalf("remembered %d views, want 2", got)
+	}
+
+	// Alice was forgotten to make room for Carol, so she is counted again;
+	// Carol is still remembered.
+	counter.Record(chirpID, "user:alice")
+	counter.Record(chirpID, "user:carol")
+	if got := counter.Pending(chirpID); got != 4 {
+		t.Errorf("Pending() = %d, want 4", got)
+	}
+
+	now = now.Add(30 * time.Minute)
+	if err := counter.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := counter.seen.Len(); got != 0 {
+		t.Errorf("remembered %d views after the window, want 0", got)
+	}
+}
+
+func TestCounterCapsPendingViews(t *testing.T) {
+	store := &fakeStore{views: map[pendingKey]int64{}, err: errors.New("database unavailable")}
+	counter := NewCounter(store, 30*time.Minute, 2)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	counter.now = func() time.Time { return now }
+	chirps := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+
+	// While the store is down, views of a third chirp don't fit.
+	for _, chirpID := range chirps {
+		counter.Record(chirpID, "user:alice")
+	}
+	if err := counter.Flush(context.Background()); err == nil || !strings.Contains(err.Error(), "dropped 1 views") {
+		t.Fatalf("Flush() error = %v, want the dropped views reported", err)
+	}
+	// Views of chirps already pending still count.
+	counter.Record(chirps[0], "user:bob")
+	counter.Record(chirps[2], "user:bob")
+	if got := len(counter.pending); got != 2 {
+		t.Errorf("%d chirp days pending, want 2", got)
+	}
+
+	store.err = nil
+	if err := counter.Flush(context.Background()); err == nil {
+		t.Fatal("Flush() error = nil, want the second dropped view reported")
+	}
+	var total int64
+	for _, views := range store.views {
+		total += views
+	}
+	if total != 3 || store.views[pendingKey{chirpID: chirps[0], day: truncateDay(now)}] != 2 {
+		t.Errorf("stored views = %v, want 2 of the first chirp and 1 of the second", store.views)
+	}
+	if err := counter.Flush(context.Background()); err != nil {
+		t.Errorf("Flush() with nothing dropped error = %v", err)
+	}
+}
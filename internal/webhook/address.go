@@ -0,0 +1,89 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"syscall"
+)
+
+var (
+	ErrInvalidURL     = errors.New("webhook URL must be an absolute https URL without credentials")
+	ErrBlockedAddress = errors.New("webhook receiver address is not public")
+)
+
+// Resolver looks up a host's addresses. *net.Resolver implements it.
+type Resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// Ranges that are neither private nor loopback by the netip predicates but
+// still aren't public hosts.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+}
+
+// publicAddr reports whether ip may receive webhooks. Deliveries to
+// loopback, private, link-local and unspecified addresses would let users
+// make the server call its own network.
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckURL validates a receiver URL when an endpoint is registered: it must
+// be https without credentials, and its host must resolve only to public
+// addresses. Send checks the dialed address again, since DNS can change.
+func CheckURL(ctx context.Context, resolver Resolver, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" || u.User != nil {
+		return ErrInvalidURL
+	}
+
+	host := u.Hostname()
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if !publicAddr(ip) {
+			return fmt.Errorf("%w: %s", ErrBlockedAddress, ip)
+		}
+		return nil
+	}
+
+	addrs, err := resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("resolving webhook host %q: %w", host, err)
+	}
+	for _, ip := range addrs {
+		if !publicAddr(ip) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrBlockedAddress, host, ip)
+		}
+	}
+	return nil
+}
+
+// dialControl refuses connections to non-public addresses. It runs after
+// DNS resolution, so a host that re-resolves to an internal address after
+// CheckURL is still blocked.
+func dialControl(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !publicAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, addrPort.Addr())
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+type fakeResolver map[string][]netip.Addr
+
+func (r fakeResolver) LookupNetIP(_ context.Context, _, host string) ([]netip.Addr, error) {
+	addrs, ok := r[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return addrs, nil
+}
+
+func TestCheckURL(t *testing.T) {
+	resolver := fakeResolver{
+		"hooks.example.com":    {netip.MustParseAddr("93.184.216.34")},
+		"internal.example.com": {netip.MustParseAddr("10.1.2.3")},
+		"mixed.example.com":    {netip.MustParseAddr("93.184.216.34"), netip.MustParseAddr("127.0.0.1")},
+	}
+
+	tests := []struct {
+		name    string
+		url     string
+		wantErr error
+	}{
+		{name: "Public IP", url: "https://93.184.216.34/hook"},
+		{name: "Public host", url: "https://hooks.example.com/hook"},
+		{name: "Public IPv6", url: "https://[2606:2800:220:1::1]/hook"},
+		{name: "Plain http", url: "http://hooks.example.com/hook", wantErr: ErrInvalidURL},
+		{name: "Credentials", url: "https://user:pw@hooks.example.com/hook", wantErr: ErrInvalidURL},
+		{name: "Relative", url: "/hook", wantErr: ErrInvalidURL},
+		{name: "IPv4 loopback", url: "https://127.0.0.1/", wantErr: ErrBlockedAddress},
+		{name: "IPv6 loopback", url: "https://[::1]/", wantErr: ErrBlockedAddress},
+		{name: "Cloud metadata", url: "https://169.254.169.254/latest/meta-data", wantErr: ErrBlockedAddress},
+		{name: "IPv6 link-local", url: "https://[fe80::1]/", wantErr: ErrBlockedAddress},
+		{name: "10/8", url: "https://10.0.0.1/", wantErr: ErrBlockedAddress},
+		{name: "172.16/12", url: "https://172.16.5.4/", wantErr: ErrBlockedAddress},
+		{name: "192.168/16", url: "https://192.168.1.1/", wantErr: ErrBlockedAddress},
+		{name: "IPv6 unique local", url: "https://[fd00::1]/", wantErr: ErrBlockedAddress},
+		{name: "Unspecified", url: "https://0.0.0.0/", wantErr: ErrBlockedAddress},
+		{name: "IPv6 unspecified", url: "https://[::]/", wantErr: ErrBlockedAddress},
+		{name: "IPv4-mapped loopback", url: "https://[::ffff:127.0.0.1]/", wantErr: ErrBlockedAddress},
+		{name: "Carrier-grade NAT", url: "https://100.64.0.1/", wantErr: ErrBlockedAddress},
+		{name: "Host resolving to private", url: "https://internal.example.com/", wantErr: ErrBlockedAddress},
+		{name: "Host with one private address", url: "https://mixed.example.com/", wantErr: ErrBlockedAddress},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckURL(context.Background(), resolver, tt.url)
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("CheckURL(%q) = %v, want nil", tt.url, err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckURL(%q) = %v, want %v", tt.url, err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("Unresolvable host", func(t *testing.T) {
+		if err := CheckURL(context.Background(), resolver, "https://nowhere.example.com/"); err == nil {
+			t.Error("CheckURL() = nil, want an error")
+		}
+	})
+}
+
+func TestDialControl(t *testing.T) {
+	tests := []struct {
+		address string
+		wantErr bool
+	}{
+		{address: "93.184.216.34:443"},
+		{address: "127.0.0.1:443", wantErr: true},
+		{address: "169.254.169.254:80", wantErr: true},
+		{address: "10.0.0.1:443", wantErr: true},
+		{address: "[::1]:443", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			err := dialControl("tcp", tt.address, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("dialControl(%q) = %v, wantErr %v", tt.address, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Headers set on outbound deliveries.
+const (
+	SignatureHeader = "X-Chirpy-Signature"
+	EventIDHeader   = "X-Chirpy-Event-Id"
+	EventTypeHeader = "X-Chirpy-Event-Type"
+)
+
+// MaxAttempts is how many times a delivery is tried before it is given up.
+const MaxAttempts = 10
+
+const (
+	initialBackoff = 30 * time.Second
+	maxBackoff     = 6 * time.Hour
+)
+
+// Backoff returns how long to wait before retrying a delivery that has
+// failed attempts times: 30s, 1m, 2m, ... capped at six hours.
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		return 0
+	}
+
+	d := initialBackoff
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return d
+}
+
+// StatusError is returned by Send when the receiver answered with a non-2xx
+// status.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("webhook receiver responded with status %d", e.StatusCode)
+}
+
+// Sender delivers signed events over HTTP.
+type Sender struct {
+	Client *http.Client
+	now    func() time.Time
+}
+
+// NewSender returns a Sender whose requests time out after timeout. It only
+// connects to public addresses and doesn't follow redirects, so receivers
+// can't point deliveries at the server's own network.
+func NewSender(timeout time.Duration) *Sender {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would dial on our behalf, out of reach of the address check.
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{Timeout: timeout, Control: dialControl}).DialContext
+
+	return &Sender{
+		Client: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		now: time.Now,
+	}
+}
+
+// Send POSTs body to url signed with secret, along with the W3C trace
+// context of ctx. It returns the receiver's status code, and a *StatusError
+// if that wasn't 2xx.
+func (s *Sender) Send(ctx context.Context, url, secret, eventID, eventType string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set(SignatureHeader, Sign(secret, s.now(), body))
+	req.Header.Set(EventIDHeader, eventID)
+	req.Header.Set(EventTypeHeader, eventType)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, &StatusError{StatusCode: resp.StatusCode}
+	}
+	return resp.StatusCode, nil
+}
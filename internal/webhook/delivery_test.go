@@ -0,0 +1,160 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int
+		want     time.Duration
+	}{
+		{name: "Not attempted yet", attempts: 0, want: 0},
+		{name: "First failure", attempts: 1, want: 30 * time.Second},
+		{name: "Second failure", attempts: 2, want: time.Minute},
+		{name: "Fifth failure", attempts: 5, want: 8 * time.Minute},
+		{name: "Capped", attempts: 30, want: 6 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Backoff(tt.attempts); got != tt.want {
+				t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSend(t *testing.T) {
+	body := []byte(`{"id":"0b5e3c4f-6a3e-4f0e-9a53-0f9f3f4f5e0a","type":"chirp.created","data":{}}`)
+
+	tests := []struct {
+		name       string
+		status     int
+		wantStatus int
+		wantErr    bool
+	}{
+		{name: "Accepted", status: http.StatusNoContent, wantStatus: http.StatusNoContent, wantErr: false},
+		{name: "Receiver error", status: http.StatusBadGateway, wantStatus: http.StatusBadGateway, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := NewVerifier([]string{"endpoint-secret"}, 5*time.Minute)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ := io.ReadAll(r.Body)
+				if err := verifier.Verify(r.Header.Get(SignatureHeader), got); err != nil {
+					t.Errorf("receiver could not verify delivery: %v", err)
+				}
+				if r.Header.Get(EventTypeHeader) != "chirp.created" {
+					t.Errorf("%s = %q, want chirp.created", EventTypeHeader, r.Header.Get(EventTypeHeader))
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			sender := testSender(server)
+			status, err := sender.Send(context.Background(), server.URL, "endpoint-secret", "0b5e3c4f-6a3e-4f0e-9a53-0f9f3f4f5e0a", "chirp.created", body)
+			if status != tt.wantStatus {
+				t.Errorf("Send() status = %d, want %d", status, tt.wantStatus)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var statusErr *StatusError
+			if tt.wantErr && !errors.As(err, &statusErr) {
+				t.Errorf("Send() error = %T, want *StatusError", err)
+			}
+		})
+	}
+}
+
+func TestSendPropagatesTraceContext(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("traceparent")
+	}))
+	defer server.Close()
+
+	if _, err := testSender(server).Send(ctx, server.URL, "endpoint-secret", "0b5e3c4f-6a3e-4f0e-9a53-0f9f3f4f5e0a", "chirp.created", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	if got != want {
+		t.Errorf("traceparent = %q, want %q", got, want)
+	}
+}
+
+// testSender is NewSender with the address check lifted, since test
+// receivers listen on loopback.
+func testSender(server *httptest.Server) *Sender {
+	sender := NewSender(5 * time.Second)
+	sender.Client.Transport = server.Client().Transport
+	return sender
+}
+
+func TestSendRefusesBlockedAddresses(t *testing.T) {
+	var hit atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit.Store(true)
+	}))
+	defer server.Close()
+
+	_, err := NewSender(5*time.Second).Send(context.Background(), server.URL, "endpoint-secret", "0b5e3c4f-6a3e-4f0e-9a53-0f9f3f4f5e0a", "chirp.created", []byte(`{}`))
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("Send() to loopback error = %v, want ErrBlockedAddress", err)
+	}
+	if hit.Load() {
+		t.Error("Send() reached a loopback receiver")
+	}
+}
+
+func TestSendDoesNotFollowRedirects(t *testing.T) {
+	var followed atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/internal" {
+			followed.Store(true)
+			return
+		}
+		http.Redirect(w, r, "/internal", http.StatusTemporaryRedirect)
+	}))
+	defer server.Close()
+
+	status, err := testSender(server).Send(context.Background(), server.URL, "endpoint-secret", "0b5e3c4f-6a3e-4f0e-9a53-0f9f3f4f5e0a", "chirp.created", []byte(`{}`))
+	if status != http.StatusTemporaryRedirect {
+		t.Errorf("Send() status = %d, want %d", status, http.StatusTemporaryRedirect)
+	}
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Errorf("Send() error = %v, want *StatusError", err)
+	}
+	if followed.Load() {
+		t.Error("Send() followed the redirect")
+	}
+}
@@ -0,0 +1,138 @@
+// Package webhook signs, verifies and delivers webhook payloads.
+//
+// A signature header has the form "t=<unix seconds>,v1=<hex>", where the
+// hex value is an HMAC-SHA256 of "<t>.<body>". A header may carry several
+// v1 values so a sender can sign with old and new secrets while rotating.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrMissingSignature = errors.New("webhook signature missing or malformed")
+	ErrInvalidSignature = errors.New("webhook signature does not match")
+	ErrStaleTimestamp   = errors.New("webhook timestamp outside the allowed tolerance")
+	ErrReplayed         = errors.New("webhook signature has already been used")
+)
+
+// Sign returns the signature header value for body sent at timestamp.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + t + ",v1=" + hex.EncodeToString(computeMAC(secret, t, body))
+}
+
+func computeMAC(secret, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// Verifier checks signature headers against a set of active secrets.
+type Verifier struct {
+	secrets   []string
+	tolerance time.Duration
+	now       func() time.Time
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// NewVerifier accepts signatures made with any of secrets whose timestamp is
+// within tolerance of the current time.
+func NewVerifier(secrets []string, tolerance time.Duration) *Verifier {
+	return &Verifier{
+		secrets:   secrets,
+		tolerance: tolerance,
+		now:       time.Now,
+		seen:      map[string]time.Time{},
+	}
+}
+
+// Verify checks header against body. Each signature is accepted once:
+// delivering the same signed request again within the tolerance window is
+// rejected with ErrReplayed.
+func (v *Verifier) Verify(header string, body []byte) error {
+	timestamp, signatures, err := parseHeader(header)
+	if err != nil {
+		return err
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrMissingSignature
+	}
+	now := v.now()
+	signedAt := time.Unix(unix, 0)
+	if signedAt.Before(now.Add(-v.tolerance)) || signedAt.After(now.Add(v.tolerance)) {
+		return ErrStaleTimestamp
+	}
+
+	matched := ""
+	for _, secret := range v.secrets {
+		expected := computeMAC(secret, timestamp, body)
+		for _, signature := range signatures {
+			// hmac.Equal is constant time.
+			if hmac.Equal(expected, signature) {
+				matched = hex.EncodeToString(signature)
+			}
+		}
+	}
+	if matched == "" {
+		return ErrInvalidSignature
+	}
+
+	return v.remember(matched, signedAt, now)
+}
+
+func (v *Verifier) remember(signature string, signedAt, now time.Time) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for seen, at := range v.seen {
+		if at.Before(now.Add(-v.tolerance)) {
+			delete(v.seen, seen)
+		}
+	}
+
+	if _, ok := v.seen[signature]; ok {
+		return ErrReplayed
+	}
+	v.seen[signature] = signedAt
+	return nil
+}
+
+func parseHeader(header string) (string, [][]byte, error) {
+	var timestamp string
+	var signatures [][]byte
+
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature, err := hex.DecodeString(value)
+			if err == nil {
+				signatures = append(signatures, signature)
+			}
+		}
+	}
+
+	if timestamp == "" || len(signatures) == 0 {
+		return "", nil, ErrMissingSignature
+	}
+	return timestamp, signatures, nil
+}
@@ -0,0 +1,80 @@
+package webhook
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	body := []byte(`{"event":"user.upgraded","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c"}}`)
+
+	tests := []struct {
+		name    string
+		header  string
+		body    []byte
+		wantErr error
+	}{
+		{
+			name:    "Valid signature",
+			header:  Sign("current-secret", now, body),
+			body:    body,
+			wantErr: nil,
+		},
+		{
+			name:    "Signed with the previous secret during rotation",
+			header:  Sign("previous-secret", now.Add(time.Second), body),
+			body:    body,
+			wantErr: nil,
+		},
+		{
+			name:    "Replayed delivery",
+			header:  Sign("current-secret", now, body),
+			body:    body,
+			wantErr: ErrReplayed,
+		},
+		{
+			name:    "Tampered body",
+			header:  Sign("current-secret", now.Add(2*time.Second), body),
+			body:    []byte(`{"event":"user.upgraded","data":{"user_id":"someone-else"}}`),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "Unknown secret",
+			header:  Sign("wrong-secret", now.Add(3*time.Second), body),
+			body:    body,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "Stale timestamp",
+			header:  Sign("current-secret", now.Add(-10*time.Minute), body),
+			body:    body,
+			wantErr: ErrStaleTimestamp,
+		},
+		{
+			name:    "Timestamp too far in the future",
+			header:  Sign("current-secret", now.Add(10*time.Minute), body),
+			body:    body,
+			wantErr: ErrStaleTimestamp,
+		},
+		{
+			name:    "Missing signature",
+			header:  "",
+			body:    body,
+			wantErr: ErrMissingSignature,
+		},
+	}
+
+	verifier := NewVerifier([]string{"current-secret", "previous-secret"}, 5*time.Minute)
+	verifier.now = func() time.Time { return now }
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifier.Verify(tt.header, tt.body)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,79 +1,425 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"syscall"
+	"time"
 
+	"github.com/ireoluwa12345/chirpy/internal/analytics"
+	"github.com/ireoluwa12345/chirpy/internal/auth"
+	"github.com/ireoluwa12345/chirpy/internal/billing"
+	"github.com/ireoluwa12345/chirpy/internal/config"
 	"github.com/ireoluwa12345/chirpy/internal/database"
+	"github.com/ireoluwa12345/chirpy/internal/health"
+	"github.com/ireoluwa12345/chirpy/internal/logging"
+	"github.com/ireoluwa12345/chirpy/internal/metrics"
+	"github.com/ireoluwa12345/chirpy/internal/migrate"
+	"github.com/ireoluwa12345/chirpy/internal/oidc"
+	"github.com/ireoluwa12345/chirpy/internal/ratelimit"
+	"github.com/ireoluwa12345/chirpy/internal/tracing"
+	"github.com/ireoluwa12345/chirpy/internal/views"
+	"github.com/ireoluwa12345/chirpy/internal/webhook"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
 type apiConfig struct {
-	hits      atomic.Int32
 	db        *database.Queries
+	dbPool    *sql.DB
+	migrator  *migrate.Migrator
 	jwtSecret string
-	polkaKey  string
+	adminKey  string
+
+	accessTokenExpiry  time.Duration
+	refreshTokenExpiry time.Duration
+
+	limiter           ratelimit.Limiter
+	trustProxyHeaders bool
+
+	passwordPolicy auth.PasswordPolicy
+
+	oidcProviders map[string]*oidc.Lazy
+
+	billingProviders map[string]billing.Provider
+
+	subscriptionGracePeriod time.Duration
+
+	webhookSender *webhook.Sender
+
+	metrics *metrics.Metrics
+
+	analytics      *analytics.Buffer
+	analyticsStore *analytics.PostgresStore
+
+	views *views.Counter
+
+	// draining is set on shutdown so readiness checks fail while in-flight
+	// requests finish.
+	draining atomic.Bool
 }
 
 func main() {
-	port := "8080"
-
 	godotenv.Load()
-	dbURL := os.Getenv("DB_URL")
-	jwtSecret := os.Getenv("JWT_SECRET")
-	polkaKey := os.Getenv("POLKA_KEY")
 
-	db, err := sql.Open("postgres", dbURL)
+	// Without a command, or with only flags, chirpy serves as it always has.
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "serve" {
+		args = args[1:]
+	} else if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		if err := runCommand(args, os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "chirpy:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	serve(args)
+}
+
+// serve runs the API server until SIGINT or SIGTERM.
+func serve(args []string) {
+	conf, err := config.Load(args, os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintln(os.Stderr, "Usage: chirpy [serve] [flags]")
+		config.Usage(os.Stderr)
+		return
+	}
+	if err != nil {
+		fatal("invalid configuration", "error", err)
+	}
+
+	logger, err := logging.New(os.Stdout, conf.LogLevel, conf.LogFormat)
+	if err != nil {
+		fatal("error configuring logging", "error", err)
+	}
+	slog.SetDefault(logger)
+	slog.Info("configuration", "config", conf)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    conf.TraceExporter,
+		Path:        conf.TraceFile,
+		ServiceName: "chirpy",
+		SampleRatio: conf.TraceSampleRatio,
+	})
+	if err != nil {
+		fatal("error configuring tracing", "error", err)
+	}
+
+	passwordPolicy, err := newPasswordPolicy(conf)
 	if err != nil {
-		log.Fatalf("error occurred: %v", err)
+		fatal("error configuring passwords", "error", err)
 	}
-	dbQueries := database.New(db)
 
-	mux := http.NewServeMux()
-	apiMux := http.NewServeMux()
-	adminMux := http.NewServeMux()
+	db, err := sql.Open("postgres", conf.DBURL)
+	if err != nil {
+		fatal("error opening database", "error", err)
+	}
+	dbQueries := database.New(queryErrorRecorder{db: tracing.WrapDB(db)})
+
+	migrator, err := migrate.New(db, migrations())
+	if err != nil {
+		fatal("error loading migrations", "error", err)
+	}
+	if conf.AutoMigrate {
+		// Replicas starting together take turns behind an advisory lock; all
+		// but the first find nothing to do.
+		results, err := migrator.Up(context.Background())
+		for _, result := range results {
+			slog.Info("applied migration", "version", result.Source.Version, "duration", result.Duration)
+		}
+		if err != nil {
+			fatal("error applying migrations", "error", err)
+		}
+	}
+	if err := migrator.Check(context.Background()); errors.Is(err, migrate.ErrSchemaTooNew) {
+		fatal("refusing to start", "error", err)
+	} else if err != nil {
+		// Readiness keeps failing until the database is reachable.
+		slog.Warn("error checking schema version", "error", err)
+	}
+
+	appMetrics := metrics.New()
+	appMetrics.RegisterDB(db, "chirpy")
+
+	analyticsStore := analytics.NewPostgresStore(dbQueries)
+
+	var limiter ratelimit.Limiter
+	if conf.RateLimitBackend == "postgres" {
+		limiter = ratelimit.NewPostgresLimiter(dbQueries)
+	} else {
+		limiter = ratelimit.NewMemoryLimiter()
+	}
+
+	// The rules were checked by config.Validate.
+	defaultLimit, _ := ratelimit.ParseRule("default", conf.RateLimitDefault)
+	loginLimit, _ := ratelimit.ParseRule("login", conf.RateLimitLogin)
+	createChirpLimit, _ := ratelimit.ParseRule("create_chirp", conf.RateLimitCreateChirp)
 
 	apiCfg := &apiConfig{
-		hits:      atomic.Int32{},
 		db:        dbQueries,
-		jwtSecret: jwtSecret,
-		polkaKey:  polkaKey,
+		dbPool:    db,
+		migrator:  migrator,
+		jwtSecret: conf.JWTSecret,
+		adminKey:  conf.AdminAPIKey,
+
+		accessTokenExpiry:  conf.AccessTokenExpiry,
+		refreshTokenExpiry: conf.RefreshTokenExpiry,
+
+		limiter:           limiter,
+		trustProxyHeaders: conf.TrustProxyHeaders,
+
+		passwordPolicy: passwordPolicy,
+
+		oidcProviders: loadOIDCProviders(conf),
+
+		subscriptionGracePeriod: conf.SubscriptionGracePeriod,
+
+		webhookSender: webhook.NewSender(10 * time.Second),
+
+		metrics: appMetrics,
+
+		analytics:      analytics.NewBuffer(analyticsStore),
+		analyticsStore: analyticsStore,
+
+		views: views.NewCounter(views.NewPostgresStore(dbQueries), conf.ViewDedupWindow, conf.ViewDedupMaxEntries),
+	}
+
+	// polka_webhook_secrets holds every secret Polka may currently sign
+	// with, so secrets can be rotated without downtime.
+	if len(conf.PolkaWebhookSecrets) == 0 {
+		slog.Warn("POLKA_WEBHOOK_SECRETS is not set; polka webhooks are authenticated by API key only")
 	}
 
-	fileServer := http.StripPrefix("/app/", http.FileServer(http.Dir("./")))
+	apiCfg.billingProviders = newBillingProviders(conf)
 
-	mux.Handle("/app/", apiCfg.middlewareMetricsInc(fileServer))
-	apiMux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
+	// Background workers stop once the server has drained, so the work
+	// done by the last requests is still flushed.
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+
+	subscriptionExpiryInterval := 5 * time.Minute
+	webhookDeliveryInterval := conf.WebhookDeliveryInterval
+	analyticsRetentionInterval := time.Hour
+	heartbeats := map[string]*health.Heartbeat{
+		"subscription_expiry": newWorkerHeartbeat(subscriptionExpiryInterval),
+		"webhook_delivery":    newWorkerHeartbeat(webhookDeliveryInterval),
+		"analytics_retention": newWorkerHeartbeat(analyticsRetentionInterval),
+	}
+
+	workers.Go(func() {
+		apiCfg.runSubscriptionExpiry(workerCtx, subscriptionExpiryInterval, heartbeats["subscription_expiry"])
+	})
+	workers.Go(func() {
+		apiCfg.runWebhookDelivery(workerCtx, webhookDeliveryInterval, heartbeats["webhook_delivery"])
+	})
+	workers.Go(func() {
+		apiCfg.analytics.Run(workerCtx, conf.AnalyticsFlushInterval, func(err error) {
+			slog.Error("error flushing analytics", "error", err)
+		})
+	})
+	workers.Go(func() {
+		apiCfg.views.Run(workerCtx, conf.ViewFlushInterval, func(err error) {
+			slog.Error("error flushing chirp views", "error", err)
+		})
+	})
+	workers.Go(func() {
+		apiCfg.runAnalyticsRetention(workerCtx, analyticsRetentionInterval, analytics.Retention{
+			Minutes: conf.AnalyticsMinuteRetention,
+			Hours:   conf.AnalyticsHourRetention,
+		}, heartbeats["analytics_retention"])
 	})
-	apiMux.HandleFunc("POST /validate_chirp", validateChirp)
-	apiMux.HandleFunc("POST /users", apiCfg.HandleCreateUser)
-	apiMux.HandleFunc("PUT /users", apiCfg.HandleUpdateUsers)
-	apiMux.HandleFunc("POST /login", apiCfg.HandleLoginUser)
-	apiMux.HandleFunc("POST /chirps", apiCfg.HandleCreateChirp)
-	apiMux.HandleFunc("GET /chirps", apiCfg.HandleGetChirps)
-	apiMux.HandleFunc("GET /chirps/{chirpID}", apiCfg.HandleGetChirpByID)
-	apiMux.HandleFunc("POST /refresh", apiCfg.HandleRefresh)
-	apiMux.HandleFunc("POST /revoke", apiCfg.HandleRevoke)
-	apiMux.Handle("DELETE /chirps/{chirpID}", apiCfg.authorize(http.HandlerFunc(apiCfg.HandleDeleteChirps)))
-	apiMux.HandleFunc("POST /polka/webhooks", apiCfg.HandlePolkaWebhook)
-
-	adminMux.HandleFunc("GET /metrics", apiCfg.fileServerHits)
-	adminMux.HandleFunc("POST /reset", apiCfg.fileServerReset)
-
-	mux.Handle("/api/", http.StripPrefix("/api", apiMux))
-	mux.Handle("/admin/", http.StripPrefix("/admin", adminMux))
+	workers.Go(func() {
+		pruneRateLimits(workerCtx, limiter, max(defaultLimit.Period, loginLimit.Period, createChirpLimit.Period))
+	})
+
+	readiness := apiCfg.readinessChecker(heartbeats)
+
+	mux := apiCfg.routes(rateLimits{
+		Default:     defaultLimit,
+		Login:       loginLimit,
+		CreateChirp: createChirpLimit,
+	}, readiness)
 
+	// Writes must be allowed to outlast the request deadline so timed-out
+	// requests still get their 504; config.Validate ensures they do.
 	srv := &http.Server{
-		Addr:    ":" + port,
-		Handler: mux,
+		Addr:              ":" + strconv.Itoa(conf.Port),
+		Handler:           requestID(apiCfg.instrument(apiCfg.timeout(conf.RequestTimeout, mux))),
+		ReadHeaderTimeout: conf.HTTPReadHeaderTimeout,
+		ReadTimeout:       conf.HTTPReadTimeout,
+		WriteTimeout:      conf.HTTPWriteTimeout,
+		IdleTimeout:       conf.HTTPIdleTimeout,
 	}
 
-	srv.ListenAndServe()
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("listening", "addr", srv.Addr)
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		fatal("server stopped", "error", err)
+	case <-signals.Done():
+	}
+	// A second signal kills the process without waiting.
+	stopSignals()
+
+	slog.Info("shutting down")
+	if err := apiCfg.drain(srv, conf.ShutdownDrainDelay, conf.ShutdownTimeout); err != nil {
+		slog.Error("error draining requests", "error", err)
+	}
+
+	// Draining may have used up its whole timeout, so stopping the workers
+	// and flushing traces each get their own.
+	stopWorkers()
+	workersCtx, cancel := context.WithTimeout(context.Background(), conf.ShutdownWorkerTimeout)
+	defer cancel()
+	workersDone := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(workersDone)
+	}()
+	select {
+	case <-workersDone:
+	case <-workersCtx.Done():
+		slog.Error("background workers didn't stop in time")
+	}
+
+	tracingCtx, cancel := context.WithTimeout(context.Background(), conf.ShutdownWorkerTimeout)
+	defer cancel()
+	if err := shutdownTracing(tracingCtx); err != nil {
+		slog.Error("error flushing traces", "error", err)
+	}
+	if err := db.Close(); err != nil {
+		slog.Error("error closing database", "error", err)
+	}
+	slog.Info("shut down")
+}
+
+// drain fails readiness first and gives load balancers drainDelay to notice,
+// then refuses new connections and waits up to timeout for requests in
+// flight to finish.
+func (cfg *apiConfig) drain(srv *http.Server, drainDelay, timeout time.Duration) error {
+	cfg.draining.Store(true)
+	time.Sleep(drainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return srv.Shutdown(ctx)
+}
+
+// newPasswordPolicy sets the password hashing parameters and returns the
+// policy new passwords must meet.
+func newPasswordPolicy(conf *config.Config) (auth.PasswordPolicy, error) {
+	passwordParams := auth.DefaultPasswordParams
+	passwordParams.Memory = conf.Argon2MemoryKiB
+	passwordParams.Iterations = conf.Argon2Iterations
+	passwordParams.Parallelism = conf.Argon2Parallelism
+	if err := auth.SetPasswordParams(passwordParams); err != nil {
+		return auth.PasswordPolicy{}, fmt.Errorf("invalid password hashing parameters: %w", err)
+	}
+
+	passwordPolicy := auth.PasswordPolicy{
+		MinLength: conf.PasswordMinLength,
+		MinScore:  conf.PasswordMinScore,
+	}
+	if path := conf.BreachedPasswordsFile; path != "" {
+		breached, err := auth.LoadBreachedPasswords(path)
+		if err != nil {
+			return auth.PasswordPolicy{}, fmt.Errorf("error loading breached passwords from %s: %w", path, err)
+		}
+		passwordPolicy.Breached = breached
+	}
+	return passwordPolicy, nil
+}
+
+// newBillingProviders returns the payment providers whose webhooks are
+// accepted, by name.
+func newBillingProviders(conf *config.Config) map[string]billing.Provider {
+	providers := map[string]billing.Provider{}
+	for _, provider := range []billing.Provider{
+		billing.NewPolka(conf.PolkaKey, conf.PolkaWebhookSecrets),
+	} {
+		providers[provider.Name()] = provider
+	}
+	return providers
+}
+
+// fatal logs msg with args and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// oidcDiscoveryRetry is how long after a failed discovery an identity
+// provider is tried again.
+const oidcDiscoveryRetry = 30 * time.Second
+
+// loadOIDCProviders sets up every provider named in oidc_providers and
+// discovers each one now where it can. One that can't be reached yet is
+// discovered when it's next used.
+func loadOIDCProviders(conf *config.Config) map[string]*oidc.Lazy {
+	providers := map[string]*oidc.Lazy{}
+
+	for _, name := range conf.OIDCProviders {
+		settings := conf.OIDC[name]
+		provider := oidc.NewLazy(oidc.Config{
+			Name:         name,
+			IssuerURL:    settings.IssuerURL,
+			ClientID:     settings.ClientID,
+			ClientSecret: settings.ClientSecret,
+			RedirectURL:  settings.RedirectURL,
+		}, nil, oidcDiscoveryRetry)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if _, err := provider.Provider(ctx); err != nil {
+			slog.Warn("identity provider unavailable; retrying when it's used", "provider", name, "error", err)
+		}
+		cancel()
+
+		providers[name] = provider
+	}
+
+	return providers
+}
+
+// newWorkerHeartbeat allows a worker that runs every interval to miss a run
+// and take a while over the next before it counts as stuck.
+func newWorkerHeartbeat(interval time.Duration) *health.Heartbeat {
+	return health.NewHeartbeat(2*interval + 5*time.Minute)
+}
+
+// pruneRateLimits periodically forgets buckets idle for longer than
+// maxPeriod, until ctx is cancelled; by then they have refilled completely.
+func pruneRateLimits(ctx context.Context, limiter ratelimit.Limiter, maxPeriod time.Duration) {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := limiter.Prune(ctx, time.Now().Add(-maxPeriod)); err != nil {
+			slog.ErrorContext(ctx, "error pruning rate limits", "error", err)
+		}
+	}
 }
@@ -2,39 +2,397 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/ireoluwa12345/chirpy/internal/auth"
+	"github.com/ireoluwa12345/chirpy/internal/entitlements"
+	"github.com/ireoluwa12345/chirpy/internal/logging"
+	"github.com/ireoluwa12345/chirpy/internal/ratelimit"
+	"github.com/ireoluwa12345/chirpy/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
+// responseRecorder captures the status code and body size of a response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// requestID honors the caller's X-Request-ID or generates one, makes it
+// available to every log line written while serving the request and echoes
+// it in the response.
+func requestID(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cfg.hits.Add(1)
-		next.ServeHTTP(w, r)
+		id := r.Header.Get(logging.RequestIDHeader)
+		if !logging.ValidRequestID(id) {
+			id = logging.NewRequestID()
+		}
+
+		w.Header().Set(logging.RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
+// instrument records every request in a trace span, the HTTP metrics,
+// analytics and the access log, labelled by the route pattern that served
+// it. It wraps the top-level mux, and also sets up the request's
+// entitlements cache.
+func (cfg *apiConfig) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		route := new(string)
+		userID := new(uuid.UUID)
+		rec := &responseRecorder{ResponseWriter: w}
+
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracing.Tracer().Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		ctx = context.WithValue(ctx, "route", route)
+		ctx = context.WithValue(ctx, "access_user", userID)
+		ctx = context.WithValue(ctx, "entitlements", map[uuid.UUID]entitlements.Entitlements{})
+		r = r.WithContext(ctx)
+
+		next.ServeHTTP(rec, r)
+
+		// The muxes report the pattern they matched through recordRoute.
+		if *route == "" {
+			*route = routeFromPattern("", r.Pattern)
+		}
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		latency := time.Since(start)
+
+		span.SetName(r.Method + " " + *route)
+		span.SetAttributes(
+			attribute.String("http.route", *route),
+			attribute.Int("http.response.status_code", rec.status),
+		)
+		if rec.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+
+		cfg.metrics.ObserveRequest(r.Method, *route, rec.status, latency, rec.bytes)
+		cfg.analytics.Record(analyticsPath(r, *route, rec.status))
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("route", *route),
+			slog.Int("status", rec.status),
+			slog.Duration("latency", latency),
+			slog.Int("bytes", rec.bytes),
+		}
+		if *userID != uuid.Nil {
+			attrs = append(attrs, slog.String("user_id", userID.String()))
+		}
+		level := slog.LevelInfo
+		if rec.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.LogAttrs(ctx, level, "request", attrs...)
+	})
+}
+
+// recordUser tells instrument which user made the request, for the access
+// log.
+func recordUser(r *http.Request, userID uuid.UUID) {
+	if holder, ok := r.Context().Value("access_user").(*uuid.UUID); ok {
+		*holder = userID
+	}
+}
+
+// recordRoute tells instrument which pattern mux, mounted under prefix,
+// matched. The mux sets the pattern on the request it is given, which
+// middleware such as timeout has copied, so it can't be read from
+// instrument's own request. A mux nested inside another records its more
+// specific pattern first, and the outer one leaves it alone.
+func recordRoute(prefix string, mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.ServeHTTP(w, r)
+
+		if route, ok := r.Context().Value("route").(*string); ok && *route == "" {
+			*route = routeFromPattern(prefix, r.Pattern)
+		}
 	})
 }
 
+// routeFromPattern turns a ServeMux pattern such as "GET /chirps/{chirpID}"
+// into a metrics label. Requests no pattern matched share one label so
+// scanners can't blow up the label cardinality.
+func routeFromPattern(prefix, pattern string) string {
+	if pattern == "" {
+		return "unmatched"
+	}
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		pattern = path
+	}
+	return prefix + pattern
+}
+
 func (cfg *apiConfig) authorize(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		bearerToken, err := auth.GetBearerToken(r.Header)
 		if err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
+			writeAuthenticationError(w, err)
 			return
 		}
 
-		user_id, err := auth.ValidateJWT(bearerToken, cfg.jwtSecret)
+		claims, err := auth.ParseJWT(bearerToken, cfg.jwtSecret)
 
 		if err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte(fmt.Sprintf("error occurred getting validating token: %v", err)))
+			writeAuthenticationError(w, fmt.Errorf("%w: %v", errInvalidToken, err))
 			return
 		}
 
+		user_id, _ := claims.UserID()
+		recordUser(r, user_id)
+		if err := cfg.checkAccountEnabled(r.Context(), user_id); err != nil {
+			writeAuthenticationError(w, err)
+			return
+		}
 		ctx := context.WithValue(r.Context(), "user_id", user_id)
+		ctx = context.WithValue(ctx, "token_claims", claims)
 
 		reqWithData := r.WithContext(ctx)
 
 		next.ServeHTTP(w, reqWithData)
 	})
 }
+
+// requireScope authorizes the request like authorize and also rejects tokens
+// that don't grant scope.
+func (cfg *apiConfig) requireScope(scope string, next http.Handler) http.Handler {
+	return cfg.authorize(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims := r.Context().Value("token_claims").(*auth.Claims)
+		if !claims.Allows(scope) {
+			respondError(w, http.StatusForbidden, errCodeInsufficientScope, fmt.Sprintf("token does not grant the %s scope", scope))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}))
+}
+
+var (
+	errInsufficientScope  = errors.New("token does not grant the required scope")
+	errInvalidToken       = errors.New("access token is invalid or expired")
+	errAccountDisabled    = errors.New("account is disabled")
+	errAccountCheckFailed = errors.New("couldn't check the account")
+)
+
+// authenticate validates the request's access token for handlers that
+// aren't wrapped by authorize, and checks that it grants scope.
+func (cfg *apiConfig) authenticate(r *http.Request, scope string) (uuid.UUID, error) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	claims, err := auth.ParseJWT(bearerToken, cfg.jwtSecret)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: %v", errInvalidToken, err)
+	}
+
+	if !claims.Allows(scope) {
+		return uuid.Nil, errInsufficientScope
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: %v", errInvalidToken, err)
+	}
+
+	recordUser(r, userID)
+	if err := cfg.checkAccountEnabled(r.Context(), userID); err != nil {
+		return uuid.Nil, err
+	}
+	return userID, nil
+}
+
+// checkAccountEnabled rejects the access tokens of a user who was disabled
+// after they were issued. Tokens of users who no longer exist are left to
+// the handlers, which report them as not found.
+func (cfg *apiConfig) checkAccountEnabled(ctx context.Context, userID uuid.UUID) error {
+	user, err := cfg.db.GetUserByID(ctx, userID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "error checking whether the account is disabled", "error", err)
+		return errAccountCheckFailed
+	}
+	if user.DisabledAt.Valid {
+		return errAccountDisabled
+	}
+	return nil
+}
+
+// writeAuthenticationError tells a missing token (unauthorized) apart from
+// a bad or expired one (invalid_token), which clients answer by refreshing.
+func writeAuthenticationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errInsufficientScope):
+		respondError(w, http.StatusForbidden, errCodeInsufficientScope, err.Error())
+	case errors.Is(err, errAccountDisabled):
+		respondError(w, http.StatusForbidden, errCodeAccountDisabled, err.Error())
+	case errors.Is(err, errAccountCheckFailed):
+		respondError(w, http.StatusInternalServerError, errCodeInternal, err.Error())
+	case errors.Is(err, errInvalidToken):
+		respondError(w, http.StatusUnauthorized, errCodeInvalidToken, err.Error())
+	default:
+		respondError(w, http.StatusUnauthorized, errCodeUnauthorized, err.Error())
+	}
+}
+
+// adminOnly protects operational endpoints with the ADMIN_API_KEY, sent as
+// "Authorization: ApiKey <key>". With no key configured they are disabled.
+func (cfg *apiConfig) adminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey, err := auth.GetAPIKey(r.Header)
+		if err != nil || cfg.adminKey == "" || subtle.ConstantTimeCompare([]byte(apiKey), []byte(cfg.adminKey)) != 1 {
+			respondError(w, http.StatusUnauthorized, errCodeUnauthorized, "admin API key required")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (cfg *apiConfig) rateLimit(rule ratelimit.Rule, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, userID := cfg.rateLimitKey(r)
+		recordUser(r, userID)
+
+		rule := rule
+		if userID != uuid.Nil {
+			// Entitled users get a proportionally larger bucket. Their
+			// bucket key stays the same, so the larger limit applies as
+			// soon as the entitlement does.
+			ent, err := cfg.entitlementsFor(r.Context(), userID)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "error resolving entitlements for rate limit", "error", err)
+			} else {
+				rule.Limit *= ent.RateLimitMultiplier
+			}
+		}
+
+		res, err := cfg.limiter.Allow(r.Context(), key, rule)
+		if err != nil {
+			// Fail open: a broken limiter backend shouldn't take the API down.
+			slog.ErrorContext(r.Context(), "error checking rate limit", "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+		if !res.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			respondError(w, http.StatusTooManyRequests, errCodeRateLimited, "rate limit exceeded")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimitKey identifies the caller: the user ID for requests carrying a
+// valid access token, the client IP otherwise. userID is uuid.Nil for
+// anonymous callers.
+func (cfg *apiConfig) rateLimitKey(r *http.Request) (key string, userID uuid.UUID) {
+	if bearerToken, err := auth.GetBearerToken(r.Header); err == nil {
+		if userID, err := auth.ValidateJWT(bearerToken, cfg.jwtSecret); err == nil {
+			return "user:" + userID.String(), userID
+		}
+	}
+
+	return "ip:" + cfg.clientIP(r), uuid.Nil
+}
+
+// clientIP is the address of the client. Behind a trusted proxy it is the
+// last X-Forwarded-For entry, the one the proxy appended; earlier entries
+// come from the client and can be anything.
+func (cfg *apiConfig) clientIP(r *http.Request) string {
+	if cfg.trustProxyHeaders {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			last := forwarded[len(forwarded)-1]
+			if i := strings.LastIndex(last, ","); i >= 0 {
+				last = last[i+1:]
+			}
+			if ip := strings.TrimSpace(last); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// isHTTPS reports whether the client reached chirpy over HTTPS, either
+// directly or through a trusted proxy that terminates TLS.
+func (cfg *apiConfig) isHTTPS(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	if cfg.trustProxyHeaders {
+		// The proxy in front of chirpy appends the last entry.
+		proto := r.Header.Get("X-Forwarded-Proto")
+		if i := strings.LastIndex(proto, ","); i >= 0 {
+			proto = proto[i+1:]
+		}
+		return strings.EqualFold(strings.TrimSpace(proto), "https")
+	}
+	return false
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
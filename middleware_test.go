@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ireoluwa12345/chirpy/internal/auth"
+	"github.com/ireoluwa12345/chirpy/internal/database"
+	"github.com/ireoluwa12345/chirpy/internal/ratelimit"
+)
+
+func TestDisabledAccountTokens(t *testing.T) {
+	userID := uuid.New()
+	now := time.Now().UTC()
+	db := &fakeDB{users: []database.User{{
+		ID:         userID,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		Email:      "ada@example.com",
+		DisabledAt: sql.NullTime{Time: now, Valid: true},
+	}}}
+	_, handler := newTestAPI(t, sql.OpenDB(db))
+
+	token, err := auth.MakeJWT(userID, testJWTSecret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{name: "chirp stats", method: "GET", path: "/api/chirps/" + uuid.NewString() + "/stats"},
+		{name: "pin chirp", method: "POST", path: "/api/chirps/" + uuid.NewString() + "/pin"},
+		{name: "create chirp", method: "POST", path: "/api/chirps", body: `{"body":"hi"}`},
+		{name: "update user", method: "PUT", path: "/api/users", body: `{"email":"ada@example.org","password":"correct horse battery"}`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header = bearer(token)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusForbidden {
+				t.Errorf("status = %d, want 403; body %s", rec.Code, rec.Body)
+			}
+			assertProblem(t, rec, errCodeAccountDisabled)
+		})
+	}
+}
+
+func TestEntitlementsResolvedOncePerRequest(t *testing.T) {
+	userID := uuid.New()
+	now := time.Now().UTC()
+	db := &fakeDB{users: []database.User{{ID: userID, CreatedAt: now, UpdatedAt: now, Email: "ada@example.com"}}}
+	_, handler := newTestAPI(t, sql.OpenDB(db))
+
+	token, err := auth.MakeJWT(userID, testJWTSecret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Creating a chirp passes two rate limits, and the handler checks the
+	// author's length limit.
+	for i := range 2 {
+		req := httptest.NewRequest("POST", "/api/chirps", strings.NewReader(`{"body":"hi"}`))
+		req.Header = bearer(token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("status = %d, want 201; body %s", rec.Code, rec.Body)
+		}
+
+		if got := db.count("ListActiveEntitlementFeatures"); got != i+1 {
+			t.Errorf("request %d: resolved entitlements %d times in total, want %d", i+1, got, i+1)
+		}
+	}
+}
+
+func TestChirpStatsScope(t *testing.T) {
+	_, handler := newTestAPI(t, sql.OpenDB(&fakeDB{}))
+	userID := uuid.New()
+
+	tests := []struct {
+		name     string
+		scope    string
+		wantCode int
+		wantErr  string
+	}{
+		{name: "client without chirps:read", scope: auth.ScopeChirpsWrite, wantCode: http.StatusForbidden, wantErr: errCodeInsufficientScope},
+		{name: "client with chirps:read", scope: auth.ScopeChirpsRead, wantCode: http.StatusNotFound, wantErr: errCodeNotFound},
+		{name: "first-party token", wantCode: http.StatusNotFound, wantErr: errCodeNotFound},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			token, err := auth.MakeJWT(userID, testJWTSecret, time.Hour)
+			if tc.scope != "" {
+				token, err = auth.MakeScopedJWT(userID, testJWTSecret, time.Hour, "client", tc.scope)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest("GET", "/api/chirps/"+uuid.NewString()+"/stats", nil)
+			req.Header = bearer(token)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantCode {
+				t.Errorf("status = %d, want %d; body %s", rec.Code, tc.wantCode, rec.Body)
+			}
+			assertProblem(t, rec, tc.wantErr)
+		})
+	}
+}
+
+// stubLimiter returns result or err and records what it was asked.
+type stubLimiter struct {
+	result ratelimit.Result
+	err    error
+
+	key  string
+	rule ratelimit.Rule
+}
+
+func (l *stubLimiter) Allow(ctx context.Context, key string, rule ratelimit.Rule) (ratelimit.Result, error) {
+	l.key, l.rule = key, rule
+	return l.result, l.err
+}
+
+func (l *stubLimiter) Prune(context.Context, time.Time) error { return nil }
+
+func TestRateLimit(t *testing.T) {
+	redUser := uuid.New()
+	now := time.Now().UTC()
+	db := &fakeDB{users: []database.User{{ID: redUser, CreatedAt: now, UpdatedAt: now, Email: "ada@example.com", IsChirpyRed: true}}}
+	redToken, err := auth.MakeJWT(redUser, testJWTSecret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule := ratelimit.Rule{Name: "test", Limit: 10, Period: time.Minute}
+
+	tests := []struct {
+		name        string
+		limiter     *stubLimiter
+		token       string
+		trustProxy  bool
+		forwarded   string // X-Forwarded-For
+		wantCode    int
+		wantKey     string
+		wantLimit   int
+		wantHeaders map[string]string // empty values mean the header is absent
+	}{
+		{
+			name:      "allowed",
+			limiter:   &stubLimiter{result: ratelimit.Result{Allowed: true, Limit: 10, Remaining: 7, Reset: 1500 * time.Millisecond}},
+			wantCode:  http.StatusOK,
+			wantKey:   "ip:192.0.2.1",
+			wantLimit: 10,
+			wantHeaders: map[string]string{
+				"RateLimit-Limit":     "10",
+				"RateLimit-Remaining": "7",
+				"RateLimit-Reset":     "2",
+				"Retry-After":         "",
+			},
+		},
+		{
+			name:      "limited",
+			limiter:   &stubLimiter{result: ratelimit.Result{Limit: 10, Reset: time.Minute, RetryAfter: 5500 * time.Millisecond}},
+			wantCode:  http.StatusTooManyRequests,
+			wantKey:   "ip:192.0.2.1",
+			wantLimit: 10,
+			wantHeaders: map[string]string{
+				"RateLimit-Limit":     "10",
+				"RateLimit-Remaining": "0",
+				"RateLimit-Reset":     "60",
+				"Retry-After":         "6",
+			},
+		},
+		{
+			name:      "entitled user",
+			limiter:   &stubLimiter{result: ratelimit.Result{Allowed: true, Limit: 50, Remaining: 49, Reset: time.Second}},
+			token:     redToken,
+			wantCode:  http.StatusOK,
+			wantKey:   "user:" + redUser.String(),
+			wantLimit: 50,
+			wantHeaders: map[string]string{
+				"RateLimit-Limit": "50",
+			},
+		},
+		{
+			name:       "behind a trusted proxy",
+			limiter:    &stubLimiter{result: ratelimit.Result{Allowed: true, Limit: 10, Remaining: 9}},
+			trustProxy: true,
+			forwarded:  "198.51.100.7",
+			wantCode:   http.StatusOK,
+			wantKey:    "ip:198.51.100.7",
+			wantLimit:  10,
+		},
+		{
+			// Only the entry the proxy appended can be trusted.
+			name:       "spoofed hops behind a trusted proxy",
+			limiter:    &stubLimiter{result: ratelimit.Result{Allowed: true, Limit: 10, Remaining: 9}},
+			trustProxy: true,
+			forwarded:  "203.0.113.9, 10.0.0.1,198.51.100.7",
+			wantCode:   http.StatusOK,
+			wantKey:    "ip:198.51.100.7",
+			wantLimit:  10,
+		},
+		{
+			name:      "forwarded without a trusted proxy",
+			limiter:   &stubLimiter{result: ratelimit.Result{Allowed: true, Limit: 10, Remaining: 9}},
+			forwarded: "203.0.113.9",
+			wantCode:  http.StatusOK,
+			wantKey:   "ip:192.0.2.1",
+			wantLimit: 10,
+		},
+		{
+			name:      "limiter down fails open",
+			limiter:   &stubLimiter{err: errDatabaseDown},
+			wantCode:  http.StatusOK,
+			wantKey:   "ip:192.0.2.1",
+			wantLimit: 10,
+			wantHeaders: map[string]string{
+				"RateLimit-Limit": "",
+				"Retry-After":     "",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, _ := newTestAPI(t, sql.OpenDB(db))
+			cfg.limiter = tc.limiter
+			cfg.trustProxyHeaders = tc.trustProxy
+			served := false
+			handler := cfg.rateLimit(rule, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				served = true
+			}))
+
+			req := httptest.NewRequest("GET", "/api/chirps", nil)
+			if tc.token != "" {
+				req.Header = bearer(tc.token)
+			}
+			if tc.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tc.forwarded)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantCode || served != (tc.wantCode == http.StatusOK) {
+				t.Errorf("status = %d, served %t; want %d", rec.Code, served, tc.wantCode)
+			}
+			if tc.wantCode == http.StatusTooManyRequests {
+				assertProblem(t, rec, errCodeRateLimited)
+			}
+			if tc.limiter.key != tc.wantKey || tc.limiter.rule.Limit != tc.wantLimit {
+				t.Errorf("limiter asked for %s at %d, want %s at %d", tc.limiter.key, tc.limiter.rule.Limit, tc.wantKey, tc.wantLimit)
+			}
+			for header, want := range tc.wantHeaders {
+				if got := rec.Header().Get(header); got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestIsHTTPS(t *testing.T) {
+	tests := []struct {
+		name       string
+		trustProxy bool
+		proto      string
+		want       bool
+	}{
+		{name: "plain HTTP", want: false},
+		{name: "untrusted proxy", proto: "https", want: false},
+		{name: "trusted proxy", trustProxy: true, proto: "https", want: true},
+		{name: "trusted proxy over HTTP", trustProxy: true, proto: "http", want: false},
+		{name: "spoofed behind a trusted proxy", trustProxy: true, proto: "https, http", want: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, _ := newTestAPI(t, sql.OpenDB(&fakeDB{}))
+			cfg.trustProxyHeaders = tc.trustProxy
+
+			req := httptest.NewRequest("GET", "/api/chirps", nil)
+			if tc.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", tc.proto)
+			}
+			if got := cfg.isHTTPS(req); got != tc.want {
+				t.Errorf("isHTTPS() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
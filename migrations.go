@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"text/tabwriter"
+
+	"github.com/ireoluwa12345/chirpy/internal/config"
+	"github.com/ireoluwa12345/chirpy/internal/migrate"
+	"github.com/pressly/goose/v3"
+)
+
+//go:embed sql/schema/*.sql
+var embeddedMigrations embed.FS
+
+// migrations returns the goose migrations built into the binary.
+func migrations() fs.FS {
+	fsys, err := fs.Sub(embeddedMigrations, "sql/schema")
+	if err != nil {
+		panic(err)
+	}
+	return fsys
+}
+
+const migrateUsage = `Usage: chirpy migrate <command> [flags]
+
+Commands:
+  up      apply every pending migration
+  down    roll back the most recent migration
+  redo    roll back the most recent migration and apply it again
+  status  list migrations and whether they have been applied
+
+Only the database settings are read; run "chirpy -h" for the flags.
+`
+
+// runMigrate runs the migrate subcommand with args, the arguments after
+// "migrate".
+func runMigrate(args []string, stdout io.Writer) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		fmt.Fprint(stdout, migrateUsage)
+		return nil
+	}
+	command := args[0]
+
+	conf, err := config.Parse(args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprint(stdout, migrateUsage)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if conf.DBURL == "" {
+		return errors.New("db_url is required")
+	}
+
+	db, err := sql.Open("postgres", conf.DBURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := migrate.New(db, migrations())
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch command {
+	case "up":
+		results, err := migrator.Up(ctx)
+		printMigrationResults(stdout, results)
+		if err != nil {
+			return err
+		}
+		if len(results) == 0 {
+			fmt.Fprintf(stdout, "schema is up to date at version %d\n", migrator.Latest())
+		}
+	case "down":
+		result, err := migrator.Down(ctx)
+		if errors.Is(err, goose.ErrNoNextVersion) {
+			return errors.New("no migrations to roll back")
+		}
+		if err != nil {
+			return err
+		}
+		printMigrationResults(stdout, []*goose.MigrationResult{result})
+	case "redo":
+		results, err := migrator.Redo(ctx)
+		printMigrationResults(stdout, results)
+		if errors.Is(err, goose.ErrNoNextVersion) {
+			return errors.New("no migrations to redo")
+		}
+		if err != nil {
+			return err
+		}
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		if err := printMigrationStatus(stdout, statuses); err != nil {
+			return err
+		}
+		return migrator.Check(ctx)
+	default:
+		return fmt.Errorf("unknown migrate command %q", command)
+	}
+	return nil
+}
+
+func printMigrationResults(w io.Writer, results []*goose.MigrationResult) {
+	for _, result := range results {
+		fmt.Fprintln(w, result)
+	}
+}
+
+func printMigrationStatus(w io.Writer, statuses []*goose.MigrationStatus) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tMIGRATION\tAPPLIED AT")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.State == goose.StateApplied {
+			appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05 MST")
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", status.Source.Version, status.Source.Path, appliedAt)
+	}
+	return tw.Flush()
+}
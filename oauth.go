@@ -0,0 +1,631 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/ireoluwa12345/chirpy/internal/auth"
+	"github.com/ireoluwa12345/chirpy/internal/database"
+)
+
+const (
+	oauthCodeExpiry         = 10 * time.Minute
+	oauthAccessTokenExpiry  = time.Hour
+	oauthRefreshTokenExpiry = 30 * 24 * time.Hour
+)
+
+var scopeDescriptions = map[string]string{
+	auth.ScopeChirpsRead:   "See how often your chirps were viewed",
+	auth.ScopeChirpsWrite:  "Post and delete chirps as you",
+	auth.ScopeProfileWrite: "Change your email address and password",
+}
+
+// HandleCreateOAuthClient registers a third-party app owned by the caller.
+// Confidential clients get a secret, which is only shown once.
+func (cfg *apiConfig) HandleCreateOAuthClient(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(uuid.UUID)
+
+	var params struct {
+		Name         string   `json:"name" validate:"required"`
+		RedirectURIs []string `json:"redirect_uris" validate:"required,min=1"`
+		Confidential bool     `json:"confidential"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeInvalidJSON, "couldn't decode json")
+		return
+	}
+
+	validate := validator.New()
+	err = validate.Struct(params)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "invalid request body")
+		return
+	}
+
+	for _, redirectURI := range params.RedirectURIs {
+		if !validRedirectURI(redirectURI) {
+			respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "redirect URIs must be absolute https URLs, or http on localhost")
+			return
+		}
+	}
+
+	var secret string
+	var secretHash sql.NullString
+	if params.Confidential {
+		secret, err = auth.MakeRefreshToken()
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, errCodeInternal, "error occurred")
+			return
+		}
+
+		hash, err := auth.HashPassword(secret)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, errCodeInternal, "error occurred")
+			return
+		}
+		secretHash = sql.NullString{String: hash, Valid: true}
+	}
+
+	client, err := cfg.db.CreateOAuthClient(r.Context(), database.CreateOAuthClientParams{
+		ID:           uuid.NewString(),
+		OwnerID:      userID,
+		Name:         params.Name,
+		SecretHash:   secretHash,
+		RedirectUris: params.RedirectURIs,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error creating oauth client", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't create client")
+		return
+	}
+
+	body := map[string]interface{}{
+		"client_id":     client.ID,
+		"name":          client.Name,
+		"redirect_uris": client.RedirectUris,
+		"created_at":    client.CreatedAt,
+	}
+	if secret != "" {
+		body["client_secret"] = secret
+	}
+	respondJSON(w, http.StatusCreated, body)
+}
+
+func validRedirectURI(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Fragment != "" || u.Host == "" {
+		return false
+	}
+	if u.Scheme == "https" {
+		return true
+	}
+	return u.Scheme == "http" && (u.Hostname() == "localhost" || u.Hostname() == "127.0.0.1")
+}
+
+type authorizationRequest struct {
+	Client        database.OauthClient
+	RedirectURI   string
+	Scope         string
+	State         string
+	CodeChallenge string
+}
+
+// authorizationError is an invalid authorization request. Until the client
+// and redirect URI are known to be good the error is shown to the user;
+// after that it is sent back to the client.
+type authorizationError struct {
+	Code        string
+	Description string
+	Redirect    bool
+}
+
+func (cfg *apiConfig) parseAuthorizationRequest(ctx context.Context, values url.Values) (authorizationRequest, *authorizationError) {
+	client, err := cfg.db.GetOAuthClient(ctx, values.Get("client_id"))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			slog.ErrorContext(ctx, "error fetching oauth client", "error", err)
+		}
+		return authorizationRequest{}, &authorizationError{Code: "invalid_client", Description: "Unknown application."}
+	}
+
+	redirectURI := values.Get("redirect_uri")
+	if !slices.Contains(client.RedirectUris, redirectURI) {
+		return authorizationRequest{}, &authorizationError{Code: "invalid_request", Description: "The redirect URI is not registered for this application."}
+	}
+
+	req := authorizationRequest{
+		Client:        client,
+		RedirectURI:   redirectURI,
+		State:         values.Get("state"),
+		CodeChallenge: values.Get("code_challenge"),
+	}
+
+	if values.Get("response_type") != "code" {
+		return req, &authorizationError{Code: "unsupported_response_type", Description: "Only the code response type is supported.", Redirect: true}
+	}
+
+	if req.CodeChallenge == "" || values.Get("code_challenge_method") != "S256" {
+		return req, &authorizationError{Code: "invalid_request", Description: "PKCE with the S256 method is required.", Redirect: true}
+	}
+
+	scope, ok := parseScope(values.Get("scope"))
+	if !ok {
+		return req, &authorizationError{Code: "invalid_scope", Description: "The requested scope is invalid.", Redirect: true}
+	}
+	req.Scope = scope
+
+	return req, nil
+}
+
+// parseScope validates a space separated scope and normalizes its order.
+func parseScope(scope string) (string, bool) {
+	var scopes []string
+	for _, s := range strings.Fields(scope) {
+		if !slices.Contains(auth.GrantableScopes, s) {
+			return "", false
+		}
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	if len(scopes) == 0 {
+		return "", false
+	}
+
+	slices.Sort(scopes)
+	return strings.Join(scopes, " "), true
+}
+
+func redirectWithParams(w http.ResponseWriter, r *http.Request, redirectURI string, params url.Values) {
+	u, _ := url.Parse(redirectURI)
+	query := u.Query()
+	for key, values := range params {
+		for _, value := range values {
+			if value != "" {
+				query.Add(key, value)
+			}
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+func (cfg *apiConfig) handleAuthorizationError(w http.ResponseWriter, r *http.Request, req authorizationRequest, authErr *authorizationError) {
+	if authErr.Redirect {
+		redirectWithParams(w, r, req.RedirectURI, url.Values{
+			"error":             {authErr.Code},
+			"error_description": {authErr.Description},
+			"state":             {req.State},
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusBadRequest)
+	consentTemplate.Execute(w, consentPage{Error: authErr.Description})
+}
+
+// HandleOAuthAuthorize shows the consent screen for an authorization
+// request.
+func (cfg *apiConfig) HandleOAuthAuthorize(w http.ResponseWriter, r *http.Request) {
+	req, authErr := cfg.parseAuthorizationRequest(r.Context(), r.URL.Query())
+	if authErr != nil {
+		cfg.handleAuthorizationError(w, r, req, authErr)
+		return
+	}
+
+	renderConsent(w, http.StatusOK, req, r.URL.Query(), "")
+}
+
+// HandleOAuthConsent handles the consent form: the user logs in and approves
+// or denies the request, and is sent back to the client either way.
+func (cfg *apiConfig) HandleOAuthConsent(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "couldn't parse form")
+		return
+	}
+
+	req, authErr := cfg.parseAuthorizationRequest(r.Context(), r.PostForm)
+	if authErr != nil {
+		cfg.handleAuthorizationError(w, r, req, authErr)
+		return
+	}
+
+	if r.PostForm.Get("decision") != "approve" {
+		redirectWithParams(w, r, req.RedirectURI, url.Values{
+			"error": {"access_denied"},
+			"state": {req.State},
+		})
+		return
+	}
+
+	user, err := cfg.db.GetUserByEmail(r.Context(), r.PostForm.Get("email"))
+	if err != nil && err != sql.ErrNoRows {
+		slog.ErrorContext(r.Context(), "error fetching user by email", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't get user")
+		return
+	}
+	authenticated := false
+	if err == nil {
+		authenticated, _ = auth.VerifyPassword(r.PostForm.Get("password"), user.Password)
+	}
+	if !authenticated {
+		renderConsent(w, http.StatusUnauthorized, req, r.PostForm, "Email or password is incorrect.")
+		return
+	}
+	if user.DisabledAt.Valid {
+		renderConsent(w, http.StatusForbidden, req, r.PostForm, "This account is disabled.")
+		return
+	}
+
+	_, err = cfg.db.UpsertOAuthGrant(r.Context(), database.UpsertOAuthGrantParams{
+		ClientID: req.Client.ID,
+		UserID:   user.ID,
+		Scope:    req.Scope,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error storing oauth grant", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't authorize app")
+		return
+	}
+
+	code, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "error occurred")
+		return
+	}
+
+	err = cfg.db.CreateOAuthAuthorizationCode(r.Context(), database.CreateOAuthAuthorizationCodeParams{
+		Code:          code,
+		ClientID:      req.Client.ID,
+		UserID:        user.ID,
+		RedirectUri:   req.RedirectURI,
+		Scope:         req.Scope,
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     time.Now().Add(oauthCodeExpiry),
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error storing authorization code", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't authorize app")
+		return
+	}
+
+	redirectWithParams(w, r, req.RedirectURI, url.Values{
+		"code":  {code},
+		"state": {req.State},
+	})
+}
+
+type consentPage struct {
+	ClientName string
+	Scopes     []string
+	Params     map[string]string
+	Error      string
+}
+
+var consentTemplate = template.Must(template.New("consent").Parse(`<!DOCTYPE html>
+<html>
+  <head>
+    <title>Authorize {{if .ClientName}}{{.ClientName}}{{else}}application{{end}} - Chirpy</title>
+  </head>
+  <body>
+    {{if .ClientName}}
+    <h1>{{.ClientName}} wants to access your Chirpy account</h1>
+    <p>It will be able to:</p>
+    <ul>
+      {{range .Scopes}}<li>{{.}}</li>{{end}}
+    </ul>
+    {{if .Error}}<p role="alert">{{.Error}}</p>{{end}}
+    <form method="POST" action="/api/oauth/authorize">
+      {{range $name, $value := .Params}}<input type="hidden" name="{{$name}}" value="{{$value}}">
+      {{end}}
+      <label>Email <input type="email" name="email" required></label>
+      <label>Password <input type="password" name="password" required></label>
+      <button type="submit" name="decision" value="approve">Allow</button>
+      <button type="submit" name="decision" value="deny" formnovalidate>Deny</button>
+    </form>
+    {{else}}
+    <h1>Something went wrong</h1>
+    <p role="alert">{{.Error}}</p>
+    {{end}}
+  </body>
+</html>
+`))
+
+func renderConsent(w http.ResponseWriter, status int, req authorizationRequest, values url.Values, errorMessage string) {
+	page := consentPage{
+		ClientName: req.Client.Name,
+		Params:     map[string]string{},
+		Error:      errorMessage,
+	}
+	for _, scope := range strings.Fields(req.Scope) {
+		page.Scopes = append(page.Scopes, scopeDescriptions[scope])
+	}
+	for _, name := range []string{"response_type", "client_id", "redirect_uri", "scope", "state", "code_challenge", "code_challenge_method"} {
+		page.Params[name] = values.Get(name)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// The consent form must not be framed by the client it is protecting
+	// the user from.
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	w.WriteHeader(status)
+	consentTemplate.Execute(w, page)
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	resp, _ := json.Marshal(map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(resp)
+}
+
+// authenticateOAuthClient identifies the client calling the token or
+// revocation endpoint, by HTTP basic auth or form credentials. Public
+// clients only send their client_id.
+func (cfg *apiConfig) authenticateOAuthClient(r *http.Request) (database.OauthClient, bool) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID = r.PostFormValue("client_id")
+		clientSecret = r.PostFormValue("client_secret")
+	}
+
+	client, err := cfg.db.GetOAuthClient(r.Context(), clientID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			slog.ErrorContext(r.Context(), "error fetching oauth client", "error", err)
+		}
+		return database.OauthClient{}, false
+	}
+
+	if !client.SecretHash.Valid {
+		return client, clientSecret == ""
+	}
+
+	match, err := auth.VerifyPassword(clientSecret, client.SecretHash.String)
+	return client, err == nil && match
+}
+
+// HandleOAuthToken exchanges an authorization code or refresh token for a
+// scoped access token.
+func (cfg *apiConfig) HandleOAuthToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "The request body must be form encoded.")
+		return
+	}
+
+	client, ok := cfg.authenticateOAuthClient(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="chirpy"`)
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "Client authentication failed.")
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		cfg.exchangeAuthorizationCode(w, r, client)
+	case "refresh_token":
+		cfg.exchangeOAuthRefreshToken(w, r, client)
+	default:
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "Use authorization_code or refresh_token.")
+	}
+}
+
+func (cfg *apiConfig) exchangeAuthorizationCode(w http.ResponseWriter, r *http.Request, client database.OauthClient) {
+	code, err := cfg.db.ConsumeOAuthAuthorizationCode(r.Context(), r.PostForm.Get("code"))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			slog.ErrorContext(r.Context(), "error consuming authorization code", "error", err)
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "The authorization code is invalid, expired or already used.")
+		return
+	}
+
+	if code.ClientID != client.ID || code.RedirectUri != r.PostForm.Get("redirect_uri") {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "The authorization code was issued to another client or redirect URI.")
+		return
+	}
+
+	if !auth.VerifyPKCE(r.PostForm.Get("code_verifier"), code.CodeChallenge) {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "PKCE verification failed.")
+		return
+	}
+
+	cfg.issueOAuthTokens(w, r, client.ID, code.UserID, code.Scope)
+}
+
+func (cfg *apiConfig) exchangeOAuthRefreshToken(w http.ResponseWriter, r *http.Request, client database.OauthClient) {
+	refreshToken, err := cfg.db.CheckOAuthRefreshToken(r.Context(), r.PostForm.Get("refresh_token"))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			slog.ErrorContext(r.Context(), "error checking oauth refresh token", "error", err)
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "The refresh token is invalid, expired or revoked.")
+		return
+	}
+
+	if refreshToken.ClientID != client.ID {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "The refresh token was issued to another client.")
+		return
+	}
+
+	// A client may ask for a narrower scope than it was granted.
+	scope := refreshToken.Scope
+	if requested := r.PostForm.Get("scope"); requested != "" {
+		narrowed, ok := parseScope(requested)
+		if !ok {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_scope", "The requested scope is invalid.")
+			return
+		}
+		for _, s := range strings.Fields(narrowed) {
+			if !slices.Contains(strings.Fields(refreshToken.Scope), s) {
+				writeOAuthError(w, http.StatusBadRequest, "invalid_scope", "The requested scope exceeds the granted scope.")
+				return
+			}
+		}
+		scope = narrowed
+	}
+
+	// Refresh tokens are rotated on every use.
+	revoked, err := cfg.db.RevokeOAuthRefreshToken(r.Context(), database.RevokeOAuthRefreshTokenParams{
+		Token:    refreshToken.Token,
+		ClientID: client.ID,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error revoking oauth refresh token", "error", err)
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	if revoked == 0 {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "The refresh token is invalid, expired or revoked.")
+		return
+	}
+
+	cfg.issueOAuthTokens(w, r, client.ID, refreshToken.UserID, scope)
+}
+
+func (cfg *apiConfig) issueOAuthTokens(w http.ResponseWriter, r *http.Request, clientID string, userID uuid.UUID, scope string) {
+	accessToken, err := auth.MakeScopedJWT(userID, cfg.jwtSecret, oauthAccessTokenExpiry, clientID, scope)
+	if err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	_, err = cfg.db.CreateOAuthRefreshToken(r.Context(), database.CreateOAuthRefreshTokenParams{
+		Token:     refreshToken,
+		ClientID:  clientID,
+		UserID:    userID,
+		Scope:     scope,
+		ExpiresAt: time.Now().Add(oauthRefreshTokenExpiry),
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error storing oauth refresh token", "error", err)
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	resp, _ := json.Marshal(map[string]interface{}{
+		"access_token":  accessToken,
+		"token_type":    "Bearer",
+		"expires_in":    int(oauthAccessTokenExpiry.Seconds()),
+		"refresh_token": refreshToken,
+		"scope":         scope,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resp)
+}
+
+// HandleOAuthRevoke revokes a refresh token (RFC 7009). Access tokens are
+// short-lived JWTs and expire on their own.
+func (cfg *apiConfig) HandleOAuthRevoke(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "The request body must be form encoded.")
+		return
+	}
+
+	client, ok := cfg.authenticateOAuthClient(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="chirpy"`)
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "Client authentication failed.")
+		return
+	}
+
+	_, err := cfg.db.RevokeOAuthRefreshToken(r.Context(), database.RevokeOAuthRefreshTokenParams{
+		Token:    r.PostForm.Get("token"),
+		ClientID: client.ID,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error revoking oauth refresh token", "error", err)
+		writeOAuthError(w, http.StatusServiceUnavailable, "temporarily_unavailable", "")
+		return
+	}
+
+	// Unknown tokens are not an error; the client's goal is achieved.
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleListOAuthGrants lists the apps the user has authorized.
+func (cfg *apiConfig) HandleListOAuthGrants(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(uuid.UUID)
+
+	grants, err := cfg.db.ListOAuthGrantsForUser(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error listing oauth grants", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't get authorized apps")
+		return
+	}
+	if grants == nil {
+		grants = []database.ListOAuthGrantsForUserRow{}
+	}
+
+	respondJSON(w, http.StatusOK, grants)
+}
+
+// HandleRevokeOAuthGrant de-authorizes an app and revokes its refresh
+// tokens for the user.
+func (cfg *apiConfig) HandleRevokeOAuthGrant(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(uuid.UUID)
+	clientID := r.PathValue("clientID")
+
+	revoked, err := cfg.db.RevokeOAuthGrant(r.Context(), database.RevokeOAuthGrantParams{
+		ClientID: clientID,
+		UserID:   userID,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error revoking oauth grant", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't revoke app")
+		return
+	}
+	if revoked == 0 {
+		respondError(w, http.StatusNotFound, errCodeNotFound, "app not authorized")
+		return
+	}
+
+	err = cfg.db.RevokeOAuthRefreshTokensForGrant(r.Context(), database.RevokeOAuthRefreshTokensForGrantParams{
+		ClientID: clientID,
+		UserID:   userID,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error revoking oauth refresh tokens", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't revoke app")
+		return
+	}
+
+	respondNoContent(w)
+}
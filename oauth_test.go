@@ -0,0 +1,445 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ireoluwa12345/chirpy/internal/auth"
+	"github.com/ireoluwa12345/chirpy/internal/database"
+)
+
+const (
+	oauthTestRedirectURI = "https://app.example.com/callback"
+	oauthTestPassword    = "correct horse battery"
+	oauthTestVerifier    = "a-code-verifier-that-is-long-enough-for-pkce-s256"
+)
+
+// oauthTestPasswordHash is computed once; Argon2id is slow on purpose.
+var oauthTestPasswordHash = sync.OnceValues(func() (string, error) {
+	return auth.HashPassword(oauthTestPassword)
+})
+
+// oauthTest is an API whose database holds one user and the public OAuth
+// client they registered.
+type oauthTest struct {
+	db       *fakeDB
+	handler  http.Handler
+	user     database.User
+	token    string // the user's first-party access token
+	clientID string
+}
+
+func newOAuthTest(t *testing.T) *oauthTest {
+	t.Helper()
+
+	hash, err := oauthTestPasswordHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	user := database.User{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Email: "ada@example.com", Password: hash}
+	db := &fakeDB{users: []database.User{user}}
+	_, handler := newTestAPI(t, sql.OpenDB(db))
+
+	token, err := auth.MakeJWT(user.ID, testJWTSecret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test := &oauthTest{db: db, handler: handler, user: user, token: token}
+	test.clientID = test.registerClient(t, false).ClientID
+	return test
+}
+
+type registeredClient struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
+
+func (o *oauthTest) registerClient(t *testing.T, confidential bool) registeredClient {
+	t.Helper()
+
+	body, _ := json.Marshal(map[string]any{"name": "Chirp Scheduler", "redirect_uris": []string{oauthTestRedirectURI}, "confidential": confidential})
+	req := httptest.NewRequest("POST", "/api/oauth/clients", strings.NewReader(string(body)))
+	req.Header = bearer(o.token)
+	rec := httptest.NewRecorder()
+	o.handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("registering a client: status = %d; body %s", rec.Code, rec.Body)
+	}
+
+	var client registeredClient
+	if err := json.NewDecoder(rec.Body).Decode(&client); err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// authorizationRequestParams returns the parameters of an authorization request
+// by clientID for the chirps:write scope, with a PKCE challenge for
+// oauthTestVerifier.
+func authorizationRequestParams(clientID string) url.Values {
+	sum := sha256.Sum256([]byte(oauthTestVerifier))
+	return url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"redirect_uri":          {oauthTestRedirectURI},
+		"scope":                 {auth.ScopeChirpsWrite},
+		"state":                 {"state-123"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+	}
+}
+
+func (o *oauthTest) do(method, path string, form url.Values, header http.Header) *httptest.ResponseRecorder {
+	var req *http.Request
+	if method == "GET" {
+		req = httptest.NewRequest(method, path+"?"+form.Encode(), nil)
+	} else {
+		req = httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	maps.Copy(req.Header, header)
+	rec := httptest.NewRecorder()
+	o.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// consent approves an authorization request for clientID as the user and
+// returns the authorization code.
+func (o *oauthTest) consent(t *testing.T, clientID string) string {
+	t.Helper()
+
+	form := authorizationRequestParams(clientID)
+	form.Set("email", o.user.Email)
+	form.Set("password", oauthTestPassword)
+	form.Set("decision", "approve")
+	params := redirectParams(t, o.do("POST", "/api/oauth/authorize", form, nil))
+	if params.Get("code") == "" || params.Get("state") != "state-123" {
+		t.Fatalf("redirected with %v, want a code and the state", params)
+	}
+	return params.Get("code")
+}
+
+type oauthTokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+// exchange swaps a code from consent for tokens.
+func (o *oauthTest) exchange(t *testing.T, code string) oauthTokens {
+	t.Helper()
+
+	rec := o.do("POST", "/api/oauth/token", url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {oauthTestRedirectURI},
+		"code_verifier": {oauthTestVerifier},
+		"client_id":     {o.clientID},
+	}, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("exchanging the code: status = %d; body %s", rec.Code, rec.Body)
+	}
+
+	var tokens oauthTokens
+	if err := json.NewDecoder(rec.Body).Decode(&tokens); err != nil {
+		t.Fatal(err)
+	}
+	return tokens
+}
+
+// redirectParams returns the query of a redirect back to the client.
+func redirectParams(t *testing.T, rec *httptest.ResponseRecorder) url.Values {
+	t.Helper()
+
+	if rec.Code != http.StatusFound {
+		t.Fatalf("status = %d, want a redirect; body %s", rec.Code, rec.Body)
+	}
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location.Scheme+"://"+location.Host+location.Path != oauthTestRedirectURI {
+		t.Fatalf("redirected to %s, want %s", location, oauthTestRedirectURI)
+	}
+	return location.Query()
+}
+
+// assertOAuthError checks rec is an OAuth error response with code.
+func assertOAuthError(t *testing.T, rec *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("body isn't JSON: %v", err)
+	}
+	if rec.Code != status || body.Error != code {
+		t.Errorf("response = %d %s, want %d %s", rec.Code, body.Error, status, code)
+	}
+}
+
+func TestOAuthAuthorize(t *testing.T) {
+	tests := []struct {
+		name      string
+		change    func(params url.Values)
+		wantCode  int
+		wantError string // sent back to the client, or empty
+	}{
+		{name: "valid", change: func(url.Values) {}, wantCode: http.StatusOK},
+		{name: "unknown client", change: func(p url.Values) { p.Set("client_id", "nope") }, wantCode: http.StatusBadRequest},
+		{name: "unregistered redirect URI", change: func(p url.Values) { p.Set("redirect_uri", "https://evil.example.com/callback") }, wantCode: http.StatusBadRequest},
+		{name: "token response type", change: func(p url.Values) { p.Set("response_type", "token") }, wantCode: http.StatusFound, wantError: "unsupported_response_type"},
+		{name: "without PKCE", change: func(p url.Values) { p.Del("code_challenge") }, wantCode: http.StatusFound, wantError: "invalid_request"},
+		{name: "plain PKCE", change: func(p url.Values) { p.Set("code_challenge_method", "plain") }, wantCode: http.StatusFound, wantError: "invalid_request"},
+		{name: "ungrantable scope", change: func(p url.Values) { p.Set("scope", auth.ScopeAccount) }, wantCode: http.StatusFound, wantError: "invalid_scope"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			o := newOAuthTest(t)
+			params := authorizationRequestParams(o.clientID)
+			tc.change(params)
+
+			rec := o.do("GET", "/api/oauth/authorize", params, nil)
+			if rec.Code != tc.wantCode {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tc.wantCode, rec.Body)
+			}
+			switch {
+			case tc.wantError != "":
+				if got := redirectParams(t, rec); got.Get("error") != tc.wantError || got.Get("state") != "state-123" {
+					t.Errorf("redirected with %v, want error %s and the state", got, tc.wantError)
+				}
+			case rec.Code == http.StatusOK:
+				if !strings.Contains(rec.Body.String(), "Chirp Scheduler") || !strings.Contains(rec.Body.String(), scopeDescriptions[auth.ScopeChirpsWrite]) {
+					t.Errorf("consent page doesn't name the app and scope: %s", rec.Body)
+				}
+				if rec.Header().Get("X-Frame-Options") != "DENY" {
+					t.Error("consent page can be framed")
+				}
+			}
+		})
+	}
+}
+
+func TestOAuthConsent(t *testing.T) {
+	tests := []struct {
+		name      string
+		decision  string
+		password  string
+		disabled  bool
+		wantCode  int
+		wantError string
+	}{
+		{name: "approved", decision: "approve", password: oauthTestPassword, wantCode: http.StatusFound},
+		{name: "denied", decision: "deny", wantCode: http.StatusFound, wantError: "access_denied"},
+		{name: "wrong password", decision: "approve", password: "wrong horse battery", wantCode: http.StatusUnauthorized},
+		{name: "disabled account", decision: "approve", password: oauthTestPassword, disabled: true, wantCode: http.StatusForbidden},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			o := newOAuthTest(t)
+			if tc.disabled {
+				o.db.users[0].DisabledAt = sql.NullTime{Time: time.Now(), Valid: true}
+			}
+
+			form := authorizationRequestParams(o.clientID)
+			form.Set("email", o.user.Email)
+			form.Set("password", tc.password)
+			form.Set("decision", tc.decision)
+			rec := o.do("POST", "/api/oauth/authorize", form, nil)
+			if rec.Code != tc.wantCode {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tc.wantCode, rec.Body)
+			}
+
+			approved := tc.wantCode == http.StatusFound && tc.wantError == ""
+			if rec.Code == http.StatusFound {
+				params := redirectParams(t, rec)
+				if params.Get("error") != tc.wantError || (params.Get("code") != "") != approved {
+					t.Errorf("redirected with %v, want error %q", params, tc.wantError)
+				}
+			}
+			if granted := len(o.db.oauthGrants) == 1; granted != approved {
+				t.Errorf("grant stored = %t, want %t", granted, approved)
+			}
+		})
+	}
+}
+
+func TestOAuthTokenExchange(t *testing.T) {
+	tests := []struct {
+		name       string
+		change     func(t *testing.T, o *oauthTest, form url.Values)
+		wantStatus int
+		wantError  string
+	}{
+		{name: "valid", change: func(*testing.T, *oauthTest, url.Values) {}, wantStatus: http.StatusOK},
+		{name: "PKCE mismatch", change: func(_ *testing.T, _ *oauthTest, f url.Values) {
+			f.Set("code_verifier", "another-verifier-that-is-long-enough-for-pkce-s256")
+		}, wantStatus: http.StatusBadRequest, wantError: "invalid_grant"},
+		{name: "without a verifier", change: func(_ *testing.T, _ *oauthTest, f url.Values) { f.Del("code_verifier") }, wantStatus: http.StatusBadRequest, wantError: "invalid_grant"},
+		{name: "redirect URI mismatch", change: func(_ *testing.T, _ *oauthTest, f url.Values) { f.Set("redirect_uri", "https://app.example.com/other") }, wantStatus: http.StatusBadRequest, wantError: "invalid_grant"},
+		{name: "another client", change: func(t *testing.T, o *oauthTest, f url.Values) {
+			f.Set("client_id", o.registerClient(t, false).ClientID)
+		}, wantStatus: http.StatusBadRequest, wantError: "invalid_grant"},
+		{name: "unknown code", change: func(_ *testing.T, _ *oauthTest, f url.Values) { f.Set("code", "nope") }, wantStatus: http.StatusBadRequest, wantError: "invalid_grant"},
+		{name: "unknown client", change: func(_ *testing.T, _ *oauthTest, f url.Values) { f.Set("client_id", "nope") }, wantStatus: http.StatusUnauthorized, wantError: "invalid_client"},
+		{name: "unsupported grant type", change: func(_ *testing.T, _ *oauthTest, f url.Values) { f.Set("grant_type", "password") }, wantStatus: http.StatusBadRequest, wantError: "unsupported_grant_type"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			o := newOAuthTest(t)
+			form := url.Values{
+				"grant_type":    {"authorization_code"},
+				"code":          {o.consent(t, o.clientID)},
+				"redirect_uri":  {oauthTestRedirectURI},
+				"code_verifier": {oauthTestVerifier},
+				"client_id":     {o.clientID},
+			}
+			tc.change(t, o, form)
+
+			rec := o.do("POST", "/api/oauth/token", form, nil)
+			if tc.wantError != "" {
+				assertOAuthError(t, rec, tc.wantStatus, tc.wantError)
+				return
+			}
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tc.wantStatus, rec.Body)
+			}
+
+			var tokens oauthTokens
+			if err := json.NewDecoder(rec.Body).Decode(&tokens); err != nil {
+				t.Fatal(err)
+			}
+			claims, err := auth.ParseJWT(tokens.AccessToken, testJWTSecret)
+			if err != nil || claims.Subject != o.user.ID.String() || !claims.Allows(auth.ScopeChirpsWrite) || claims.Allows(auth.ScopeProfileWrite) {
+				t.Errorf("access token claims = %+v, %v; want the user with only chirps:write", claims, err)
+			}
+
+			// Codes are single use.
+			assertOAuthError(t, o.do("POST", "/api/oauth/token", form, nil), http.StatusBadRequest, "invalid_grant")
+		})
+	}
+}
+
+func TestOAuthConfidentialClient(t *testing.T) {
+	o := newOAuthTest(t)
+	client := o.registerClient(t, true)
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {o.consent(t, client.ClientID)},
+		"redirect_uri":  {oauthTestRedirectURI},
+		"code_verifier": {oauthTestVerifier},
+		"client_id":     {client.ClientID},
+	}
+
+	assertOAuthError(t, o.do("POST", "/api/oauth/token", form, nil), http.StatusUnauthorized, "invalid_client")
+
+	basic := http.Header{"Authorization": {"Basic " + base64.StdEncoding.EncodeToString([]byte(client.ClientID+":"+client.ClientSecret))}}
+	form.Del("client_id")
+	if rec := o.do("POST", "/api/oauth/token", form, basic); rec.Code != http.StatusOK {
+		t.Errorf("with the secret: status = %d, want 200; body %s", rec.Code, rec.Body)
+	}
+}
+
+func TestOAuthRefreshAndRevoke(t *testing.T) {
+	o := newOAuthTest(t)
+	tokens := o.exchange(t, o.consent(t, o.clientID))
+
+	refresh := func(refreshToken, scope string) *httptest.ResponseRecorder {
+		form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}, "client_id": {o.clientID}}
+		if scope != "" {
+			form.Set("scope", scope)
+		}
+		return o.do("POST", "/api/oauth/token", form, nil)
+	}
+
+	assertOAuthError(t, refresh(tokens.RefreshToken, auth.ScopeChirpsWrite+" "+auth.ScopeProfileWrite), http.StatusBadRequest, "invalid_scope")
+
+	rec := refresh(tokens.RefreshToken, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("refresh: status = %d, want 200; body %s", rec.Code, rec.Body)
+	}
+	var refreshed oauthTokens
+	if err := json.NewDecoder(rec.Body).Decode(&refreshed); err != nil {
+		t.Fatal(err)
+	}
+	if refreshed.Scope != auth.ScopeChirpsWrite {
+		t.Errorf("refreshed scope = %q, want %q", refreshed.Scope, auth.ScopeChirpsWrite)
+	}
+
+	// Refresh tokens are rotated.
+	assertOAuthError(t, refresh(tokens.RefreshToken, ""), http.StatusBadRequest, "invalid_grant")
+
+	revoke := func(token string) int {
+		return o.do("POST", "/api/oauth/revoke", url.Values{"token": {token}, "client_id": {o.clientID}}, nil).Code
+	}
+	if code := revoke(refreshed.RefreshToken); code != http.StatusOK {
+		t.Errorf("revoke: status = %d, want 200", code)
+	}
+	assertOAuthError(t, refresh(refreshed.RefreshToken, ""), http.StatusBadRequest, "invalid_grant")
+	if code := revoke("unknown"); code != http.StatusOK {
+		t.Errorf("revoking an unknown token: status = %d, want 200", code)
+	}
+}
+
+func TestOAuthScopeEnforcement(t *testing.T) {
+	o := newOAuthTest(t)
+	tokens := o.exchange(t, o.consent(t, o.clientID))
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		wantCode int
+	}{
+		{name: "chirps:write", method: "POST", path: "/api/chirps", body: `{"body":"scheduled chirp"}`, wantCode: http.StatusCreated},
+		{name: "chirps:read", method: "GET", path: "/api/chirps/" + uuid.NewString() + "/stats", wantCode: http.StatusForbidden},
+		{name: "profile:write", method: "PUT", path: "/api/users", body: `{"email":"ada@example.org","password":"correct horse battery"}`, wantCode: http.StatusForbidden},
+		{name: "account", method: "GET", path: "/api/oauth/grants", wantCode: http.StatusForbidden},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header = bearer(tokens.AccessToken)
+			rec := httptest.NewRecorder()
+			o.handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantCode {
+				t.Errorf("status = %d, want %d; body %s", rec.Code, tc.wantCode, rec.Body)
+			}
+			if tc.wantCode == http.StatusForbidden {
+				assertProblem(t, rec, errCodeInsufficientScope)
+			}
+		})
+	}
+
+	t.Run("revoking the grant", func(t *testing.T) {
+		req := httptest.NewRequest("DELETE", "/api/oauth/grants/"+o.clientID, nil)
+		req.Header = bearer(o.token)
+		rec := httptest.NewRecorder()
+		o.handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("status = %d, want 204; body %s", rec.Code, rec.Body)
+		}
+
+		form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {tokens.RefreshToken}, "client_id": {o.clientID}}
+		assertOAuthError(t, o.do("POST", "/api/oauth/token", form, nil), http.StatusBadRequest, "invalid_grant")
+	})
+}
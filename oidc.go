@@ -0,0 +1,323 @@
+package main
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ireoluwa12345/chirpy/internal/database"
+	"github.com/ireoluwa12345/chirpy/internal/oidc"
+)
+
+const (
+	oidcStateCookie = "chirpy_oidc_state"
+	oidcStateExpiry = 10 * time.Minute
+)
+
+// HandleOIDCLogin starts signing in with an external provider by redirecting
+// the browser to it.
+func (cfg *apiConfig) HandleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	provider, ok := cfg.oidcProvider(w, r)
+	if !ok {
+		return
+	}
+
+	state, err := oidc.NewState(provider.Name(), uuid.Nil)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "error occurred")
+		return
+	}
+
+	sealed, err := oidc.SealState(state, cfg.jwtSecret, oidcStateExpiry)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "error occurred")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    sealed,
+		Path:     oidcStatePath(provider),
+		MaxAge:   int(oidcStateExpiry.Seconds()),
+		HttpOnly: true,
+		Secure:   cfg.isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, oidcAuthCodeURL(provider, state), http.StatusFound)
+}
+
+// HandleOIDCLink starts linking an external identity to the logged-in user.
+// The client opens the returned URL in a browser, which needn't share its
+// cookies, so the flow's state is kept in the database under the state
+// parameter rather than in a cookie. Starting a link abandons any earlier
+// one.
+func (cfg *apiConfig) HandleOIDCLink(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(uuid.UUID)
+
+	provider, ok := cfg.oidcProvider(w, r)
+	if !ok {
+		return
+	}
+
+	state, err := oidc.NewState(provider.Name(), userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "error occurred")
+		return
+	}
+
+	if err := cfg.db.DeleteOIDCLinkStatesForUser(r.Context(), userID); err != nil {
+		slog.ErrorContext(r.Context(), "error deleting oidc link states", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't start linking")
+		return
+	}
+	err = cfg.db.CreateOIDCLinkState(r.Context(), database.CreateOIDCLinkStateParams{
+		State:        state.State,
+		UserID:       userID,
+		Provider:     provider.Name(),
+		Nonce:        state.Nonce,
+		CodeVerifier: state.CodeVerifier,
+		ExpiresAt:    time.Now().Add(oidcStateExpiry),
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error creating oidc link state", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't start linking")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{
+		"authorization_url": oidcAuthCodeURL(provider, state),
+	})
+}
+
+// oidcProvider returns the provider named in the path, discovering it if
+// that hasn't succeeded yet. It writes the error response itself when the
+// provider is unknown or can't be reached.
+func (cfg *apiConfig) oidcProvider(w http.ResponseWriter, r *http.Request) (*oidc.Provider, bool) {
+	lazy, ok := cfg.oidcProviders[r.PathValue("provider")]
+	if !ok {
+		respondError(w, http.StatusNotFound, errCodeNotFound, "unknown identity provider")
+		return nil, false
+	}
+
+	provider, err := lazy.Provider(r.Context())
+	if err != nil {
+		slog.WarnContext(r.Context(), "identity provider unavailable", "provider", lazy.Name(), "error", err)
+		respondError(w, http.StatusServiceUnavailable, errCodeUnavailable, "identity provider is unavailable")
+		return nil, false
+	}
+	return provider, true
+}
+
+func oidcAuthCodeURL(provider *oidc.Provider, state oidc.State) string {
+	return provider.AuthCodeURL(state.State, state.Nonce, oidc.S256Challenge(state.CodeVerifier))
+}
+
+// oidcStatePath scopes the state cookie to the provider's login, link and
+// callback routes.
+func oidcStatePath(provider *oidc.Provider) string {
+	return "/api/auth/" + provider.Name()
+}
+
+// HandleOIDCCallback finishes the flow started by HandleOIDCLogin or
+// HandleOIDCLink.
+func (cfg *apiConfig) HandleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	provider, ok := cfg.oidcProvider(w, r)
+	if !ok {
+		return
+	}
+
+	state, ok := cfg.oidcCallbackState(w, r, provider)
+	if !ok {
+		return
+	}
+
+	if errorCode := r.URL.Query().Get("error"); errorCode != "" {
+		respondError(w, http.StatusUnauthorized, errCodeUnauthorized, "identity provider denied the login")
+		return
+	}
+
+	rawIDToken, err := provider.Exchange(r.Context(), r.URL.Query().Get("code"), state.CodeVerifier)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error exchanging authorization code", "error", err)
+		respondError(w, http.StatusUnauthorized, errCodeUnauthorized, "couldn't exchange authorization code")
+		return
+	}
+
+	idToken, err := provider.VerifyIDToken(r.Context(), rawIDToken, state.Nonce)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error verifying id token", "error", err)
+		respondError(w, http.StatusUnauthorized, errCodeUnauthorized, "invalid id token")
+		return
+	}
+
+	identity, err := cfg.db.GetUserIdentity(r.Context(), database.GetUserIdentityParams{
+		Provider: provider.Name(),
+		Subject:  idToken.Subject,
+	})
+	if err != nil && err != sql.ErrNoRows {
+		slog.ErrorContext(r.Context(), "error fetching user identity", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't get identity")
+		return
+	}
+	linked := err == nil
+
+	if state.LinkUserID != uuid.Nil {
+		cfg.linkIdentity(w, r, provider.Name(), idToken, state.LinkUserID, identity, linked)
+		return
+	}
+
+	var user database.User
+	if linked {
+		user, err = cfg.db.GetUserByID(r.Context(), identity.UserID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error fetching user for identity", "error", err)
+			respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't get user")
+			return
+		}
+		if user.DisabledAt.Valid {
+			respondError(w, http.StatusForbidden, errCodeAccountDisabled, "account is disabled")
+			return
+		}
+	} else {
+		user, ok = cfg.createOIDCUser(w, r, provider.Name(), idToken)
+		if !ok {
+			return
+		}
+	}
+
+	accessToken, refreshToken, err := cfg.issueTokens(r.Context(), user.ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "error occurred")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"id":            user.ID,
+		"created_at":    user.CreatedAt,
+		"updated_at":    user.UpdatedAt,
+		"email":         user.Email,
+		"is_chirpy_red": user.IsChirpyRed,
+		"token":         accessToken,
+		"refresh_token": refreshToken,
+	})
+}
+
+// oidcCallbackState recovers the state of the flow a callback finishes: a
+// login's from its cookie, a link's from the database. Either can be used
+// once. It writes the error response itself when there is no valid state.
+func (cfg *apiConfig) oidcCallbackState(w http.ResponseWriter, r *http.Request, provider *oidc.Provider) (oidc.State, bool) {
+	stateParam := r.URL.Query().Get("state")
+
+	if cookie, err := r.Cookie(oidcStateCookie); err == nil {
+		// Browsers don't send a cookie's path back, so delete it on the path
+		// it was set with.
+		http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: oidcStatePath(provider), MaxAge: -1})
+
+		state, err := oidc.OpenState(cookie.Value, cfg.jwtSecret)
+		if err == nil && state.Provider == provider.Name() && state.State == stateParam {
+			return state, true
+		}
+	}
+
+	if stateParam != "" {
+		link, err := cfg.db.ConsumeOIDCLinkState(r.Context(), database.ConsumeOIDCLinkStateParams{
+			State:    stateParam,
+			Provider: provider.Name(),
+		})
+		if err == nil {
+			return oidc.State{
+				Provider:     link.Provider,
+				State:        link.State,
+				Nonce:        link.Nonce,
+				CodeVerifier: link.CodeVerifier,
+				LinkUserID:   link.UserID,
+			}, true
+		}
+		if err != sql.ErrNoRows {
+			slog.ErrorContext(r.Context(), "error consuming oidc link state", "error", err)
+			respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't get login state")
+			return oidc.State{}, false
+		}
+	}
+
+	respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "invalid login state")
+	return oidc.State{}, false
+}
+
+func (cfg *apiConfig) linkIdentity(w http.ResponseWriter, r *http.Request, provider string, idToken oidc.IDToken, userID uuid.UUID, existing database.UserIdentity, linked bool) {
+	if linked && existing.UserID != userID {
+		respondError(w, http.StatusConflict, errCodeConflict, "this identity is linked to another account")
+		return
+	}
+
+	identity := existing
+	if !linked {
+		var err error
+		identity, err = cfg.db.CreateUserIdentity(r.Context(), database.CreateUserIdentityParams{
+			ID:       uuid.New(),
+			UserID:   userID,
+			Provider: provider,
+			Subject:  idToken.Subject,
+			Email:    idToken.Email,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error linking identity", "error", err)
+			respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't link identity")
+			return
+		}
+	}
+
+	respondJSON(w, http.StatusOK, identity)
+}
+
+// createOIDCUser signs up a new user from a verified identity. The account
+// has no password until the user sets one. It writes the error response
+// itself when it fails.
+func (cfg *apiConfig) createOIDCUser(w http.ResponseWriter, r *http.Request, provider string, idToken oidc.IDToken) (database.User, bool) {
+	if idToken.Email == "" || !idToken.EmailVerified {
+		respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "identity provider did not return a verified email")
+		return database.User{}, false
+	}
+
+	// Never attach an identity to an existing account by email alone; the
+	// owner has to log in and link it.
+	_, err := cfg.db.GetUserByEmail(r.Context(), idToken.Email)
+	if err == nil {
+		respondError(w, http.StatusConflict, errCodeConflict, "an account with this email already exists; log in and link this identity")
+		return database.User{}, false
+	}
+	if err != sql.ErrNoRows {
+		slog.ErrorContext(r.Context(), "error fetching user by email", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't get user")
+		return database.User{}, false
+	}
+
+	user, err := cfg.db.CreateUser(r.Context(), database.CreateUserParams{
+		ID:       uuid.New(),
+		Email:    idToken.Email,
+		Password: "",
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error creating user for identity", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't create user")
+		return database.User{}, false
+	}
+
+	_, err = cfg.db.CreateUserIdentity(r.Context(), database.CreateUserIdentityParams{
+		ID:       uuid.New(),
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  idToken.Subject,
+		Email:    idToken.Email,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error creating identity", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't create identity")
+		return database.User{}, false
+	}
+
+	return user, true
+}
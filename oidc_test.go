@@ -0,0 +1,173 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ireoluwa12345/chirpy/internal/auth"
+	"github.com/ireoluwa12345/chirpy/internal/database"
+	"github.com/ireoluwa12345/chirpy/internal/oidc"
+	"github.com/ireoluwa12345/chirpy/internal/oidc/oidctest"
+)
+
+var oidcTestUser = oidctest.User{Subject: "user-123", Email: "walt@example.com", EmailVerified: true}
+
+// newOIDCTest starts a mock provider and an API that signs in with it as
+// "mock".
+func newOIDCTest(t *testing.T, db *fakeDB) (*oidctest.Server, http.Handler) {
+	t.Helper()
+
+	server := oidctest.NewServer("chirpy", "client-secret", oidcTestUser)
+	t.Cleanup(server.Close)
+
+	provider := oidc.NewLazy(oidc.Config{
+		Name:         "mock",
+		IssuerURL:    server.URL,
+		ClientID:     "chirpy",
+		ClientSecret: "client-secret",
+		RedirectURL:  "http://localhost:8080/api/auth/mock/callback",
+	}, server.Client(), time.Minute)
+	cfg, handler := newTestAPI(t, sql.OpenDB(db))
+	cfg.oidcProviders = map[string]*oidc.Lazy{"mock": provider}
+	return server, handler
+}
+
+// authorizeOIDC opens authURL at the mock provider, as a browser would, and
+// returns the query the provider redirects back with.
+func authorizeOIDC(t *testing.T, server *oidctest.Server, authURL string) url.Values {
+	t.Helper()
+
+	client := *server.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("authorize: invalid redirect: %v", err)
+	}
+	return location.Query()
+}
+
+func TestOIDCStateCookie(t *testing.T) {
+	_, handler := newOIDCTest(t, &fakeDB{})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/auth/mock/login", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("login: status = %d, want 302", rec.Code)
+	}
+	set := rec.Result().Cookies()
+	if len(set) != 1 || set[0].Name != oidcStateCookie || set[0].Path != "/api/auth/mock" {
+		t.Fatalf("login cookies = %v, want the state cookie on /api/auth/mock", set)
+	}
+	if set[0].Secure {
+		t.Error("state cookie is Secure on a plain HTTP request")
+	}
+
+	// The state cookie is single use, even when the callback fails.
+	req := httptest.NewRequest("GET", "/api/auth/mock/callback?state=wrong", nil)
+	req.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: set[0].Value})
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("callback: status = %d, want 400", rec.Code)
+	}
+	cleared := rec.Result().Cookies()
+	if len(cleared) != 1 || cleared[0].Name != oidcStateCookie || cleared[0].Path != set[0].Path || cleared[0].MaxAge >= 0 {
+		t.Errorf("callback cookies = %v, want the state cookie deleted on %s", cleared, set[0].Path)
+	}
+}
+
+func TestOIDCLink(t *testing.T) {
+	now := time.Now().UTC()
+	user := database.User{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Email: "ada@example.com"}
+	db := &fakeDB{users: []database.User{user}}
+	server, handler := newOIDCTest(t, db)
+
+	token, err := auth.MakeJWT(user.ID, testJWTSecret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	startLink := func() string {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/api/auth/mock/link", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("link: status = %d %s, want 200", rec.Code, rec.Body)
+		}
+		if cookies := rec.Result().Cookies(); len(cookies) != 0 {
+			t.Errorf("link set cookies %v on the API response", cookies)
+		}
+		var body struct {
+			AuthorizationURL string `json:"authorization_url"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return body.AuthorizationURL
+	}
+	callback := func(query url.Values) *httptest.ResponseRecorder {
+		t.Helper()
+		// The browser finishing the link has no cookies and no token.
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/auth/mock/callback?"+query.Encode(), nil))
+		return rec
+	}
+
+	// Starting again abandons the first link.
+	abandoned := authorizeOIDC(t, server, startLink())
+	query := authorizeOIDC(t, server, startLink())
+	if rec := callback(abandoned); rec.Code != http.StatusBadRequest {
+		t.Errorf("abandoned link: status = %d, want 400", rec.Code)
+	}
+
+	rec := callback(query)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("callback: status = %d %s, want 200", rec.Code, rec.Body)
+	}
+	var identity database.UserIdentity
+	if err := json.NewDecoder(rec.Body).Decode(&identity); err != nil {
+		t.Fatal(err)
+	}
+	if identity.UserID != user.ID || identity.Provider != "mock" || identity.Subject != oidcTestUser.Subject {
+		t.Errorf("identity = %+v, want %s linked to user %s", identity, oidcTestUser.Subject, user.ID)
+	}
+
+	// The link state can't be replayed.
+	if rec := callback(query); rec.Code != http.StatusBadRequest {
+		t.Errorf("replayed callback: status = %d, want 400", rec.Code)
+	}
+	if len(db.linkStates) != 0 {
+		t.Errorf("%d link states left over", len(db.linkStates))
+	}
+}
+
+func TestOIDCProviderUnavailable(t *testing.T) {
+	server := oidctest.NewServer("chirpy", "client-secret", oidcTestUser)
+	server.Close()
+
+	cfg, handler := newTestAPI(t, sql.OpenDB(&fakeDB{}))
+	cfg.oidcProviders = map[string]*oidc.Lazy{
+		"mock": oidc.NewLazy(oidc.Config{Name: "mock", IssuerURL: server.URL, ClientID: "chirpy"}, nil, time.Minute),
+	}
+
+	for _, path := range []string{"/api/auth/mock/login", "/api/auth/mock/callback?state=s"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("GET %s: status = %d, want 503", path, rec.Code)
+		}
+	}
+}
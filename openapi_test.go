@@ -0,0 +1,387 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TestOpenAPIRoutes fails when a route is registered without a spec entry or
+// the spec documents a route that isn't registered.
+func TestOpenAPIRoutes(t *testing.T) {
+	spec := loadOpenAPI(t)
+
+	documented := map[string]bool{}
+	for path, item := range spec["paths"].(map[string]any) {
+		for method := range item.(map[string]any) {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	registered := registeredRoutes(t)
+	for _, route := range registered {
+		if !documented[route] {
+			t.Errorf("route %q is registered but not in openapi.json", route)
+		}
+	}
+	for route := range documented {
+		if !slices.Contains(registered, route) {
+			t.Errorf("openapi.json documents %q but no such route is registered", route)
+		}
+	}
+}
+
+// registeredRoutes reads the method-qualified patterns routes registers,
+// with the prefixes the api and admin muxes are mounted under.
+func registeredRoutes(t *testing.T) []string {
+	t.Helper()
+
+	file, err := parser.ParseFile(token.NewFileSet(), "routes.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prefixes := map[string]string{"mux": "", "apiMux": "/api", "adminMux": "/admin"}
+	var routes []string
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || (sel.Sel.Name != "Handle" && sel.Sel.Name != "HandleFunc") {
+			return true
+		}
+		recv, ok := sel.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		prefix, ok := prefixes[recv.Name]
+		if !ok {
+			return true
+		}
+		lit, ok := call.Args[0].(*ast.BasicLit)
+		if !ok {
+			t.Errorf("routes.go: %s pattern is not a string literal", sel.Sel.Name)
+			return true
+		}
+		pattern, _ := strconv.Unquote(lit.Value)
+		method, path, ok := strings.Cut(pattern, " ")
+		if !ok {
+			// Subtree mounts such as /app/ and /api/ aren't endpoints.
+			return true
+		}
+		routes = append(routes, method+" "+prefix+path)
+		return true
+	})
+	if len(routes) == 0 {
+		t.Fatal("found no routes in routes.go")
+	}
+	return routes
+}
+
+// TestOpenAPIResponses drives the server through a session and fails when a
+// response's status, content type or body isn't what the spec documents.
+func TestOpenAPIResponses(t *testing.T) {
+	spec := loadOpenAPI(t)
+	_, handler := newTestAPI(t, sql.OpenDB(&fakeDB{}))
+
+	do := func(method, path string, header http.Header, body string, wantStatus int) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != wantStatus {
+			t.Errorf("%s %s: status = %d, want %d; body %s", method, path, rec.Code, wantStatus, rec.Body)
+		}
+		assertMatchesSpec(t, spec, req, rec)
+		return rec
+	}
+	decode := func(rec *httptest.ResponseRecorder, v any) {
+		t.Helper()
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("decoding %s: %v", rec.Body, err)
+		}
+	}
+	admin := http.Header{"Authorization": {"ApiKey " + testAdminKey}}
+
+	do("GET", "/api/openapi.json", nil, "", http.StatusOK)
+	do("GET", "/api/docs", nil, "", http.StatusOK)
+	do("GET", "/livez", nil, "", http.StatusOK)
+	do("GET", "/metrics", nil, "", http.StatusOK)
+	do("POST", "/api/validate_chirp", nil, `{"body":"a kerfuffle"}`, http.StatusOK)
+	do("POST", "/api/validate_chirp", nil, `{"body":"`+strings.Repeat("a", 141)+`"}`, http.StatusBadRequest)
+
+	do("POST", "/api/users", nil, `{"email":"ada@example.com","password":"short"}`, http.StatusBadRequest)
+	var user struct {
+		ID string `json:"id"`
+	}
+	decode(do("POST", "/api/users", nil, `{"email":"ada@example.com","password":"correct horse battery"}`, http.StatusCreated), &user)
+
+	do("POST", "/api/login", nil, `{"email":"ada@example.com","password":"wrong horse battery"}`, http.StatusUnauthorized)
+	var login struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	decode(do("POST", "/api/login", nil, `{"email":"ada@example.com","password":"correct horse battery"}`, http.StatusOK), &login)
+	do("PUT", "/api/users", bearer(login.Token), `{"email":"ada@example.org","password":"correct horse battery"}`, http.StatusOK)
+	do("PUT", "/api/users", bearer("not-a-jwt"), `{}`, http.StatusUnauthorized)
+
+	var chirp struct {
+		ID string `json:"id"`
+	}
+	decode(do("POST", "/api/chirps", bearer(login.Token), `{"body":"hello, world"}`, http.StatusCreated), &chirp)
+	do("POST", "/api/chirps", bearer(login.Token), `{"body":"`+strings.Repeat("a", 141)+`"}`, http.StatusBadRequest)
+	do("GET", "/api/chirps", nil, "", http.StatusOK)
+	do("GET", "/api/chirps?author_id="+user.ID+"&sort=asc", nil, "", http.StatusOK)
+	do("GET", "/api/chirps?limit=1&offset=1", nil, "", http.StatusOK)
+	do("GET", "/api/chirps?limit=1&after="+chirp.ID, nil, "", http.StatusOK)
+	do("GET", "/api/chirps/"+chirp.ID, nil, "", http.StatusOK)
+	do("GET", "/api/chirps/"+uuid.NewString(), nil, "", http.StatusNotFound)
+	do("GET", "/api/chirps/nope", nil, "", http.StatusBadRequest)
+	do("PUT", "/api/chirps/"+chirp.ID, bearer(login.Token), `{"body":"hello again"}`, http.StatusForbidden)
+	do("GET", "/api/chirps/"+chirp.ID+"/stats", bearer(login.Token), "", http.StatusOK)
+	do("GET", "/api/chirps/"+chirp.ID+"/stats", bearer(testToken(t)), "", http.StatusForbidden)
+
+	do("POST", "/api/chirps/"+chirp.ID+"/pin", bearer(login.Token), "", http.StatusNoContent)
+	do("GET", "/api/users/"+user.ID+"/pinned", nil, "", http.StatusOK)
+	do("DELETE", "/api/chirps/"+chirp.ID+"/pin", bearer(login.Token), "", http.StatusNoContent)
+	do("DELETE", "/api/chirps/"+chirp.ID, bearer(testToken(t)), "", http.StatusForbidden)
+	do("DELETE", "/api/chirps/"+chirp.ID, bearer(login.Token), "", http.StatusNoContent)
+
+	do("GET", "/api/webhooks", bearer(login.Token), "", http.StatusOK)
+	do("GET", "/api/oauth/grants", bearer(login.Token), "", http.StatusOK)
+
+	do("POST", "/api/refresh", bearer(login.RefreshToken), "", http.StatusOK)
+	do("POST", "/api/revoke", bearer(login.RefreshToken), "", http.StatusNoContent)
+	do("POST", "/api/refresh", bearer(login.RefreshToken), "", http.StatusUnauthorized)
+
+	do("GET", "/admin/analytics", nil, "", http.StatusUnauthorized)
+	do("GET", "/admin/analytics", admin, "", http.StatusOK)
+	do("GET", "/admin/webhooks/events", admin, "", http.StatusOK)
+	do("GET", "/admin/webhooks/endpoints", admin, "", http.StatusOK)
+	do("GET", "/admin/users/"+user.ID+"/entitlements", admin, "", http.StatusOK)
+	do("GET", "/admin/users/"+uuid.NewString()+"/entitlements", admin, "", http.StatusNotFound)
+}
+
+func loadOpenAPI(t *testing.T) map[string]any {
+	t.Helper()
+
+	var spec map[string]any
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("openapi.json: %v", err)
+	}
+	if spec["openapi"] != "3.1.0" {
+		t.Fatalf("openapi = %v, want 3.1.0", spec["openapi"])
+	}
+	return spec
+}
+
+// assertMatchesSpec checks rec is a response the spec documents for req.
+func assertMatchesSpec(t *testing.T, spec map[string]any, req *http.Request, rec *httptest.ResponseRecorder) {
+	t.Helper()
+	name := req.Method + " " + req.URL.Path
+
+	op := findOperation(spec, req.Method, req.URL.Path)
+	if op == nil {
+		t.Errorf("%s: no operation in openapi.json", name)
+		return
+	}
+	response, ok := op["responses"].(map[string]any)[strconv.Itoa(rec.Code)]
+	if !ok {
+		t.Errorf("%s: status %d is not documented", name, rec.Code)
+		return
+	}
+	content, _ := resolveRef(spec, response)["content"].(map[string]any)
+	if len(content) == 0 {
+		if rec.Body.Len() != 0 {
+			t.Errorf("%s: %d has a body %q, want none", name, rec.Code, rec.Body)
+		}
+		return
+	}
+
+	mediaType, _, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	if err != nil {
+		t.Errorf("%s: Content-Type %q: %v", name, rec.Header().Get("Content-Type"), err)
+		return
+	}
+	media, ok := content[mediaType].(map[string]any)
+	if !ok {
+		t.Errorf("%s: %d Content-Type %q is not documented", name, rec.Code, mediaType)
+		return
+	}
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		return
+	}
+
+	var body any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Errorf("%s: body %q is not JSON: %v", name, rec.Body, err)
+		return
+	}
+	for _, problem := range validateSchema(spec, media["schema"], body, "body") {
+		t.Errorf("%s: %d response: %s", name, rec.Code, problem)
+	}
+}
+
+// findOperation returns the spec operation for method and path, preferring
+// the template with the most literal segments.
+func findOperation(spec map[string]any, method, path string) map[string]any {
+	segments := strings.Split(path, "/")
+
+	var best map[string]any
+	bestLiterals := -1
+	for template, item := range spec["paths"].(map[string]any) {
+		parts := strings.Split(template, "/")
+		if len(parts) != len(segments) {
+			continue
+		}
+		literals := 0
+		matched := true
+		for i, part := range parts {
+			if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+				matched = matched && segments[i] != ""
+				continue
+			}
+			matched = matched && part == segments[i]
+			literals++
+		}
+		op, ok := item.(map[string]any)[strings.ToLower(method)].(map[string]any)
+		if matched && ok && literals > bestLiterals {
+			best, bestLiterals = op, literals
+		}
+	}
+	return best
+}
+
+func resolveRef(spec map[string]any, node any) map[string]any {
+	obj, _ := node.(map[string]any)
+	for obj != nil {
+		ref, ok := obj["$ref"].(string)
+		if !ok {
+			break
+		}
+		var target any = spec
+		for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			target = target.(map[string]any)[key]
+		}
+		obj, _ = target.(map[string]any)
+	}
+	return obj
+}
+
+// validateSchema checks value against the subset of JSON Schema the spec
+// uses and returns a description of each mismatch.
+func validateSchema(spec map[string]any, node any, value any, at string) []string {
+	schema := resolveRef(spec, node)
+	if schema == nil {
+		return nil
+	}
+
+	var problems []string
+	if types, got := schemaTypes(schema["type"]), jsonType(value); len(types) > 0 && !slices.Contains(types, got) {
+		// Every integer is also a number.
+		if got != "integer" || !slices.Contains(types, "number") {
+			return []string{fmt.Sprintf("%s is %s, want %s", at, got, strings.Join(types, " or "))}
+		}
+	}
+	if enum, ok := schema["enum"].([]any); ok && !slices.Contains(enum, value) {
+		problems = append(problems, fmt.Sprintf("%s = %v, want one of %v", at, value, enum))
+	}
+
+	switch v := value.(type) {
+	case string:
+		switch schema["format"] {
+		case "uuid":
+			if _, err := uuid.Parse(v); err != nil {
+				problems = append(problems, fmt.Sprintf("%s = %q is not a uuid", at, v))
+			}
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339Nano, v); err != nil {
+				problems = append(problems, fmt.Sprintf("%s = %q is not a date-time", at, v))
+			}
+		}
+	case []any:
+		if minItems, ok := schema["minItems"].(float64); ok && float64(len(v)) < minItems {
+			problems = append(problems, fmt.Sprintf("%s has %d items, want at least %v", at, len(v), minItems))
+		}
+		for i, item := range v {
+			problems = append(problems, validateSchema(spec, schema["items"], item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			if _, ok := v[name.(string)]; !ok {
+				problems = append(problems, fmt.Sprintf("%s lacks required %q", at, name))
+			}
+		}
+		for name, member := range v {
+			if prop, ok := properties[name]; ok {
+				problems = append(problems, validateSchema(spec, prop, member, at+"."+name)...)
+				continue
+			}
+			switch extra := schema["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					problems = append(problems, fmt.Sprintf("%s has undocumented %q", at, name))
+				}
+			case map[string]any:
+				problems = append(problems, validateSchema(spec, extra, member, at+"."+name)...)
+			}
+		}
+	}
+	return problems
+}
+
+func schemaTypes(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		types := make([]string, 0, len(v))
+		for _, t := range v {
+			types = append(types, t.(string))
+		}
+		return types
+	}
+	return nil
+}
+
+func jsonType(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ireoluwa12345/chirpy/internal/database"
+	"github.com/ireoluwa12345/chirpy/internal/health"
+	"github.com/ireoluwa12345/chirpy/internal/tracing"
+	"github.com/ireoluwa12345/chirpy/internal/webhook"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Events integrators can subscribe to. There is no follow.created: chirpy
+// has no follows to emit it for.
+const (
+	eventChirpCreated   = "chirp.created"
+	eventChirpDeleted   = "chirp.deleted"
+	eventUserUpgraded   = "user.upgraded"
+	eventUserDowngraded = "user.downgraded"
+)
+
+var outboundEventTypes = map[string]bool{
+	eventChirpCreated:   true,
+	eventChirpDeleted:   true,
+	eventUserUpgraded:   true,
+	eventUserDowngraded: true,
+}
+
+// Statuses of a row in webhook_deliveries.
+const (
+	deliveryPending   = "pending"
+	deliverySucceeded = "succeeded"
+	deliveryFailed    = "failed"
+)
+
+const webhookDeliveryBatchSize = 20
+
+// outboundEvent is the body of every delivery.
+type outboundEvent struct {
+	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// emitEvent queues eventType for every endpoint subscribed to it: endpoints
+// registered by admins, and those of userID, the user the event is about.
+// Failing to queue is logged and never fails the request that caused it.
+func (cfg *apiConfig) emitEvent(ctx context.Context, eventType string, userID uuid.UUID, data any) {
+	event := outboundEvent{
+		ID:        uuid.New(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		slog.ErrorContext(ctx, "error encoding event", "event_type", eventType, "error", err)
+		return
+	}
+
+	_, err = cfg.db.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{
+		EventID:      event.ID,
+		EventType:    eventType,
+		Payload:      payload,
+		TraceContext: tracing.MarshalContext(ctx),
+		OwnerID:      uuid.NullUUID{UUID: userID, Valid: userID != uuid.Nil},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "error queueing event", "event_type", eventType, "error", err)
+	}
+}
+
+// runWebhookDelivery sends due deliveries every interval until ctx is
+// cancelled, beating heartbeat after each run. Several replicas can run it
+// at once; each delivery is claimed by one of them.
+func (cfg *apiConfig) runWebhookDelivery(ctx context.Context, interval time.Duration, heartbeat *health.Heartbeat) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		cfg.deliverPendingWebhooks(ctx)
+		heartbeat.Beat()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *apiConfig) deliverPendingWebhooks(ctx context.Context) {
+	for ctx.Err() == nil {
+		deliveries, err := cfg.db.ClaimWebhookDeliveries(ctx, webhookDeliveryBatchSize)
+		if err != nil {
+			slog.ErrorContext(ctx, "error claiming webhook deliveries", "error", err)
+			return
+		}
+
+		endpoints := map[uuid.UUID]database.WebhookEndpoint{}
+		for _, delivery := range deliveries {
+			endpoint, ok := endpoints[delivery.EndpointID]
+			if !ok {
+				endpoint, err = cfg.db.GetWebhookEndpoint(ctx, delivery.EndpointID)
+				if err != nil {
+					slog.ErrorContext(ctx, "error fetching webhook endpoint", "error", err)
+					continue
+				}
+				endpoints[endpoint.ID] = endpoint
+			}
+
+			cfg.deliverWebhook(ctx, endpoint, delivery)
+		}
+
+		if len(deliveries) < webhookDeliveryBatchSize {
+			return
+		}
+	}
+}
+
+// deliverWebhook makes one attempt at a claimed delivery and schedules a
+// retry with exponential backoff if it fails. The attempt is traced as part
+// of the request that caused the event.
+func (cfg *apiConfig) deliverWebhook(ctx context.Context, endpoint database.WebhookEndpoint, delivery database.WebhookDelivery) {
+	ctx, span := tracing.Tracer().Start(tracing.UnmarshalContext(ctx, delivery.TraceContext), "webhook "+delivery.EventType,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("webhook.delivery_id", delivery.ID.String()),
+			attribute.String("webhook.event_type", delivery.EventType),
+			attribute.Int("webhook.attempt", int(delivery.Attempts)),
+			attribute.String("url.full", endpoint.Url),
+		),
+	)
+	defer span.End()
+
+	statusCode, err := cfg.webhookSender.Send(ctx, endpoint.Url, endpoint.Secret, delivery.EventID.String(), delivery.EventType, delivery.Payload)
+	lastStatusCode := sql.NullInt32{Int32: int32(statusCode), Valid: statusCode != 0}
+	if statusCode != 0 {
+		span.SetAttributes(attribute.Int("http.response.status_code", statusCode))
+	}
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	if err == nil {
+		err = cfg.db.MarkWebhookDeliverySucceeded(ctx, database.MarkWebhookDeliverySucceededParams{
+			ID:             delivery.ID,
+			LastStatusCode: lastStatusCode,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "error recording webhook delivery", "error", err)
+		}
+		return
+	}
+
+	if errors.Is(err, context.Canceled) && ctx.Err() != nil {
+		// Shutting down; the lease runs out and the delivery is retried.
+		return
+	}
+
+	status := deliveryPending
+	if delivery.Attempts >= webhook.MaxAttempts {
+		status = deliveryFailed
+		slog.WarnContext(ctx, "giving up on webhook delivery", "delivery_id", delivery.ID, "url", endpoint.Url, "error", err)
+	}
+
+	err = cfg.db.MarkWebhookDeliveryFailed(ctx, database.MarkWebhookDeliveryFailedParams{
+		ID:             delivery.ID,
+		Status:         status,
+		NextAttemptAt:  time.Now().Add(webhook.Backoff(int(delivery.Attempts))),
+		LastStatusCode: lastStatusCode,
+		LastError:      sql.NullString{String: err.Error(), Valid: true},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "error recording webhook delivery failure", "error", err)
+	}
+}
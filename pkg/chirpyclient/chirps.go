@@ -0,0 +1,177 @@
+package chirpyclient
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Chirp is a post.
+type Chirp struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uuid.UUID `json:"user_id"`
+	Body      string    `json:"body"`
+	MediaURLs []string  `json:"media_urls"`
+	ViewCount int64     `json:"view_count"`
+}
+
+// ChirpStats are the view counts for a chirp.
+type ChirpStats struct {
+	ChirpID    uuid.UUID    `json:"chirp_id"`
+	ViewCount  int64        `json:"view_count"`
+	DailyViews []DailyViews `json:"daily_views"`
+}
+
+// DailyViews is the number of views a chirp got on Day, a YYYY-MM-DD date.
+type DailyViews struct {
+	Day   string `json:"day"`
+	Views int64  `json:"views"`
+}
+
+// CreateChirp posts a chirp as the logged-in user. Media URLs need the
+// media_attachments entitlement.
+func (c *Client) CreateChirp(ctx context.Context, body string, mediaURLs ...string) (Chirp, error) {
+	var chirp Chirp
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/chirps",
+		body: struct {
+			Body      string   `json:"body"`
+			MediaURLs []string `json:"media_urls,omitempty"`
+		}{body, mediaURLs},
+		auth: accessCredential,
+	}, &chirp)
+	return chirp, err
+}
+
+// GetChirp returns the chirp with id.
+func (c *Client) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	var chirp Chirp
+	err := c.do(ctx, request{method: http.MethodGet, path: "/api/chirps/" + id.String()}, &chirp)
+	return chirp, err
+}
+
+// UpdateChirp edits the body of one of the logged-in user's chirps. It needs
+// the edit_chirps entitlement.
+func (c *Client) UpdateChirp(ctx context.Context, id uuid.UUID, body string) (Chirp, error) {
+	var chirp Chirp
+	err := c.do(ctx, request{
+		method: http.MethodPut,
+		path:   "/api/chirps/" + id.String(),
+		body: struct {
+			Body string `json:"body"`
+		}{body},
+		auth: accessCredential,
+	}, &chirp)
+	return chirp, err
+}
+
+// DeleteChirp deletes one of the logged-in user's chirps.
+func (c *Client) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/api/chirps/" + id.String(), auth: accessCredential}, nil)
+}
+
+// ChirpStats returns the view counts for one of the logged-in user's chirps.
+func (c *Client) ChirpStats(ctx context.Context, id uuid.UUID) (ChirpStats, error) {
+	var stats ChirpStats
+	err := c.do(ctx, request{method: http.MethodGet, path: "/api/chirps/" + id.String() + "/stats", auth: accessCredential}, &stats)
+	return stats, err
+}
+
+// PinChirp pins one of the logged-in user's chirps to their profile.
+func (c *Client) PinChirp(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, request{method: http.MethodPost, path: "/api/chirps/" + id.String() + "/pin", auth: accessCredential}, nil)
+}
+
+// UnpinChirp unpins one of the logged-in user's chirps.
+func (c *Client) UnpinChirp(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/api/chirps/" + id.String() + "/pin", auth: accessCredential}, nil)
+}
+
+// PinnedChirps returns the chirps userID has pinned, most recently pinned
+// first.
+func (c *Client) PinnedChirps(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	var chirps []Chirp
+	err := c.do(ctx, request{method: http.MethodGet, path: "/api/users/" + userID.String() + "/pinned"}, &chirps)
+	return chirps, err
+}
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 100
+)
+
+// ListChirpsOptions filter and order ListChirps.
+type ListChirpsOptions struct {
+	// AuthorID limits the list to one user's chirps when set.
+	AuthorID uuid.UUID
+
+	// Ascending lists the oldest chirps first instead of the newest.
+	Ascending bool
+
+	// PageSize is how many chirps each request fetches: 50 by default and
+	// at most 100.
+	PageSize int
+}
+
+// ListChirps returns an iterator over chirps, fetching them a page at a
+// time as the loop needs them. The iteration stops after yielding an error.
+func (c *Client) ListChirps(ctx context.Context, opts ListChirpsOptions) iter.Seq2[Chirp, error] {
+	return func(yield func(Chirp, error) bool) {
+		for page, err := range c.ListChirpPages(ctx, opts) {
+			if err != nil {
+				yield(Chirp{}, err)
+				return
+			}
+			for _, chirp := range page {
+				if !yield(chirp, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// ListChirpPages is like ListChirps but yields whole pages.
+func (c *Client) ListChirpPages(ctx context.Context, opts ListChirpsOptions) iter.Seq2[[]Chirp, error] {
+	pageSize := opts.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	pageSize = min(pageSize, maxPageSize)
+
+	return func(yield func([]Chirp, error) bool) {
+		query := url.Values{"limit": {strconv.Itoa(pageSize)}}
+		if opts.AuthorID != uuid.Nil {
+			query.Set("author_id", opts.AuthorID.String())
+		}
+		if opts.Ascending {
+			query.Set("sort", "asc")
+		}
+
+		for {
+			var page []Chirp
+			err := c.do(ctx, request{method: http.MethodGet, path: "/api/chirps", query: query}, &page)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if len(page) > 0 && !yield(page, nil) {
+				return
+			}
+			if len(page) < pageSize {
+				return
+			}
+			// Continue after the last chirp rather than at an offset, so
+			// chirps posted meanwhile don't repeat ones already yielded.
+			query.Set("after", page[len(page)-1].ID.String())
+		}
+	}
+}
@@ -0,0 +1,281 @@
+// Package chirpyclient is a Go client for the Chirpy API. It covers
+// accounts, sessions and chirps.
+//
+// A Client remembers the session Login starts. When the server reports the
+// access token expired, the client gets a new one through /api/refresh and
+// sends the request again. Idempotent requests are retried with backoff
+// after network errors, rate limiting and gateway errors. Failed calls
+// return an *Error that matches one of the Err variables with errors.Is.
+package chirpyclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxRetries   = 3
+	defaultRetryBackoff = 250 * time.Millisecond
+	maxRetryBackoff     = 10 * time.Second
+)
+
+// Client calls the Chirpy API. It is safe for concurrent use.
+type Client struct {
+	// HTTPClient sends requests. It defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	// MaxRetries is how many times an idempotent request is retried. Zero
+	// disables retries.
+	MaxRetries int
+
+	// RetryBackoff is the wait before the first retry. It doubles with each
+	// retry, up to ten seconds, unless the server asks for longer with
+	// Retry-After.
+	RetryBackoff time.Duration
+
+	baseURL *url.URL
+
+	mu           sync.Mutex
+	accessToken  string
+	refreshToken string
+
+	// refreshMu keeps concurrent requests that see an expired token from
+	// each refreshing it.
+	refreshMu sync.Mutex
+}
+
+// New returns a client for the server at baseURL, such as
+// "https://chirpy.example.com".
+func New(baseURL string) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("chirpyclient: parsing base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("chirpyclient: base URL %q must be http or https", baseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+
+	return &Client{
+		HTTPClient:   http.DefaultClient,
+		MaxRetries:   defaultMaxRetries,
+		RetryBackoff: defaultRetryBackoff,
+		baseURL:      u,
+	}, nil
+}
+
+// Tokens returns the session's access and refresh tokens, for example to
+// save it and resume it later with SetTokens.
+func (c *Client) Tokens() (accessToken, refreshToken string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.accessToken, c.refreshToken
+}
+
+// SetTokens replaces the session.
+func (c *Client) SetTokens(accessToken, refreshToken string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.accessToken, c.refreshToken = accessToken, refreshToken
+}
+
+// credential says which token, if any, authenticates a request.
+type credential int
+
+const (
+	noCredential credential = iota
+	accessCredential
+	refreshCredential
+)
+
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	body   any
+	auth   credential
+}
+
+// idempotent reports whether the request may safely be sent again.
+func (r request) idempotent() bool {
+	switch r.method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// do sends req and decodes a successful response into out, which may be
+// nil. A request rejected for an expired access token is sent once more
+// after refreshing it.
+func (c *Client) do(ctx context.Context, req request, out any) error {
+	var body []byte
+	if req.body != nil {
+		var err error
+		body, err = json.Marshal(req.body)
+		if err != nil {
+			return fmt.Errorf("chirpyclient: encoding %s %s request: %w", req.method, req.path, err)
+		}
+	}
+
+	accessToken, refreshToken := c.Tokens()
+	var token string
+	switch req.auth {
+	case accessCredential:
+		token = accessToken
+	case refreshCredential:
+		token = refreshToken
+	}
+	if req.auth != noCredential && token == "" {
+		return ErrNoSession
+	}
+
+	err := c.send(ctx, req, body, token, out)
+	if req.auth != accessCredential || !errors.Is(err, ErrInvalidToken) {
+		return err
+	}
+
+	token, refreshErr := c.refreshAfter(ctx, token)
+	if refreshErr != nil {
+		if errors.Is(refreshErr, ErrNoSession) {
+			return err
+		}
+		return fmt.Errorf("chirpyclient: refreshing access token: %w", refreshErr)
+	}
+	return c.send(ctx, req, body, token, out)
+}
+
+// refreshAfter returns an access token to replace stale, refreshing the
+// session unless another request already has.
+func (c *Client) refreshAfter(ctx context.Context, stale string) (string, error) {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	if current, _ := c.Tokens(); current != stale && current != "" {
+		return current, nil
+	}
+	return c.Refresh(ctx)
+}
+
+// send makes req, retrying it while that is safe and worthwhile.
+func (c *Client) send(ctx context.Context, req request, body []byte, token string, out any) error {
+	for attempt := 0; ; attempt++ {
+		resp, data, err := c.roundTrip(ctx, req, body, token)
+		if err == nil && resp.StatusCode < 300 {
+			if out == nil || resp.StatusCode == http.StatusNoContent {
+				return nil
+			}
+			if err := json.Unmarshal(data, out); err != nil {
+				return fmt.Errorf("chirpyclient: decoding %s %s response: %w", req.method, req.path, err)
+			}
+			return nil
+		}
+
+		var header http.Header
+		if err == nil {
+			header = resp.Header
+			err = newError(resp, data)
+		}
+		if attempt >= c.MaxRetries || !req.idempotent() || !retryable(ctx, resp) {
+			return err
+		}
+
+		timer := time.NewTimer(c.backoff(attempt, header))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// roundTrip sends one attempt of req and reads the whole response.
+func (c *Client) roundTrip(ctx context.Context, req request, body []byte, token string) (*http.Response, []byte, error) {
+	u := *c.baseURL
+	u.Path += req.path
+	u.RawQuery = req.query.Encode()
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, u.String(), reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("chirpyclient: %s %s: %w", req.method, req.path, err)
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, nil, fmt.Errorf("chirpyclient: %s %s: %w", req.method, req.path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("chirpyclient: reading %s %s response: %w", req.method, req.path, err)
+	}
+	return resp, data, nil
+}
+
+// retryable reports whether an attempt that got resp, or no response at all
+// when resp is nil, is worth retrying.
+func retryable(ctx context.Context, resp *http.Response) bool {
+	if resp == nil {
+		return ctx.Err() == nil
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff returns how long to wait before retry attempt+1: RetryBackoff
+// doubled attempt times with up to 50% jitter, or the server's Retry-After
+// if that is longer.
+func (c *Client) backoff(attempt int, header http.Header) time.Duration {
+	d := c.RetryBackoff
+	for range attempt {
+		d *= 2
+		if d >= maxRetryBackoff {
+			d = maxRetryBackoff
+			break
+		}
+	}
+	if d > 1 {
+		d += rand.N(d / 2)
+	}
+
+	if retryAfter := parseRetryAfter(header); retryAfter > d {
+		return retryAfter
+	}
+	return d
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds.
+func parseRetryAfter(header http.Header) time.Duration {
+	seconds, err := strconv.Atoi(header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
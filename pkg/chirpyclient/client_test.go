@@ -0,0 +1,278 @@
+package chirpyclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := New(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.RetryBackoff = time.Millisecond
+	return client
+}
+
+func problem(w http.ResponseWriter, status int, code, extra string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"type":"about:blank","title":%q,"status":%d,"code":%q,"detail":"it failed"%s}`, http.StatusText(status), status, code, extra)
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseURL string
+		wantErr bool
+	}{
+		{name: "https", baseURL: "https://chirpy.example.com"},
+		{name: "trailing slash", baseURL: "http://localhost:8080/"},
+		{name: "no scheme", baseURL: "chirpy.example.com", wantErr: true},
+		{name: "unparseable", baseURL: "http://%zz", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.baseURL)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New(%q) error = %v, wantErr %v", tt.baseURL, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		code     string
+		extra    string
+		wantIs   error
+		wantCode string
+	}{
+		{name: "not found", status: 404, code: "not_found", wantIs: ErrNotFound, wantCode: "not_found"},
+		{name: "chirp too long", status: 400, code: "chirp_too_long", extra: `,"max_length":140`, wantIs: ErrChirpTooLong, wantCode: "chirp_too_long"},
+		{name: "entitlement required", status: 403, code: "entitlement_required", wantIs: ErrEntitlementRequired, wantCode: "entitlement_required"},
+		{name: "password policy", status: 400, code: "password_policy", extra: `,"fields":[{"field":"password","message":"too short"}]`, wantIs: ErrPasswordPolicy, wantCode: "password_policy"},
+		{name: "unknown code", status: 418, code: "teapot", wantCode: "teapot"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				problem(w, tt.status, tt.code, tt.extra)
+			})
+
+			_, err := client.GetChirp(context.Background(), uuid.New())
+
+			var apiErr *Error
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("error = %v, want an *Error", err)
+			}
+			if apiErr.StatusCode != tt.status || apiErr.Code != tt.wantCode || apiErr.Detail != "it failed" {
+				t.Errorf("error = %+v, want status %d and code %q", apiErr, tt.status, tt.wantCode)
+			}
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Errorf("errors.Is(%v, %v) = false", err, tt.wantIs)
+			}
+			if tt.wantIs == nil && errors.Unwrap(err) != nil {
+				t.Errorf("Unwrap() = %v, want nil", errors.Unwrap(err))
+			}
+		})
+	}
+
+	t.Run("password policy fields", func(t *testing.T) {
+		client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			problem(w, 400, "password_policy", `,"fields":[{"field":"password","message":"too short"}]`)
+		})
+
+		_, err := client.CreateUser(context.Background(), "ada@example.com", "short")
+
+		var apiErr *Error
+		if !errors.As(err, &apiErr) || len(apiErr.Fields) != 1 || apiErr.Fields[0].Message != "too short" {
+			t.Errorf("error = %#v, want one field error", err)
+		}
+	})
+
+	t.Run("not a problem document", func(t *testing.T) {
+		client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+		})
+		client.MaxRetries = 0
+
+		_, err := client.GetChirp(context.Background(), uuid.New())
+
+		var apiErr *Error
+		if !errors.As(err, &apiErr) || apiErr.Code != "" || apiErr.Detail != "bad gateway" {
+			t.Errorf("error = %#v, want an *Error without a code", err)
+		}
+	})
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       func(*Client) error
+		failures     int
+		status       int
+		wantAttempts int32
+		wantErr      bool
+	}{
+		{
+			name:         "GET retried until it succeeds",
+			method:       func(c *Client) error { _, err := c.GetChirp(context.Background(), uuid.New()); return err },
+			failures:     2,
+			status:       http.StatusServiceUnavailable,
+			wantAttempts: 3,
+		},
+		{
+			name:         "GET gives up after MaxRetries",
+			method:       func(c *Client) error { _, err := c.GetChirp(context.Background(), uuid.New()); return err },
+			failures:     10,
+			status:       http.StatusTooManyRequests,
+			wantAttempts: 4,
+			wantErr:      true,
+		},
+		{
+			name:         "DELETE retried",
+			method:       func(c *Client) error { return c.DeleteChirp(context.Background(), uuid.New()) },
+			failures:     1,
+			status:       http.StatusGatewayTimeout,
+			wantAttempts: 2,
+		},
+		{
+			name:         "POST not retried",
+			method:       func(c *Client) error { _, err := c.CreateChirp(context.Background(), "hi"); return err },
+			failures:     1,
+			status:       http.StatusServiceUnavailable,
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "client errors not retried",
+			method:       func(c *Client) error { _, err := c.GetChirp(context.Background(), uuid.New()); return err },
+			failures:     1,
+			status:       http.StatusNotFound,
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if int(attempts.Add(1)) <= tt.failures {
+					problem(w, tt.status, "service_unavailable", "")
+					return
+				}
+				switch r.Method {
+				case http.MethodDelete:
+					w.WriteHeader(http.StatusNoContent)
+				default:
+					w.Header().Set("Content-Type", "application/json")
+					fmt.Fprintf(w, `{"id":%q,"body":"hi"}`, uuid.New())
+				}
+			})
+			client.SetTokens("access", "refresh")
+
+			err := tt.method(client)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	client := &Client{RetryBackoff: 100 * time.Millisecond}
+
+	tests := []struct {
+		name     string
+		attempt  int
+		header   http.Header
+		min, max time.Duration
+	}{
+		{name: "first retry", attempt: 0, min: 100 * time.Millisecond, max: 150 * time.Millisecond},
+		{name: "third retry", attempt: 2, min: 400 * time.Millisecond, max: 600 * time.Millisecond},
+		{name: "capped", attempt: 20, min: maxRetryBackoff, max: maxRetryBackoff * 3 / 2},
+		{name: "Retry-After", attempt: 0, header: http.Header{"Retry-After": {"2"}}, min: 2 * time.Second, max: 2 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := client.backoff(tt.attempt, tt.header); got < tt.min || got > tt.max {
+				t.Errorf("backoff(%d) = %v, want between %v and %v", tt.attempt, got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestRefreshOnInvalidToken(t *testing.T) {
+	var refreshes atomic.Int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch auth := r.Header.Get("Authorization"); {
+		case r.URL.Path == "/api/refresh":
+			if auth != "Bearer refresh" {
+				problem(w, 401, "invalid_token", "")
+				return
+			}
+			refreshes.Add(1)
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"token":"fresh"}`)
+		case auth == "Bearer fresh":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			problem(w, 401, "invalid_token", "")
+		}
+	})
+
+	t.Run("refreshes and retries", func(t *testing.T) {
+		client.SetTokens("expired", "refresh")
+
+		if err := client.DeleteChirp(context.Background(), uuid.New()); err != nil {
+			t.Fatalf("DeleteChirp: %v", err)
+		}
+		if access, _ := client.Tokens(); access != "fresh" {
+			t.Errorf("access token = %q, want fresh", access)
+		}
+		if got := refreshes.Load(); got != 1 {
+			t.Errorf("refreshes = %d, want 1", got)
+		}
+	})
+
+	t.Run("refresh token revoked", func(t *testing.T) {
+		client.SetTokens("expired", "revoked")
+
+		err := client.DeleteChirp(context.Background(), uuid.New())
+		if !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("error = %v, want ErrInvalidToken", err)
+		}
+	})
+
+	t.Run("no session", func(t *testing.T) {
+		client.SetTokens("", "")
+
+		err := client.DeleteChirp(context.Background(), uuid.New())
+		if !errors.Is(err, ErrNoSession) {
+			t.Errorf("error = %v, want ErrNoSession", err)
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package chirpyclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Errors for the API's error codes. Every *Error returned for a known code
+// matches one of them with errors.Is.
+var (
+	ErrInvalidJSON         = errors.New("chirpyclient: invalid json")
+	ErrInvalidRequest      = errors.New("chirpyclient: invalid request")
+	ErrInvalidID           = errors.New("chirpyclient: invalid id")
+	ErrUnauthorized        = errors.New("chirpyclient: unauthorized")
+	ErrInvalidToken        = errors.New("chirpyclient: invalid token")
+	ErrInvalidCredentials  = errors.New("chirpyclient: invalid credentials")
+	ErrInsufficientScope   = errors.New("chirpyclient: insufficient scope")
+	ErrForbidden           = errors.New("chirpyclient: forbidden")
+	ErrAccountDisabled     = errors.New("chirpyclient: account disabled")
+	ErrEntitlementRequired = errors.New("chirpyclient: entitlement required")
+	ErrNotFound            = errors.New("chirpyclient: not found")
+	ErrConflict            = errors.New("chirpyclient: conflict")
+	ErrChirpTooLong        = errors.New("chirpyclient: chirp too long")
+	ErrLimitExceeded       = errors.New("chirpyclient: limit exceeded")
+	ErrPasswordPolicy      = errors.New("chirpyclient: password policy")
+	ErrRateLimited         = errors.New("chirpyclient: rate limited")
+	ErrInternal            = errors.New("chirpyclient: internal server error")
+	ErrTimeout             = errors.New("chirpyclient: timeout")
+	ErrUnavailable         = errors.New("chirpyclient: service unavailable")
+)
+
+var codeErrors = map[string]error{
+	"invalid_json":         ErrInvalidJSON,
+	"invalid_request":      ErrInvalidRequest,
+	"invalid_id":           ErrInvalidID,
+	"unauthorized":         ErrUnauthorized,
+	"invalid_token":        ErrInvalidToken,
+	"invalid_credentials":  ErrInvalidCredentials,
+	"insufficient_scope":   ErrInsufficientScope,
+	"forbidden":            ErrForbidden,
+	"account_disabled":     ErrAccountDisabled,
+	"entitlement_required": ErrEntitlementRequired,
+	"not_found":            ErrNotFound,
+	"conflict":             ErrConflict,
+	"chirp_too_long":       ErrChirpTooLong,
+	"limit_exceeded":       ErrLimitExceeded,
+	"password_policy":      ErrPasswordPolicy,
+	"rate_limited":         ErrRateLimited,
+	"internal_error":       ErrInternal,
+	"timeout":              ErrTimeout,
+	"service_unavailable":  ErrUnavailable,
+}
+
+// ErrNoSession is returned by calls that need a session when the client has
+// none, because Login hasn't been called or the session was revoked.
+var ErrNoSession = errors.New("chirpyclient: not logged in")
+
+// Error is an error response from the API.
+type Error struct {
+	StatusCode int
+
+	// Code identifies the error, such as "not_found". It is empty when the
+	// response wasn't an API problem document, as from a proxy.
+	Code   string
+	Title  string
+	Detail string
+
+	// Fields lists the rules a password broke, for ErrPasswordPolicy.
+	Fields []FieldError
+
+	// RetryAfter is how long the server asked the client to wait.
+	RetryAfter time.Duration
+}
+
+// FieldError is one rule a request field broke.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("chirpyclient: %d %s", e.StatusCode, e.Title)
+	}
+	return fmt.Sprintf("chirpyclient: %d %s: %s", e.StatusCode, e.Title, e.Detail)
+}
+
+// Unwrap returns the Err variable for e's code, or nil for unknown codes.
+func (e *Error) Unwrap() error {
+	return codeErrors[e.Code]
+}
+
+// newError builds an *Error from a non-2xx response and its body.
+func newError(resp *http.Response, body []byte) *Error {
+	e := &Error{
+		StatusCode: resp.StatusCode,
+		Title:      http.StatusText(resp.StatusCode),
+		RetryAfter: parseRetryAfter(resp.Header),
+	}
+
+	var doc struct {
+		Title  string       `json:"title"`
+		Code   string       `json:"code"`
+		Detail string       `json:"detail"`
+		Fields []FieldError `json:"fields"`
+	}
+	if json.Unmarshal(body, &doc) != nil || doc.Code == "" {
+		e.Detail = strings.TrimSpace(string(body))
+		return e
+	}
+
+	e.Code = doc.Code
+	e.Detail = doc.Detail
+	e.Fields = doc.Fields
+	if doc.Title != "" {
+		e.Title = doc.Title
+	}
+	return e
+}
@@ -0,0 +1,107 @@
+package chirpyclient
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// User is a Chirpy account.
+type User struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Email       string    `json:"email"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+}
+
+type credentials struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// CreateUser signs up a new account. It doesn't log in.
+func (c *Client) CreateUser(ctx context.Context, email, password string) (User, error) {
+	var user User
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/users",
+		body:   credentials{Email: email, Password: password},
+	}, &user)
+	return user, err
+}
+
+// Login starts a session for the account, replacing any previous one.
+func (c *Client) Login(ctx context.Context, email, password string) (User, error) {
+	var resp struct {
+		User
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/login",
+		body:   credentials{Email: email, Password: password},
+	}, &resp)
+	if err != nil {
+		return User{}, err
+	}
+
+	c.SetTokens(resp.Token, resp.RefreshToken)
+	return resp.User, nil
+}
+
+// UpdateUser changes the logged-in user's email and password.
+func (c *Client) UpdateUser(ctx context.Context, email, password string) (User, error) {
+	var user User
+	err := c.do(ctx, request{
+		method: http.MethodPut,
+		path:   "/api/users",
+		body:   credentials{Email: email, Password: password},
+		auth:   accessCredential,
+	}, &user)
+	return user, err
+}
+
+// Refresh gets a new access token for the session and returns it. Requests
+// call it themselves when the access token has expired.
+func (c *Client) Refresh(ctx context.Context) (string, error) {
+	_, refreshToken := c.Tokens()
+
+	var resp struct {
+		Token string `json:"token"`
+	}
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/refresh",
+		auth:   refreshCredential,
+	}, &resp)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	// Keep a session started while the refresh was in flight.
+	if c.refreshToken == refreshToken {
+		c.accessToken = resp.Token
+	}
+	c.mu.Unlock()
+	return resp.Token, nil
+}
+
+// Revoke ends the session on the server and forgets its tokens.
+func (c *Client) Revoke(ctx context.Context) error {
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/revoke",
+		auth:   refreshCredential,
+	}, nil)
+	if err != nil {
+		return err
+	}
+
+	c.SetTokens("", "")
+	return nil
+}
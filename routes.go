@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/ireoluwa12345/chirpy/internal/auth"
+	"github.com/ireoluwa12345/chirpy/internal/health"
+	"github.com/ireoluwa12345/chirpy/internal/ratelimit"
+)
+
+// rateLimits are the per-caller request limits routes applies.
+type rateLimits struct {
+	Default     ratelimit.Rule
+	Login       ratelimit.Rule
+	CreateChirp ratelimit.Rule
+}
+
+// routes registers every endpoint. The returned handler is wrapped by the
+// request-wide middleware in main.
+func (cfg *apiConfig) routes(limits rateLimits, readiness *health.Checker) http.Handler {
+	mux := http.NewServeMux()
+	apiMux := http.NewServeMux()
+	adminMux := http.NewServeMux()
+
+	fileServer := http.StripPrefix("/app/", http.FileServer(http.Dir("./")))
+
+	mux.Handle("/app/", fileServer)
+	mux.HandleFunc("GET /livez", HandleLivez)
+	mux.Handle("GET /readyz", readiness)
+	mux.Handle("GET /startupz", cfg.startupChecker())
+	// healthz predates /readyz and keeps its plain-text body for existing
+	// monitors.
+	apiMux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if readiness.Run(r.Context()).Status != health.StatusOK {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("unavailable"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	})
+	apiMux.HandleFunc("GET /openapi.json", HandleOpenAPI)
+	apiMux.HandleFunc("GET /docs", HandleDocs)
+	apiMux.HandleFunc("POST /validate_chirp", validateChirp)
+	apiMux.HandleFunc("POST /users", cfg.HandleCreateUser)
+	apiMux.HandleFunc("PUT /users", cfg.HandleUpdateUsers)
+	apiMux.Handle("POST /login", cfg.rateLimit(limits.Login, http.HandlerFunc(cfg.HandleLoginUser)))
+	apiMux.Handle("POST /chirps", cfg.rateLimit(limits.CreateChirp, http.HandlerFunc(cfg.HandleCreateChirp)))
+	apiMux.HandleFunc("GET /chirps", cfg.HandleGetChirps)
+	apiMux.HandleFunc("GET /chirps/{chirpID}", cfg.HandleGetChirpByID)
+	apiMux.HandleFunc("GET /auth/{provider}/login", cfg.HandleOIDCLogin)
+	apiMux.HandleFunc("GET /auth/{provider}/callback", cfg.HandleOIDCCallback)
+	apiMux.Handle("POST /auth/{provider}/link", cfg.requireScope(auth.ScopeAccount, http.HandlerFunc(cfg.HandleOIDCLink)))
+	apiMux.Handle("POST /oauth/clients", cfg.requireScope(auth.ScopeAccount, http.HandlerFunc(cfg.HandleCreateOAuthClient)))
+	apiMux.HandleFunc("GET /oauth/authorize", cfg.HandleOAuthAuthorize)
+	apiMux.Handle("POST /oauth/authorize", cfg.rateLimit(limits.Login, http.HandlerFunc(cfg.HandleOAuthConsent)))
+	apiMux.HandleFunc("POST /oauth/token", cfg.HandleOAuthToken)
+	apiMux.HandleFunc("POST /oauth/revoke", cfg.HandleOAuthRevoke)
+	apiMux.Handle("GET /oauth/grants", cfg.requireScope(auth.ScopeAccount, http.HandlerFunc(cfg.HandleListOAuthGrants)))
+	apiMux.Handle("DELETE /oauth/grants/{clientID}", cfg.requireScope(auth.ScopeAccount, http.HandlerFunc(cfg.HandleRevokeOAuthGrant)))
+	apiMux.HandleFunc("POST /refresh", cfg.HandleRefresh)
+	apiMux.HandleFunc("POST /revoke", cfg.HandleRevoke)
+	apiMux.Handle("PUT /chirps/{chirpID}", cfg.requireScope(auth.ScopeChirpsWrite, http.HandlerFunc(cfg.HandleUpdateChirp)))
+	apiMux.Handle("DELETE /chirps/{chirpID}", cfg.requireScope(auth.ScopeChirpsWrite, http.HandlerFunc(cfg.HandleDeleteChirps)))
+	apiMux.Handle("GET /chirps/{chirpID}/stats", cfg.requireScope(auth.ScopeChirpsRead, http.HandlerFunc(cfg.HandleGetChirpStats)))
+	apiMux.Handle("POST /chirps/{chirpID}/pin", cfg.requireScope(auth.ScopeChirpsWrite, http.HandlerFunc(cfg.HandlePinChirp)))
+	apiMux.Handle("DELETE /chirps/{chirpID}/pin", cfg.requireScope(auth.ScopeChirpsWrite, http.HandlerFunc(cfg.HandleUnpinChirp)))
+	apiMux.HandleFunc("GET /users/{userID}/pinned", cfg.HandleGetPinnedChirps)
+	apiMux.HandleFunc("POST /billing/{provider}/webhooks", cfg.HandleBillingWebhook)
+	apiMux.HandleFunc("POST /polka/webhooks", cfg.HandlePolkaWebhook)
+	apiMux.Handle("POST /webhooks", cfg.requireScope(auth.ScopeAccount, http.HandlerFunc(cfg.HandleCreateWebhookEndpoint)))
+	apiMux.Handle("GET /webhooks", cfg.requireScope(auth.ScopeAccount, http.HandlerFunc(cfg.HandleListWebhookEndpoints)))
+	apiMux.Handle("DELETE /webhooks/{endpointID}", cfg.requireScope(auth.ScopeAccount, http.HandlerFunc(cfg.HandleDeleteWebhookEndpoint)))
+	apiMux.Handle("GET /webhooks/{endpointID}/deliveries", cfg.requireScope(auth.ScopeAccount, http.HandlerFunc(cfg.HandleListWebhookDeliveries)))
+	apiMux.Handle("POST /webhooks/{endpointID}/deliveries/{deliveryID}/redeliver", cfg.requireScope(auth.ScopeAccount, http.HandlerFunc(cfg.HandleRedeliverWebhook)))
+
+	adminMux.Handle("GET /metrics", cfg.adminOnly(http.HandlerFunc(cfg.fileServerHits)))
+	adminMux.Handle("POST /reset", cfg.adminOnly(http.HandlerFunc(cfg.fileServerReset)))
+	adminMux.Handle("GET /webhooks/events", cfg.adminOnly(http.HandlerFunc(cfg.HandleListWebhookEvents)))
+	adminMux.Handle("POST /webhooks/events/{eventID}/replay", cfg.adminOnly(http.HandlerFunc(cfg.HandleReplayWebhookEvent)))
+	adminMux.Handle("POST /webhooks/endpoints", cfg.adminOnly(http.HandlerFunc(cfg.HandleCreateWebhookEndpoint)))
+	adminMux.Handle("GET /webhooks/endpoints", cfg.adminOnly(http.HandlerFunc(cfg.HandleListWebhookEndpoints)))
+	adminMux.Handle("DELETE /webhooks/endpoints/{endpointID}", cfg.adminOnly(http.HandlerFunc(cfg.HandleDeleteWebhookEndpoint)))
+	adminMux.Handle("GET /webhooks/endpoints/{endpointID}/deliveries", cfg.adminOnly(http.HandlerFunc(cfg.HandleListWebhookDeliveries)))
+	adminMux.Handle("POST /webhooks/endpoints/{endpointID}/deliveries/{deliveryID}/redeliver", cfg.adminOnly(http.HandlerFunc(cfg.HandleRedeliverWebhook)))
+	adminMux.Handle("GET /analytics", cfg.adminOnly(http.HandlerFunc(cfg.HandleGetAnalytics)))
+	adminMux.Handle("POST /entitlements", cfg.adminOnly(http.HandlerFunc(cfg.HandleGrantEntitlement)))
+	adminMux.Handle("DELETE /entitlements/{grantID}", cfg.adminOnly(http.HandlerFunc(cfg.HandleRevokeEntitlement)))
+	adminMux.Handle("GET /users/{userID}/entitlements", cfg.adminOnly(http.HandlerFunc(cfg.HandleGetUserEntitlements)))
+
+	mux.Handle("/api/", http.StripPrefix("/api", cfg.rateLimit(limits.Default, recordRoute("/api", apiMux))))
+	mux.Handle("/admin/", http.StripPrefix("/admin", recordRoute("/admin", adminMux)))
+	mux.Handle("GET /metrics", cfg.metrics.Handler())
+
+	return recordRoute("", mux)
+}
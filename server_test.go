@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ireoluwa12345/chirpy/internal/analytics"
+	"github.com/ireoluwa12345/chirpy/internal/auth"
+	"github.com/ireoluwa12345/chirpy/internal/billing"
+	"github.com/ireoluwa12345/chirpy/internal/billing/billingtest"
+	"github.com/ireoluwa12345/chirpy/internal/database"
+	"github.com/ireoluwa12345/chirpy/internal/metrics"
+	"github.com/ireoluwa12345/chirpy/internal/migrate"
+	"github.com/ireoluwa12345/chirpy/internal/ratelimit"
+	"github.com/ireoluwa12345/chirpy/internal/views"
+	"github.com/ireoluwa12345/chirpy/internal/webhook"
+)
+
+const (
+	testJWTSecret = "test-secret-that-is-long-enough-to-use"
+	testAdminKey  = "test-admin-api-key"
+
+	testBillingSecret = "test-billing-secret"
+)
+
+// stubConnector is a database/sql connector for tests without Postgres.
+// With a nil err every query returns no rows and every statement changes
+// nothing, so handlers take their not-found paths; otherwise every
+// statement fails with err.
+type stubConnector struct{ err error }
+
+func (c stubConnector) Connect(context.Context) (driver.Conn, error) { return stubConn(c), nil }
+func (c stubConnector) Driver() driver.Driver                        { return stubDriver{c} }
+
+type stubDriver struct{ c stubConnector }
+
+func (d stubDriver) Open(string) (driver.Conn, error) { return stubConn(d.c), nil }
+
+type stubConn struct{ err error }
+
+func (c stubConn) Prepare(string) (driver.Stmt, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return stubStmt{}, nil
+}
+
+func (c stubConn) Close() error { return nil }
+
+func (c stubConn) Begin() (driver.Tx, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return stubTx{}, nil
+}
+
+type stubStmt struct{}
+
+func (stubStmt) Close() error                               { return nil }
+func (stubStmt) NumInput() int                              { return -1 }
+func (stubStmt) Exec([]driver.Value) (driver.Result, error) { return driver.RowsAffected(0), nil }
+func (stubStmt) Query([]driver.Value) (driver.Rows, error)  { return stubRows{}, nil }
+
+type stubRows struct{}
+
+func (stubRows) Columns() []string         { return nil }
+func (stubRows) Close() error              { return nil }
+func (stubRows) Next([]driver.Value) error { return io.EOF }
+
+type stubTx struct{}
+
+func (stubTx) Commit() error   { return nil }
+func (stubTx) Rollback() error { return nil }
+
+var errDatabaseDown = errors.New("database is down")
+
+// newTestAPI returns an apiConfig backed by db and the server's handler,
+// wired the way serve wires them.
+func newTestAPI(t *testing.T, db *sql.DB) (*apiConfig, http.Handler) {
+	t.Helper()
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := migrate.New(db, migrations())
+	if err != nil {
+		t.Fatal(err)
+	}
+	queries := database.New(db)
+
+	cfg := &apiConfig{
+		db:        queries,
+		dbPool:    db,
+		migrator:  migrator,
+		jwtSecret: testJWTSecret,
+		adminKey:  testAdminKey,
+
+		accessTokenExpiry:  time.Hour,
+		refreshTokenExpiry: 24 * time.Hour,
+
+		limiter:        ratelimit.NewMemoryLimiter(),
+		passwordPolicy: auth.PasswordPolicy{MinLength: 12},
+
+		billingProviders: map[string]billing.Provider{
+			"polka": billing.NewPolka("polka-key", nil),
+			"fake":  billingtest.NewFake(testBillingSecret),
+		},
+
+		webhookSender: webhook.NewSender(time.Second),
+		metrics:       metrics.New(),
+
+		analytics:      analytics.NewBuffer(analytics.NewPostgresStore(queries)),
+		analyticsStore: analytics.NewPostgresStore(queries),
+
+		views: views.NewCounter(views.NewPostgresStore(queries), time.Minute, 1000),
+	}
+
+	mux := cfg.routes(rateLimits{
+		Default:     ratelimit.Rule{Name: "default", Limit: 1000, Period: time.Minute},
+		Login:       ratelimit.Rule{Name: "login", Limit: 3, Period: time.Minute},
+		CreateChirp: ratelimit.Rule{Name: "create_chirp", Limit: 1000, Period: time.Minute},
+	}, cfg.readinessChecker(nil))
+
+	return cfg, requestID(cfg.instrument(cfg.timeout(5*time.Second, mux)))
+}
+
+// testToken returns an access token for a random user.
+func testToken(t *testing.T) string {
+	t.Helper()
+	token, err := auth.MakeJWT(uuid.New(), testJWTSecret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
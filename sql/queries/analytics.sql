@@ -0,0 +1,42 @@
+-- name: AddAnalyticsHits :exec
+INSERT INTO analytics_hits (granularity, bucket_start, path, hits)
+VALUES ('minute', $1, $2, $3)
+ON CONFLICT (granularity, bucket_start, path) DO UPDATE
+SET hits = analytics_hits.hits + EXCLUDED.hits;
+
+-- name: RollUpAnalyticsHits :execrows
+-- Moves buckets of from_granularity older than before into buckets of
+-- to_granularity, truncated in UTC.
+WITH rolled AS (
+    DELETE FROM analytics_hits
+    WHERE analytics_hits.granularity = @from_granularity AND analytics_hits.bucket_start < @before
+    RETURNING bucket_start, path, hits
+)
+INSERT INTO analytics_hits (granularity, bucket_start, path, hits)
+SELECT @to_granularity::text, date_trunc(@to_granularity::text, rolled.bucket_start AT TIME ZONE 'UTC') AT TIME ZONE 'UTC', rolled.path, SUM(rolled.hits)::bigint
+FROM rolled
+GROUP BY 2, 3
+ON CONFLICT (granularity, bucket_start, path) DO UPDATE
+SET hits = analytics_hits.hits + EXCLUDED.hits;
+
+-- name: GetAnalyticsHits :many
+SELECT (date_trunc(@granularity::text, bucket_start AT TIME ZONE 'UTC') AT TIME ZONE 'UTC')::timestamptz AS bucket, path, SUM(hits)::bigint AS hits
+FROM analytics_hits
+WHERE bucket_start >= @from_time AND bucket_start < @to_time
+  AND (sqlc.narg('path')::text IS NULL OR path = sqlc.narg('path')::text)
+GROUP BY 1, 2
+ORDER BY 1, 2;
+
+-- name: CountAnalyticsHits :one
+SELECT COALESCE(SUM(hits), 0)::bigint AS hits
+FROM analytics_hits
+WHERE starts_with(path, @prefix::text);
+
+-- name: DeleteAnalyticsHits :one
+WITH deleted AS (
+    DELETE FROM analytics_hits
+    WHERE starts_with(path, @prefix::text)
+    RETURNING hits
+)
+SELECT COALESCE(SUM(hits), 0)::bigint AS hits
+FROM deleted;
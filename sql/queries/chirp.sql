@@ -1,19 +1,67 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, user_id, body)
+INSERT INTO chirps (id, created_at, updated_at, user_id, body, media_urls)
 VALUES (
-    $1, NOW(), NOW(), $2, $3
+    $1, NOW(), NOW(), $2, $3, $4
 )
 RETURNING *;
 
 -- name: GetChirps :many
-SELECT id, created_at, updated_at, user_id, body
-FROM chirps ORDER BY created_at DESC;
+SELECT id, created_at, updated_at, user_id, body, media_urls, view_count
+FROM chirps
+WHERE (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+  AND (sqlc.narg('after_created_at')::timestamptz IS NULL
+       OR (@ascending::bool AND (created_at, id) > (sqlc.narg('after_created_at')::timestamptz, sqlc.narg('after_id')::uuid))
+       OR (NOT @ascending::bool AND (created_at, id) < (sqlc.narg('after_created_at')::timestamptz, sqlc.narg('after_id')::uuid)))
+ORDER BY
+    CASE WHEN @ascending::bool THEN created_at END ASC,
+    CASE WHEN @ascending::bool THEN id END ASC,
+    created_at DESC,
+    id DESC
+LIMIT sqlc.narg('row_limit')::int
+OFFSET @row_offset::int;
 
 -- name: GetChirpByID :one
-SELECT id, created_at, updated_at, user_id, body
+SELECT id, created_at, updated_at, user_id, body, media_urls, view_count
 FROM chirps
 WHERE id = $1;
 
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
 -- name: DeleteChirp :exec
 DELETE FROM chirps
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: DeleteChirpsByUser :many
+DELETE FROM chirps
+WHERE user_id = $1
+RETURNING id;
+
+-- name: PinChirp :exec
+INSERT INTO pinned_chirps (user_id, chirp_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: UnpinChirp :execrows
+DELETE FROM pinned_chirps
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: CountPinnedChirps :one
+SELECT COUNT(*) FROM pinned_chirps
+WHERE user_id = $1;
+
+-- name: IsChirpPinned :one
+SELECT EXISTS (
+    SELECT 1 FROM pinned_chirps
+    WHERE user_id = $1 AND chirp_id = $2
+);
+
+-- name: GetPinnedChirps :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.user_id, chirps.body, chirps.media_urls, chirps.view_count
+FROM chirps
+JOIN pinned_chirps ON pinned_chirps.chirp_id = chirps.id
+WHERE pinned_chirps.user_id = $1
+ORDER BY pinned_chirps.created_at DESC;
@@ -0,0 +1,16 @@
+-- name: AddChirpViews :exec
+WITH counted AS (
+    UPDATE chirps
+    SET view_count = view_count + @views::bigint
+    WHERE id = @chirp_id
+    RETURNING id
+)
+INSERT INTO chirp_daily_views (chirp_id, day, views)
+SELECT id, @day::date, @views::bigint FROM counted
+ON CONFLICT (chirp_id, day) DO UPDATE
+SET views = chirp_daily_views.views + EXCLUDED.views;
+
+-- name: GetChirpDailyViews :many
+SELECT day, views FROM chirp_daily_views
+WHERE chirp_id = $1 AND day >= @since::date
+ORDER BY day;
@@ -0,0 +1,21 @@
+-- name: CreateEntitlementGrant :one
+INSERT INTO entitlement_grants (id, created_at, user_id, feature, reason, expires_at)
+VALUES (
+    $1, NOW(), $2, $3, $4, $5
+)
+RETURNING *;
+
+-- name: ListActiveEntitlementFeatures :many
+SELECT feature FROM entitlement_grants
+WHERE user_id = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW());
+
+-- name: ListEntitlementGrants :many
+SELECT * FROM entitlement_grants
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: RevokeEntitlementGrant :one
+UPDATE entitlement_grants
+SET revoked_at = NOW()
+WHERE id = $1 AND revoked_at IS NULL
+RETURNING *;
@@ -0,0 +1,71 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (id, created_at, updated_at, owner_id, name, secret_hash, redirect_uris)
+VALUES (
+    $1, NOW(), NOW(), $2, $3, $4, $5
+)
+RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT id, created_at, updated_at, owner_id, name, secret_hash, redirect_uris
+FROM oauth_clients
+WHERE id = $1;
+
+-- name: CreateOAuthAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes (code, created_at, client_id, user_id, redirect_uri, scope, code_challenge, expires_at)
+VALUES (
+    $1, NOW(), $2, $3, $4, $5, $6, $7
+);
+
+-- name: ConsumeOAuthAuthorizationCode :one
+UPDATE oauth_authorization_codes
+SET used_at = NOW()
+WHERE code = $1 AND used_at IS NULL AND expires_at > NOW()
+RETURNING *;
+
+-- name: UpsertOAuthGrant :one
+INSERT INTO oauth_grants (client_id, user_id, created_at, updated_at, scope, revoked_at)
+VALUES (
+    $1, $2, NOW(), NOW(), $3, NULL
+)
+ON CONFLICT (client_id, user_id) DO UPDATE
+SET scope = EXCLUDED.scope, updated_at = NOW(), revoked_at = NULL
+RETURNING *;
+
+-- name: ListOAuthGrantsForUser :many
+SELECT oauth_grants.client_id, oauth_clients.name AS client_name, oauth_grants.scope, oauth_grants.created_at, oauth_grants.updated_at
+FROM oauth_grants
+JOIN oauth_clients ON oauth_clients.id = oauth_grants.client_id
+WHERE oauth_grants.user_id = $1 AND oauth_grants.revoked_at IS NULL
+ORDER BY oauth_grants.created_at;
+
+-- name: RevokeOAuthGrant :execrows
+UPDATE oauth_grants
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE client_id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: CreateOAuthRefreshToken :one
+INSERT INTO oauth_refresh_tokens (token, created_at, updated_at, client_id, user_id, scope, expires_at, revoked_at)
+VALUES (
+    $1, NOW(), NOW(), $2, $3, $4, $5, NULL
+)
+RETURNING *;
+
+-- name: CheckOAuthRefreshToken :one
+SELECT token, created_at, updated_at, client_id, user_id, scope, expires_at, revoked_at
+FROM oauth_refresh_tokens
+WHERE token = $1 AND revoked_at IS NULL AND expires_at > NOW();
+
+-- name: RevokeOAuthRefreshToken :execrows
+UPDATE oauth_refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE token = $1 AND client_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeOAuthRefreshTokensForGrant :exec
+UPDATE oauth_refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE client_id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeOAuthRefreshTokensForUser :execrows
+UPDATE oauth_refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
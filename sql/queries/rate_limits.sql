@@ -0,0 +1,18 @@
+-- name: TakeRateLimitToken :one
+INSERT INTO rate_limit_buckets (key, tokens, allowed, updated_at)
+VALUES (
+    @key, @capacity::DOUBLE PRECISION - 1, TRUE, NOW()
+)
+ON CONFLICT (key) DO UPDATE
+SET allowed = LEAST(@capacity::DOUBLE PRECISION, rate_limit_buckets.tokens + EXTRACT(EPOCH FROM NOW() - rate_limit_buckets.updated_at) * @refill_per_second::DOUBLE PRECISION) >= 1,
+    tokens = CASE
+        WHEN LEAST(@capacity::DOUBLE PRECISION, rate_limit_buckets.tokens + EXTRACT(EPOCH FROM NOW() - rate_limit_buckets.updated_at) * @refill_per_second::DOUBLE PRECISION) >= 1
+        THEN LEAST(@capacity::DOUBLE PRECISION, rate_limit_buckets.tokens + EXTRACT(EPOCH FROM NOW() - rate_limit_buckets.updated_at) * @refill_per_second::DOUBLE PRECISION) - 1
+        ELSE LEAST(@capacity::DOUBLE PRECISION, rate_limit_buckets.tokens + EXTRACT(EPOCH FROM NOW() - rate_limit_buckets.updated_at) * @refill_per_second::DOUBLE PRECISION)
+    END,
+    updated_at = NOW()
+RETURNING tokens, allowed;
+
+-- name: DeleteStaleRateLimitBuckets :exec
+DELETE FROM rate_limit_buckets
+WHERE updated_at < @before;
@@ -12,4 +12,9 @@ WHERE token = $1 AND revoked IS NULL AND expires_at > NOW();
 -- name: RevokeRefreshToken :exec
 UPDATE refresh_tokens
 SET revoked = NOW(), updated_at = NOW()
-WHERE token = $1;
\ No newline at end of file
+WHERE token = $1;
+
+-- name: RevokeRefreshTokensForUser :execrows
+UPDATE refresh_tokens
+SET revoked = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked IS NULL;
\ No newline at end of file
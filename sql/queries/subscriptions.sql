@@ -0,0 +1,27 @@
+-- name: UpsertSubscription :one
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, current_period_end, grace_period_end)
+VALUES (
+    $1, NOW(), NOW(), $2, $3, 'active', $4, NULL
+)
+ON CONFLICT (user_id) DO UPDATE
+SET plan = EXCLUDED.plan, status = 'active', current_period_end = EXCLUDED.current_period_end, grace_period_end = NULL, updated_at = NOW()
+RETURNING *;
+
+-- name: MarkSubscriptionPastDue :one
+UPDATE subscriptions
+SET status = 'past_due', grace_period_end = COALESCE(grace_period_end, $2), updated_at = NOW()
+WHERE user_id = $1 AND status IN ('active', 'past_due')
+RETURNING *;
+
+-- name: EndSubscription :one
+UPDATE subscriptions
+SET status = $2, grace_period_end = NULL, updated_at = NOW()
+WHERE user_id = $1
+RETURNING *;
+
+-- name: ExpireLapsedSubscriptions :many
+UPDATE subscriptions
+SET status = 'expired', updated_at = NOW()
+WHERE (status = 'active' AND current_period_end <= NOW())
+   OR (status = 'past_due' AND COALESCE(grace_period_end, current_period_end) <= NOW())
+RETURNING user_id;
@@ -0,0 +1,26 @@
+-- name: CreateUserIdentity :one
+INSERT INTO user_identities (id, created_at, updated_at, user_id, provider, subject, email)
+VALUES (
+    $1, NOW(), NOW(), $2, $3, $4, $5
+)
+RETURNING *;
+
+-- name: GetUserIdentity :one
+SELECT id, created_at, updated_at, user_id, provider, subject, email
+FROM user_identities
+WHERE provider = $1 AND subject = $2;
+
+-- name: DeleteOIDCLinkStatesForUser :exec
+DELETE FROM oidc_link_states
+WHERE user_id = $1;
+
+-- name: CreateOIDCLinkState :exec
+INSERT INTO oidc_link_states (state, created_at, user_id, provider, nonce, code_verifier, expires_at)
+VALUES (
+    $1, NOW(), $2, $3, $4, $5, $6
+);
+
+-- name: ConsumeOIDCLinkState :one
+DELETE FROM oidc_link_states
+WHERE state = $1 AND provider = $2 AND expires_at > NOW()
+RETURNING *;
@@ -6,7 +6,7 @@ VALUES (
 RETURNING *;
 
 -- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, password, is_chirpy_red
+SELECT id, created_at, updated_at, email, password, is_chirpy_red, disabled_at
 FROM users
 WHERE email = $1;
 
@@ -16,8 +16,38 @@ SET updated_at = NOW(), email = $1, password = $2
 WHERE id = $3
 RETURNING *;
 
--- name: UpgradeUser :one
+-- name: SyncUserChirpyRed :one
 UPDATE users
-SET updated_at = NOW(), is_chirpy_red = TRUE
+SET updated_at = NOW(), is_chirpy_red = EXISTS (
+    SELECT 1 FROM subscriptions
+    WHERE subscriptions.user_id = users.id
+      AND (
+        (subscriptions.status = 'active' AND subscriptions.current_period_end > NOW())
+        OR (subscriptions.status = 'past_due' AND subscriptions.grace_period_end > NOW())
+      )
+)
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET updated_at = NOW(), password = $2
+WHERE id = $1;
+
+-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, password, is_chirpy_red, disabled_at
+FROM users
+WHERE id = $1;
+
+
+-- name: DisableUser :one
+UPDATE users
+SET updated_at = NOW(), disabled_at = COALESCE(disabled_at, NOW())
+WHERE id = $1
+RETURNING *;
+
+-- name: EnableUser :one
+UPDATE users
+SET updated_at = NOW(), disabled_at = NULL
 WHERE id = $1
 RETURNING *;
\ No newline at end of file
@@ -0,0 +1,68 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, created_at, updated_at, owner_id, url, secret, events, active)
+VALUES (
+    $1, NOW(), NOW(), $2, $3, $4, $5, TRUE
+)
+RETURNING *;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints
+WHERE id = $1;
+
+-- name: ListWebhookEndpoints :many
+SELECT * FROM webhook_endpoints
+WHERE owner_id IS NOT DISTINCT FROM sqlc.narg('owner_id')
+ORDER BY created_at;
+
+-- name: DeleteWebhookEndpoint :exec
+DELETE FROM webhook_endpoints
+WHERE id = $1;
+
+-- name: EnqueueWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (id, created_at, updated_at, endpoint_id, event_id, event_type, payload, status, attempts, next_attempt_at, trace_context)
+SELECT gen_random_uuid(), NOW(), NOW(), webhook_endpoints.id, @event_id, @event_type, @payload, 'pending', 0, NOW(), @trace_context
+FROM webhook_endpoints
+WHERE webhook_endpoints.active
+  AND @event_type::text = ANY(webhook_endpoints.events)
+  AND (webhook_endpoints.owner_id IS NULL OR webhook_endpoints.owner_id = sqlc.narg('owner_id'));
+
+-- name: ClaimWebhookDeliveries :many
+-- Claimed deliveries are leased for five minutes; if the worker dies they
+-- become due again once the lease runs out.
+UPDATE webhook_deliveries
+SET attempts = attempts + 1, next_attempt_at = NOW() + INTERVAL '5 minutes', updated_at = NOW()
+WHERE id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkWebhookDeliverySucceeded :exec
+UPDATE webhook_deliveries
+SET status = 'succeeded', last_status_code = $2, last_error = NULL, delivered_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: MarkWebhookDeliveryFailed :exec
+UPDATE webhook_deliveries
+SET status = $2, next_attempt_at = $3, last_status_code = $4, last_error = $5, updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries
+WHERE id = $1;
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
+
+-- name: RedeliverWebhookDelivery :one
+INSERT INTO webhook_deliveries (id, created_at, updated_at, endpoint_id, event_id, event_type, payload, status, attempts, next_attempt_at, trace_context)
+SELECT @new_id, NOW(), NOW(), endpoint_id, event_id, event_type, payload, 'pending', 0, NOW(), trace_context
+FROM webhook_deliveries
+WHERE webhook_deliveries.id = @delivery_id
+RETURNING *;
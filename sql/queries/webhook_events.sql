@@ -0,0 +1,46 @@
+-- name: CreateWebhookEvent :one
+INSERT INTO webhook_events (id, created_at, updated_at, provider, event_id, event_type, payload, status, attempts)
+VALUES (
+    $1, NOW(), NOW(), $2, $3, $4, $5, 'received', 0
+)
+ON CONFLICT (provider, event_id) DO NOTHING
+RETURNING *;
+
+-- name: GetWebhookEvent :one
+SELECT id, created_at, updated_at, provider, event_id, event_type, payload, status, error, attempts, processed_at
+FROM webhook_events
+WHERE provider = $1 AND event_id = $2;
+
+-- name: GetWebhookEventByID :one
+SELECT id, created_at, updated_at, provider, event_id, event_type, payload, status, error, attempts, processed_at
+FROM webhook_events
+WHERE id = $1;
+
+-- name: ListWebhookEvents :many
+SELECT id, created_at, updated_at, provider, event_id, event_type, payload, status, error, attempts, processed_at
+FROM webhook_events
+WHERE sqlc.narg('status')::TEXT IS NULL OR status = sqlc.narg('status')::TEXT
+ORDER BY created_at DESC
+LIMIT @max_results;
+
+-- name: ClaimWebhookEvent :one
+-- Marks an event as being processed if its status is one of statuses, or
+-- if an earlier attempt has been stuck processing for five minutes.
+UPDATE webhook_events
+SET status = 'processing', attempts = attempts + 1, updated_at = NOW()
+WHERE id = @id
+  AND (status = ANY(@statuses::TEXT[])
+       OR (status = 'processing' AND updated_at < NOW() - INTERVAL '5 minutes'))
+RETURNING *;
+
+-- name: MarkWebhookEventProcessed :one
+UPDATE webhook_events
+SET status = $2, error = NULL, processed_at = NOW(), updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: MarkWebhookEventFailed :one
+UPDATE webhook_events
+SET status = 'failed', error = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
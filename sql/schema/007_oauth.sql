@@ -0,0 +1,64 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE oauth_clients(
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    owner_id UUID NOT NULL,
+    name TEXT NOT NULL,
+    secret_hash TEXT,
+    redirect_uris TEXT[] NOT NULL,
+
+    foreign key (owner_id) references users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE oauth_authorization_codes(
+    code TEXT PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    client_id TEXT NOT NULL,
+    user_id UUID NOT NULL,
+    redirect_uri TEXT NOT NULL,
+    scope TEXT NOT NULL,
+    code_challenge TEXT NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+
+    foreign key (client_id) references oauth_clients(id) ON DELETE CASCADE,
+    foreign key (user_id) references users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE oauth_grants(
+    client_id TEXT NOT NULL,
+    user_id UUID NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    scope TEXT NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+
+    PRIMARY KEY (client_id, user_id),
+    foreign key (client_id) references oauth_clients(id) ON DELETE CASCADE,
+    foreign key (user_id) references users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE oauth_refresh_tokens(
+    token TEXT PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    client_id TEXT NOT NULL,
+    user_id UUID NOT NULL,
+    scope TEXT NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+
+    foreign key (client_id) references oauth_clients(id) ON DELETE CASCADE,
+    foreign key (user_id) references users(id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS oauth_refresh_tokens;
+DROP TABLE IF EXISTS oauth_grants;
+DROP TABLE IF EXISTS oauth_authorization_codes;
+DROP TABLE IF EXISTS oauth_clients;
+-- +goose StatementEnd
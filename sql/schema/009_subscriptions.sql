@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE subscriptions(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    user_id UUID NOT NULL UNIQUE,
+    plan TEXT NOT NULL,
+    status TEXT NOT NULL,
+    current_period_end TIMESTAMP WITH TIME ZONE NOT NULL,
+    grace_period_end TIMESTAMP WITH TIME ZONE,
+
+    foreign key (user_id) references users(id) ON DELETE CASCADE
+);
+
+-- Upgrades before subscriptions existed never lapsed; keep them active
+-- until Polka tells us otherwise.
+INSERT INTO subscriptions (id, user_id, plan, status, current_period_end)
+SELECT gen_random_uuid(), id, 'chirpy_red', 'active', '9999-12-31 00:00:00+00'
+FROM users
+WHERE is_chirpy_red;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS subscriptions;
+-- +goose StatementEnd
@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE entitlement_grants(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    user_id UUID NOT NULL,
+    feature TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+
+    foreign key (user_id) references users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX entitlement_grants_user_id_idx ON entitlement_grants(user_id);
+
+CREATE TABLE pinned_chirps(
+    user_id UUID NOT NULL,
+    chirp_id UUID NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (user_id, chirp_id),
+    foreign key (user_id) references users(id) ON DELETE CASCADE,
+    foreign key (chirp_id) references chirps(id) ON DELETE CASCADE
+);
+
+ALTER TABLE chirps ADD COLUMN media_urls TEXT[] NOT NULL DEFAULT '{}';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE chirps DROP COLUMN IF EXISTS media_urls;
+DROP TABLE IF EXISTS pinned_chirps;
+DROP TABLE IF EXISTS entitlement_grants;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE analytics_hits(
+    granularity TEXT NOT NULL,
+    bucket_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    path TEXT NOT NULL,
+    hits BIGINT NOT NULL,
+
+    PRIMARY KEY (granularity, bucket_start, path)
+);
+
+CREATE INDEX analytics_hits_bucket_start_idx ON analytics_hits (bucket_start);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS analytics_hits;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE chirps ADD COLUMN view_count BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE chirp_daily_views(
+    chirp_id UUID NOT NULL,
+    day DATE NOT NULL,
+    views BIGINT NOT NULL,
+
+    PRIMARY KEY (chirp_id, day),
+    foreign key (chirp_id) references chirps(id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS chirp_daily_views;
+ALTER TABLE chirps DROP COLUMN IF EXISTS view_count;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The W3C trace context of the request that caused the event, so the
+-- delivery can be traced as part of it.
+ALTER TABLE webhook_deliveries ADD COLUMN trace_context JSONB NOT NULL DEFAULT '{}';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE webhook_deliveries DROP COLUMN IF EXISTS trace_context;
+-- +goose StatementEnd
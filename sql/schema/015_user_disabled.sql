@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Disabled users can't log in; set and cleared by "chirpy user disable"
+-- and "chirpy user enable".
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMP WITH TIME ZONE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN IF EXISTS disabled_at;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- GET /api/chirps pages through chirps in (created_at, id) order, over
+-- everyone's or one author's.
+CREATE INDEX chirps_created_at_id_idx ON chirps (created_at, id);
+CREATE INDEX chirps_user_id_created_at_id_idx ON chirps (user_id, created_at, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS chirps_user_id_created_at_id_idx;
+DROP INDEX IF EXISTS chirps_created_at_id_idx;
+-- +goose StatementEnd
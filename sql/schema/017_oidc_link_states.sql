@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Pending identity links, keyed by the OIDC state parameter. Linking is
+-- started by an API client and finished by whichever browser opens the
+-- authorization URL, so the state can't live in a cookie.
+CREATE TABLE oidc_link_states(
+    state TEXT PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    user_id UUID NOT NULL,
+    provider TEXT NOT NULL,
+    nonce TEXT NOT NULL,
+    code_verifier TEXT NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+
+    foreign key (user_id) references users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX oidc_link_states_user_id_idx ON oidc_link_states (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS oidc_link_states;
+-- +goose StatementEnd
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ireoluwa12345/chirpy/internal/database"
+	"github.com/ireoluwa12345/chirpy/internal/health"
+)
+
+// Statuses of a Chirpy Red subscription. A user is Chirpy Red while their
+// subscription is active and paid up, or past due but inside its grace
+// period; users.is_chirpy_red caches that and is kept in sync by
+// SyncUserChirpyRed.
+const (
+	subscriptionActive   = "active"
+	subscriptionPastDue  = "past_due"
+	subscriptionCanceled = "canceled"
+	subscriptionRefunded = "refunded"
+	subscriptionExpired  = "expired"
+)
+
+const (
+	defaultSubscriptionPlan   = "chirpy_red"
+	defaultSubscriptionPeriod = 30 * 24 * time.Hour
+)
+
+// activateSubscription starts or renews a subscription until periodEnd.
+func (cfg *apiConfig) activateSubscription(ctx context.Context, userID uuid.UUID, plan string, periodEnd time.Time) error {
+	_, err := cfg.db.UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+		ID:               uuid.New(),
+		UserID:           userID,
+		Plan:             plan,
+		CurrentPeriodEnd: periodEnd,
+	})
+	if err != nil {
+		return err
+	}
+
+	return cfg.syncChirpyRed(ctx, userID)
+}
+
+// markSubscriptionPastDue starts the grace period after a failed payment.
+// Repeated failures don't extend it.
+func (cfg *apiConfig) markSubscriptionPastDue(ctx context.Context, userID uuid.UUID) error {
+	_, err := cfg.db.MarkSubscriptionPastDue(ctx, database.MarkSubscriptionPastDueParams{
+		UserID:         userID,
+		GracePeriodEnd: sql.NullTime{Time: time.Now().Add(cfg.subscriptionGracePeriod), Valid: true},
+	})
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	return cfg.syncChirpyRed(ctx, userID)
+}
+
+// endSubscription ends a subscription immediately with status.
+func (cfg *apiConfig) endSubscription(ctx context.Context, userID uuid.UUID, status string) error {
+	_, err := cfg.db.EndSubscription(ctx, database.EndSubscriptionParams{
+		UserID: userID,
+		Status: status,
+	})
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	return cfg.syncChirpyRed(ctx, userID)
+}
+
+// syncChirpyRed recomputes users.is_chirpy_red and tells integrators when
+// it changes.
+func (cfg *apiConfig) syncChirpyRed(ctx context.Context, userID uuid.UUID) error {
+	before, err := cfg.db.GetUserByID(ctx, userID)
+	if err == sql.ErrNoRows {
+		return errWebhookUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	user, err := cfg.db.SyncUserChirpyRed(ctx, userID)
+	if err == sql.ErrNoRows {
+		return errWebhookUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if user.IsChirpyRed != before.IsChirpyRed {
+		eventType := eventUserUpgraded
+		if !user.IsChirpyRed {
+			eventType = eventUserDowngraded
+		}
+		cfg.emitEvent(ctx, eventType, user.ID, map[string]any{
+			"user_id":       user.ID,
+			"is_chirpy_red": user.IsChirpyRed,
+		})
+	}
+
+	return nil
+}
+
+// runSubscriptionExpiry expires lapsed subscriptions every interval until
+// ctx is cancelled, beating heartbeat after each run.
+func (cfg *apiConfig) runSubscriptionExpiry(ctx context.Context, interval time.Duration, heartbeat *health.Heartbeat) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		cfg.expireLapsedSubscriptions(ctx)
+		heartbeat.Beat()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *apiConfig) expireLapsedSubscriptions(ctx context.Context) {
+	userIDs, err := cfg.db.ExpireLapsedSubscriptions(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "error expiring subscriptions", "error", err)
+		return
+	}
+
+	for _, userID := range userIDs {
+		if err := cfg.syncChirpyRed(ctx, userID); err != nil {
+			slog.ErrorContext(ctx, "error revoking Chirpy Red", "user_id", userID, "error", err)
+		}
+	}
+}
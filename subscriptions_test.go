@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ireoluwa12345/chirpy/internal/database"
+	"github.com/ireoluwa12345/chirpy/internal/health"
+)
+
+func TestSubscriptionLifecycle(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		// apply changes the subscription, starting from an active one.
+		apply     func(cfg *apiConfig, userID uuid.UUID) error
+		wantSub   string
+		wantGrace bool
+		wantRed   bool
+	}{
+		{
+			name:    "activated",
+			apply:   func(*apiConfig, uuid.UUID) error { return nil },
+			wantSub: subscriptionActive, wantRed: true,
+		},
+		{
+			name: "payment failed",
+			apply: func(cfg *apiConfig, userID uuid.UUID) error {
+				return cfg.markSubscriptionPastDue(ctx, userID)
+			},
+			wantSub: subscriptionPastDue, wantGrace: true, wantRed: true,
+		},
+		{
+			name: "renewed after a failed payment",
+			apply: func(cfg *apiConfig, userID uuid.UUID) error {
+				if err := cfg.markSubscriptionPastDue(ctx, userID); err != nil {
+					return err
+				}
+				return cfg.activateSubscription(ctx, userID, defaultSubscriptionPlan, time.Now().Add(defaultSubscriptionPeriod))
+			},
+			wantSub: subscriptionActive, wantRed: true,
+		},
+		{
+			name: "canceled",
+			apply: func(cfg *apiConfig, userID uuid.UUID) error {
+				return cfg.endSubscription(ctx, userID, subscriptionCanceled)
+			},
+			wantSub: subscriptionCanceled,
+		},
+		{
+			name: "canceled while past due",
+			apply: func(cfg *apiConfig, userID uuid.UUID) error {
+				if err := cfg.markSubscriptionPastDue(ctx, userID); err != nil {
+					return err
+				}
+				return cfg.endSubscription(ctx, userID, subscriptionCanceled)
+			},
+			wantSub: subscriptionCanceled,
+		},
+		{
+			name: "refunded",
+			apply: func(cfg *apiConfig, userID uuid.UUID) error {
+				return cfg.endSubscription(ctx, userID, subscriptionRefunded)
+			},
+			wantSub: subscriptionRefunded,
+		},
+		{
+			name: "payment failed after cancellation",
+			apply: func(cfg *apiConfig, userID uuid.UUID) error {
+				if err := cfg.endSubscription(ctx, userID, subscriptionCanceled); err != nil {
+					return err
+				}
+				return cfg.markSubscriptionPastDue(ctx, userID)
+			},
+			wantSub: subscriptionCanceled,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, userID := newBillingTestDB()
+			cfg, _ := newTestAPI(t, sql.OpenDB(db))
+			cfg.subscriptionGracePeriod = time.Hour
+
+			if err := cfg.activateSubscription(ctx, userID, defaultSubscriptionPlan, time.Now().Add(defaultSubscriptionPeriod)); err != nil {
+				t.Fatal(err)
+			}
+			if err := tc.apply(cfg, userID); err != nil {
+				t.Fatal(err)
+			}
+
+			sub := db.subscription(t, userID)
+			if sub.Status != tc.wantSub || sub.GracePeriodEnd.Valid != tc.wantGrace {
+				t.Errorf("subscription = %s with grace period %t, want %s with %t", sub.Status, sub.GracePeriodEnd.Valid, tc.wantSub, tc.wantGrace)
+			}
+			if red := db.user(t, userID).IsChirpyRed; red != tc.wantRed {
+				t.Errorf("is_chirpy_red = %t, want %t", red, tc.wantRed)
+			}
+		})
+	}
+}
+
+func TestSubscriptionGracePeriod(t *testing.T) {
+	ctx := context.Background()
+	db, userID := newBillingTestDB()
+	cfg, _ := newTestAPI(t, sql.OpenDB(db))
+	cfg.subscriptionGracePeriod = time.Hour
+
+	if err := cfg.activateSubscription(ctx, userID, defaultSubscriptionPlan, time.Now().Add(defaultSubscriptionPeriod)); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.markSubscriptionPastDue(ctx, userID); err != nil {
+		t.Fatal(err)
+	}
+	grace := db.subscription(t, userID).GracePeriodEnd.Time
+	if until := time.Until(grace); until <= 59*time.Minute || until > time.Hour {
+		t.Errorf("grace period ends in %s, want an hour", until)
+	}
+
+	// Repeated failures don't extend the grace period.
+	if err := cfg.markSubscriptionPastDue(ctx, userID); err != nil {
+		t.Fatal(err)
+	}
+	if again := db.subscription(t, userID).GracePeriodEnd.Time; !again.Equal(grace) {
+		t.Errorf("grace period moved from %s to %s", grace, again)
+	}
+
+	// The user stays Chirpy Red until the grace period ends.
+	cfg.expireLapsedSubscriptions(ctx)
+	if sub := db.subscription(t, userID); sub.Status != subscriptionPastDue || !db.user(t, userID).IsChirpyRed {
+		t.Fatalf("inside the grace period: subscription %s, is_chirpy_red %t; want past_due and true", sub.Status, db.user(t, userID).IsChirpyRed)
+	}
+
+	db.mu.Lock()
+	db.subscriptions[0].GracePeriodEnd.Time = time.Now().Add(-time.Second)
+	db.mu.Unlock()
+
+	cfg.expireLapsedSubscriptions(ctx)
+	if sub := db.subscription(t, userID); sub.Status != subscriptionExpired || db.user(t, userID).IsChirpyRed {
+		t.Errorf("after the grace period: subscription %s, is_chirpy_red %t; want expired and false", sub.Status, db.user(t, userID).IsChirpyRed)
+	}
+}
+
+func TestExpireLapsedSubscriptions(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		status    string
+		periodEnd time.Time
+		graceEnd  time.Time
+		wantSub   string
+		wantRed   bool
+	}{
+		{name: "active", status: subscriptionActive, periodEnd: now.Add(time.Hour), wantSub: subscriptionActive, wantRed: true},
+		{name: "active past its period", status: subscriptionActive, periodEnd: now.Add(-time.Hour), wantSub: subscriptionExpired},
+		{name: "past due in its grace period", status: subscriptionPastDue, periodEnd: now.Add(-time.Hour), graceEnd: now.Add(time.Hour), wantSub: subscriptionPastDue, wantRed: true},
+		{name: "past due after its grace period", status: subscriptionPastDue, periodEnd: now.Add(-2 * time.Hour), graceEnd: now.Add(-time.Hour), wantSub: subscriptionExpired},
+		{name: "canceled", status: subscriptionCanceled, periodEnd: now.Add(-time.Hour), wantSub: subscriptionCanceled},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, userID := newBillingTestDB()
+			db.users[0].IsChirpyRed = tc.status == subscriptionActive || tc.status == subscriptionPastDue
+			db.subscriptions = []database.Subscription{{
+				ID:               uuid.New(),
+				CreatedAt:        now,
+				UpdatedAt:        now,
+				UserID:           userID,
+				Plan:             defaultSubscriptionPlan,
+				Status:           tc.status,
+				CurrentPeriodEnd: tc.periodEnd,
+				GracePeriodEnd:   sql.NullTime{Time: tc.graceEnd, Valid: !tc.graceEnd.IsZero()},
+			}}
+			cfg, _ := newTestAPI(t, sql.OpenDB(db))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				cfg.runSubscriptionExpiry(ctx, time.Millisecond, health.NewHeartbeat(time.Minute))
+				close(done)
+			}()
+			// Once the second run has started the first one is complete.
+			for deadline := time.Now().Add(5 * time.Second); db.count("ExpireLapsedSubscriptions") < 2; time.Sleep(time.Millisecond) {
+				if time.Now().After(deadline) {
+					t.Fatal("subscriptions weren't expired")
+				}
+			}
+			cancel()
+			<-done
+
+			if sub := db.subscription(t, userID); sub.Status != tc.wantSub {
+				t.Errorf("subscription status = %s, want %s", sub.Status, tc.wantSub)
+			}
+			if red := db.user(t, userID).IsChirpyRed; red != tc.wantRed {
+				t.Errorf("is_chirpy_red = %t, want %t", red, tc.wantRed)
+			}
+		})
+	}
+}
+
+func TestSyncChirpyRed(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("unknown user", func(t *testing.T) {
+		cfg, _ := newTestAPI(t, sql.OpenDB(&fakeDB{}))
+		if err := cfg.syncChirpyRed(ctx, uuid.New()); !errors.Is(err, errWebhookUserNotFound) {
+			t.Errorf("error = %v, want %v", err, errWebhookUserNotFound)
+		}
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		cfg, _ := newTestAPI(t, sql.OpenDB(stubConnector{err: errDatabaseDown}))
+		if err := cfg.syncChirpyRed(ctx, uuid.New()); !errors.Is(err, errDatabaseDown) {
+			t.Errorf("error = %v, want %v", err, errDatabaseDown)
+		}
+	})
+
+	t.Run("stale flag", func(t *testing.T) {
+		// is_chirpy_red is recomputed from the subscriptions, whatever it
+		// said before.
+		db, userID := newBillingTestDB()
+		db.users[0].IsChirpyRed = true
+		cfg, _ := newTestAPI(t, sql.OpenDB(db))
+
+		if err := cfg.syncChirpyRed(ctx, userID); err != nil {
+			t.Fatal(err)
+		}
+		if db.user(t, userID).IsChirpyRed {
+			t.Error("user without a subscription is still Chirpy Red")
+		}
+	})
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/ireoluwa12345/chirpy/internal/database"
+	"github.com/lib/pq"
+)
+
+// statusClientClosedRequest is nginx's non-standard status for requests the
+// client abandoned before a response was written.
+const statusClientClosedRequest = 499
+
+// timeout gives every request a deadline of d. Handlers that fail because
+// the request was cancelled, ran out of time or couldn't reach the database
+// have their error response replaced with 499, 504 or 503 so those
+// failures aren't reported as server errors.
+func (cfg *apiConfig) timeout(d time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+
+		queryErr := new(error)
+		ctx = context.WithValue(ctx, "query_error", queryErr)
+
+		next.ServeHTTP(&timeoutWriter{ResponseWriter: w, ctx: ctx, queryErr: queryErr}, r.WithContext(ctx))
+	})
+}
+
+// timeoutWriter rewrites error responses caused by cancellation, timeouts
+// or an unavailable database.
+type timeoutWriter struct {
+	http.ResponseWriter
+	ctx      context.Context
+	queryErr *error
+
+	wroteHeader bool
+	replaced    bool
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	if tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+
+	if status >= http.StatusBadRequest {
+		if replacement := failureStatus(tw.ctx, *tw.queryErr); replacement != 0 {
+			tw.replaced = true
+			tw.writeFailure(replacement)
+			return
+		}
+	}
+
+	tw.ResponseWriter.WriteHeader(status)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	if !tw.wroteHeader {
+		tw.WriteHeader(http.StatusOK)
+	}
+	if tw.replaced {
+		// The handler's own error body is dropped.
+		return len(b), nil
+	}
+	return tw.ResponseWriter.Write(b)
+}
+
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+func (tw *timeoutWriter) writeFailure(status int) {
+	if status == statusClientClosedRequest {
+		// Nobody is listening for a body.
+		tw.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	tw.Header().Del("Content-Length")
+	switch status {
+	case http.StatusGatewayTimeout:
+		respondError(tw.ResponseWriter, status, errCodeTimeout, "request timed out")
+	case http.StatusServiceUnavailable:
+		tw.Header().Set("Retry-After", "5")
+		respondError(tw.ResponseWriter, status, errCodeUnavailable, "service temporarily unavailable")
+	default:
+		tw.ResponseWriter.WriteHeader(status)
+	}
+}
+
+// failureStatus returns the status for a request that failed while ctx was
+// done or after a query failed with queryErr, or 0 when the failure had
+// another cause.
+func failureStatus(ctx context.Context, queryErr error) int {
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		return statusClientClosedRequest
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case queryErr == nil:
+		return 0
+	case errors.Is(queryErr, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	}
+
+	var pqErr *pq.Error
+	if errors.As(queryErr, &pqErr) {
+		switch {
+		case pqErr.Code == "57014": // query_canceled, e.g. by statement_timeout
+			return http.StatusGatewayTimeout
+		case pqErr.Code.Class() == "53", // insufficient_resources
+			pqErr.Code.Class() == "57": // operator_intervention, e.g. shutting down
+			return http.StatusServiceUnavailable
+		}
+		return 0
+	}
+
+	var netErr net.Error
+	if errors.As(queryErr, &netErr) || errors.Is(queryErr, driver.ErrBadConn) || errors.Is(queryErr, sql.ErrConnDone) {
+		return http.StatusServiceUnavailable
+	}
+	return 0
+}
+
+// queryErrorRecorder wraps a database.DBTX to remember the last failed
+// query of each request, so timeout can tell why a handler failed.
+type queryErrorRecorder struct {
+	db database.DBTX
+}
+
+func (q queryErrorRecorder) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	result, err := q.db.ExecContext(ctx, query, args...)
+	recordQueryError(ctx, err)
+	return result, err
+}
+
+func (q queryErrorRecorder) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	stmt, err := q.db.PrepareContext(ctx, query)
+	recordQueryError(ctx, err)
+	return stmt, err
+}
+
+func (q queryErrorRecorder) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	recordQueryError(ctx, err)
+	return rows, err
+}
+
+func (q queryErrorRecorder) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	row := q.db.QueryRowContext(ctx, query, args...)
+	recordQueryError(ctx, row.Err())
+	return row
+}
+
+func recordQueryError(ctx context.Context, err error) {
+	if err == nil {
+		return
+	}
+	if holder, ok := ctx.Value("query_error").(*error); ok {
+		*holder = err
+	}
+}
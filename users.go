@@ -4,10 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
-	"fmt"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
-	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
@@ -25,16 +24,19 @@ func (cfg *apiConfig) HandleCreateUser(w http.ResponseWriter, r *http.Request) {
 	err := decoder.Decode(&params)
 
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "couldn't decode json}`))
+		respondError(w, http.StatusBadRequest, errCodeInvalidJSON, "couldn't decode json")
 		return
 	}
 
 	validate := validator.New()
 	err = validate.Struct(params)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "invalid request body"}`))
+		respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "invalid request body")
+		return
+	}
+
+	if err := cfg.passwordPolicy.Validate(params.Email, params.Password); err != nil {
+		writePasswordPolicyError(w, err)
 		return
 	}
 
@@ -42,29 +44,26 @@ func (cfg *apiConfig) HandleCreateUser(w http.ResponseWriter, r *http.Request) {
 
 	hashedPassword, err := auth.HashPassword(params.Password)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(`{"error": "error occurred"}`))
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "error occurred")
 		return
 	}
 	params.Password = hashedPassword
 
-	user, err := cfg.db.CreateUser(context.Background(), database.CreateUserParams{ID: id, Email: params.Email, Password: hashedPassword})
+	user, err := cfg.db.CreateUser(r.Context(), database.CreateUserParams{ID: id, Email: params.Email, Password: hashedPassword})
 
-	resp, err := json.Marshal(map[string]interface{}{
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error creating user", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't create user")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
 		"id":            user.ID,
 		"created_at":    user.CreatedAt,
 		"updated_at":    user.UpdatedAt,
 		"email":         user.Email,
 		"is_chirpy_red": user.IsChirpyRed,
 	})
-
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "couldn't marshal json}`))
-	}
-
-	w.WriteHeader(http.StatusCreated)
-	w.Write([]byte(resp))
 }
 
 func (cfg *apiConfig) HandleLoginUser(w http.ResponseWriter, r *http.Request) {
@@ -77,97 +76,83 @@ func (cfg *apiConfig) HandleLoginUser(w http.ResponseWriter, r *http.Request) {
 	err := decoder.Decode(&params)
 
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "couldn't decode json}`))
+		respondError(w, http.StatusBadRequest, errCodeInvalidJSON, "couldn't decode json")
 		return
 	}
 
-	user, err := cfg.db.GetUserByEmail(context.Background(), params.Email)
+	user, err := cfg.db.GetUserByEmail(r.Context(), params.Email)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte(`{"error": "No user found"}`))
+			cfg.metrics.FailedLogins.Inc()
+			respondError(w, http.StatusUnauthorized, errCodeInvalidCredentials, "No user found")
 			return
 		}
-		w.WriteHeader(http.StatusBadRequest)
-		log.Println("Error fetching user by email:", err)
-		w.Write([]byte(`{"error": "couldn't get user}`))
+		slog.ErrorContext(r.Context(), "error fetching user by email", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't get user")
 		return
 	}
 
 	authenticated, err := auth.VerifyPassword(params.Password, user.Password)
 
 	if err != nil || !authenticated {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte(`{"error": "email or password is incorrect"}`))
+		cfg.metrics.FailedLogins.Inc()
+		respondError(w, http.StatusUnauthorized, errCodeInvalidCredentials, "email or password is incorrect")
 		return
 	}
 
-	expiresIn, err := time.ParseDuration(accessTokenExpiry)
-
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(`{"error": "error occurred"}`))
+	if user.DisabledAt.Valid {
+		respondError(w, http.StatusForbidden, errCodeAccountDisabled, "account is disabled")
 		return
 	}
 
-	jwtToken, err := auth.MakeJWT(user.ID, cfg.jwtSecret, expiresIn)
-
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(`{"error": "error occurred"}`))
-		return
+	if auth.NeedsRehash(user.Password) {
+		cfg.rehashPassword(r.Context(), user.ID, params.Password)
 	}
 
-	refreshToken, err := auth.MakeRefreshToken()
+	jwtToken, refreshToken, err := cfg.issueTokens(r.Context(), user.ID)
 
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(`{"error": "error occurred"}`))
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "error occurred")
 		return
 	}
 
-	expiresAt := time.Now().Add(time.Duration(refreshTokenExpiry) * time.Hour)
-
-	storedRefreshToken, err := cfg.db.CreateRefreshToken(context.Background(), database.CreateRefreshTokenParams{
-		UserID:    user.ID,
-		Token:     refreshToken,
-		ExpiresAt: expiresAt,
-	})
-
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(`{"error": "error occurred"}`))
-		return
-	}
+	cfg.metrics.Logins.Inc()
 
-	resp, _ := json.Marshal(map[string]interface{}{
+	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"id":            user.ID,
 		"created_at":    user.CreatedAt,
 		"updated_at":    user.UpdatedAt,
 		"email":         user.Email,
 		"is_chirpy_red": user.IsChirpyRed,
 		"token":         jwtToken,
-		"refresh_token": storedRefreshToken.Token,
+		"refresh_token": refreshToken,
 	})
-
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(resp))
 }
 
-func (cfg *apiConfig) HandleUpdateUsers(w http.ResponseWriter, r *http.Request) {
-	bearerToken, err := auth.GetBearerToken(r.Header)
+// rehashPassword upgrades a stored hash to the current parameters after a
+// successful login. Failing to do so must not fail the login.
+func (cfg *apiConfig) rehashPassword(ctx context.Context, userID uuid.UUID, password string) {
+	hashedPassword, err := auth.HashPassword(password)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
+		slog.ErrorContext(ctx, "error rehashing password", "error", err)
 		return
 	}
 
-	user_id, err := auth.ValidateJWT(bearerToken, cfg.jwtSecret)
+	err = cfg.db.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+		ID:       userID,
+		Password: hashedPassword,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "error storing rehashed password", "error", err)
+	}
+}
+
+func (cfg *apiConfig) HandleUpdateUsers(w http.ResponseWriter, r *http.Request) {
+	user_id, err := cfg.authenticate(r, auth.ScopeProfileWrite)
 
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte(fmt.Sprintf("error occurred getting validating token: %v", err)))
+		writeAuthenticationError(w, err)
 		return
 	}
 
@@ -180,29 +165,53 @@ func (cfg *apiConfig) HandleUpdateUsers(w http.ResponseWriter, r *http.Request)
 	err = decoder.Decode(&params)
 
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, errCodeInvalidJSON, "couldn't decode json")
+		return
+	}
+
+	if err := cfg.passwordPolicy.Validate(params.Email, params.Password); err != nil {
+		writePasswordPolicyError(w, err)
+		return
 	}
 
 	hashedPassword, err := auth.HashPassword(params.Password)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "error occurred")
 		return
 	}
 
-	user, err := cfg.db.UpdateUser(context.Background(), database.UpdateUserParams{
+	user, err := cfg.db.UpdateUser(r.Context(), database.UpdateUserParams{
 		Email:    params.Email,
 		Password: hashedPassword,
 		ID:       user_id,
 	})
 
-	resp, _ := json.Marshal(map[string]interface{}{
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error updating user", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't update user")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"id":            user.ID,
 		"created_at":    user.CreatedAt,
 		"updated_at":    user.UpdatedAt,
 		"email":         user.Email,
 		"is_chirpy_red": user.IsChirpyRed,
 	})
+}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write(resp)
+func writePasswordPolicyError(w http.ResponseWriter, err error) {
+	var policyErr *auth.PolicyError
+	if !errors.As(err, &policyErr) {
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "error occurred")
+		return
+	}
+
+	respondProblem(w, problem{
+		Status:     http.StatusBadRequest,
+		Code:       errCodePasswordPolicy,
+		Detail:     "password does not meet the password policy",
+		Extensions: map[string]any{"fields": policyErr.Errors},
+	})
 }
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ireoluwa12345/chirpy/internal/auth"
+	"github.com/ireoluwa12345/chirpy/internal/database"
+	"github.com/ireoluwa12345/chirpy/internal/webhook"
+)
+
+// The same handlers serve users under /api/webhooks and admins under
+// /admin/webhooks/endpoints. Users manage their own endpoints; admins
+// register endpoints that receive every event and can inspect any endpoint.
+
+type webhookEndpointResponse struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	OwnerID   *uuid.UUID `json:"owner_id"`
+	URL       string     `json:"url"`
+	Events    []string   `json:"events"`
+	Active    bool       `json:"active"`
+	// Secret is only returned when the endpoint is created.
+	Secret string `json:"secret,omitempty"`
+}
+
+func newWebhookEndpointResponse(endpoint database.WebhookEndpoint) webhookEndpointResponse {
+	resp := webhookEndpointResponse{
+		ID:        endpoint.ID,
+		CreatedAt: endpoint.CreatedAt,
+		UpdatedAt: endpoint.UpdatedAt,
+		URL:       endpoint.Url,
+		Events:    endpoint.Events,
+		Active:    endpoint.Active,
+	}
+	if endpoint.OwnerID.Valid {
+		resp.OwnerID = &endpoint.OwnerID.UUID
+	}
+	return resp
+}
+
+type webhookDeliveryResponse struct {
+	ID             uuid.UUID       `json:"id"`
+	CreatedAt      time.Time       `json:"created_at"`
+	EndpointID     uuid.UUID       `json:"endpoint_id"`
+	EventID        uuid.UUID       `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	LastStatusCode *int32          `json:"last_status_code"`
+	LastError      *string         `json:"last_error"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
+
+func newWebhookDeliveryResponse(delivery database.WebhookDelivery) webhookDeliveryResponse {
+	resp := webhookDeliveryResponse{
+		ID:         delivery.ID,
+		CreatedAt:  delivery.CreatedAt,
+		EndpointID: delivery.EndpointID,
+		EventID:    delivery.EventID,
+		EventType:  delivery.EventType,
+		Payload:    delivery.Payload,
+		Status:     delivery.Status,
+		Attempts:   delivery.Attempts,
+	}
+	if delivery.Status == deliveryPending {
+		resp.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	if delivery.LastStatusCode.Valid {
+		resp.LastStatusCode = &delivery.LastStatusCode.Int32
+	}
+	if delivery.LastError.Valid {
+		resp.LastError = &delivery.LastError.String
+	}
+	if delivery.DeliveredAt.Valid {
+		resp.DeliveredAt = &delivery.DeliveredAt.Time
+	}
+	return resp
+}
+
+// webhookEndpointOwner is the authenticated user for /api/webhooks, and no
+// one for the admin routes.
+func webhookEndpointOwner(r *http.Request) uuid.NullUUID {
+	if userID, ok := r.Context().Value("user_id").(uuid.UUID); ok {
+		return uuid.NullUUID{UUID: userID, Valid: true}
+	}
+	return uuid.NullUUID{}
+}
+
+func (cfg *apiConfig) HandleCreateWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	param := struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}{}
+
+	err := json.NewDecoder(r.Body).Decode(&param)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeInvalidJSON, "couldn't decode json")
+		return
+	}
+
+	err = webhook.CheckURL(r.Context(), net.DefaultResolver, param.URL)
+	if err != nil {
+		switch {
+		case errors.Is(err, webhook.ErrInvalidURL):
+			respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "url must be an absolute https URL")
+		case errors.Is(err, webhook.ErrBlockedAddress):
+			respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "url must point at a public address")
+		default:
+			respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "couldn't resolve the url's host")
+		}
+		return
+	}
+
+	if len(param.Events) == 0 {
+		respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "subscribe to at least one event")
+		return
+	}
+	for _, event := range param.Events {
+		if !outboundEventTypes[event] {
+			respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "unknown event type: "+event)
+			return
+		}
+	}
+
+	secret, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "error occurred")
+		return
+	}
+	secret = "whsec_" + secret
+
+	endpoint, err := cfg.db.CreateWebhookEndpoint(r.Context(), database.CreateWebhookEndpointParams{
+		ID:      uuid.New(),
+		OwnerID: webhookEndpointOwner(r),
+		Url:     param.URL,
+		Secret:  secret,
+		Events:  param.Events,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error creating webhook endpoint", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't create webhook endpoint")
+		return
+	}
+
+	body := newWebhookEndpointResponse(endpoint)
+	body.Secret = endpoint.Secret
+
+	respondJSON(w, http.StatusCreated, body)
+}
+
+func (cfg *apiConfig) HandleListWebhookEndpoints(w http.ResponseWriter, r *http.Request) {
+	endpoints, err := cfg.db.ListWebhookEndpoints(r.Context(), webhookEndpointOwner(r))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error listing webhook endpoints", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't get webhook endpoints")
+		return
+	}
+
+	body := make([]webhookEndpointResponse, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		body = append(body, newWebhookEndpointResponse(endpoint))
+	}
+
+	respondJSON(w, http.StatusOK, body)
+}
+
+// getWebhookEndpoint loads the endpoint named in the path, writing an error
+// response and returning false if it doesn't exist or belongs to someone
+// else.
+func (cfg *apiConfig) getWebhookEndpoint(w http.ResponseWriter, r *http.Request) (database.WebhookEndpoint, bool) {
+	endpointID, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeInvalidID, "invalid UUID")
+		return database.WebhookEndpoint{}, false
+	}
+
+	endpoint, err := cfg.db.GetWebhookEndpoint(r.Context(), endpointID)
+	if err != nil && err != sql.ErrNoRows {
+		slog.ErrorContext(r.Context(), "error fetching webhook endpoint", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't get webhook endpoint")
+		return endpoint, false
+	}
+
+	owner := webhookEndpointOwner(r)
+	if err == sql.ErrNoRows || (owner.Valid && endpoint.OwnerID != owner) {
+		respondError(w, http.StatusNotFound, errCodeNotFound, "webhook endpoint not found")
+		return endpoint, false
+	}
+
+	return endpoint, true
+}
+
+func (cfg *apiConfig) HandleDeleteWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := cfg.getWebhookEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.DeleteWebhookEndpoint(r.Context(), endpoint.ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error deleting webhook endpoint", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't delete webhook endpoint")
+		return
+	}
+
+	respondNoContent(w)
+}
+
+// HandleListWebhookDeliveries is the delivery log of one endpoint, newest
+// first.
+func (cfg *apiConfig) HandleListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := cfg.getWebhookEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 100
+	if limitString := r.URL.Query().Get("limit"); limitString != "" {
+		var err error
+		limit, err = strconv.Atoi(limitString)
+		if err != nil || limit < 1 || limit > 1000 {
+			respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "limit must be between 1 and 1000")
+			return
+		}
+	}
+
+	deliveries, err := cfg.db.ListWebhookDeliveries(r.Context(), database.ListWebhookDeliveriesParams{
+		EndpointID: endpoint.ID,
+		Limit:      int32(limit),
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error listing webhook deliveries", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't get webhook deliveries")
+		return
+	}
+
+	body := make([]webhookDeliveryResponse, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		body = append(body, newWebhookDeliveryResponse(delivery))
+	}
+
+	respondJSON(w, http.StatusOK, body)
+}
+
+// HandleRedeliverWebhook queues a logged delivery to be sent again as a new
+// delivery with a fresh set of attempts. The event ID is unchanged, so
+// receivers can deduplicate.
+func (cfg *apiConfig) HandleRedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := cfg.getWebhookEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	deliveryID, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeInvalidID, "invalid UUID")
+		return
+	}
+
+	delivery, err := cfg.db.GetWebhookDelivery(r.Context(), deliveryID)
+	if err != nil && err != sql.ErrNoRows {
+		slog.ErrorContext(r.Context(), "error fetching webhook delivery", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't get webhook delivery")
+		return
+	}
+	if err == sql.ErrNoRows || delivery.EndpointID != endpoint.ID {
+		respondError(w, http.StatusNotFound, errCodeNotFound, "webhook delivery not found")
+		return
+	}
+
+	redelivery, err := cfg.db.RedeliverWebhookDelivery(r.Context(), database.RedeliverWebhookDeliveryParams{
+		NewID:      uuid.New(),
+		DeliveryID: delivery.ID,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error queueing webhook redelivery", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't redeliver webhook")
+		return
+	}
+
+	respondJSON(w, http.StatusAccepted, newWebhookDeliveryResponse(redelivery))
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ireoluwa12345/chirpy/internal/billing"
+	"github.com/ireoluwa12345/chirpy/internal/database"
+)
+
+// Statuses of a row in webhook_events.
+const (
+	webhookEventReceived   = "received"
+	webhookEventProcessing = "processing"
+	webhookEventProcessed  = "processed"
+	webhookEventIgnored    = "ignored"
+	webhookEventFailed     = "failed"
+)
+
+var (
+	errWebhookUserNotFound = errors.New("webhook refers to an unknown user")
+	errWebhookEventClaimed = errors.New("webhook event is already processing or handled")
+)
+
+// replayStatuses are the statuses an event can be replayed from. Only failed
+// events are replayed unless force is set, since re-applying a processed
+// event repeats its effects.
+func replayStatuses(force bool) []string {
+	if force {
+		return []string{webhookEventReceived, webhookEventFailed, webhookEventProcessed, webhookEventIgnored}
+	}
+	return []string{webhookEventFailed}
+}
+
+// claimWebhookEvent marks an event as processing if its status is one of
+// statuses, so concurrent deliveries and replays of the same event don't
+// both apply it. It returns errWebhookEventClaimed if the event is in any
+// other status.
+func (cfg *apiConfig) claimWebhookEvent(ctx context.Context, id uuid.UUID, statuses ...string) (database.WebhookEvent, error) {
+	event, err := cfg.db.ClaimWebhookEvent(ctx, database.ClaimWebhookEventParams{
+		ID:       id,
+		Statuses: statuses,
+	})
+	if err == sql.ErrNoRows {
+		return database.WebhookEvent{}, errWebhookEventClaimed
+	}
+	return event, err
+}
+
+// recordWebhookEvent stores an incoming event in the event log and claims it
+// for processing. It reports a duplicate when the same event was already
+// handled or is being processed by another delivery, in which case the
+// delivery should be acknowledged without processing it. Events whose
+// earlier processing failed are claimed for another attempt.
+func (cfg *apiConfig) recordWebhookEvent(ctx context.Context, provider, eventID, eventType string, payload []byte) (database.WebhookEvent, bool, error) {
+	event, err := cfg.db.CreateWebhookEvent(ctx, database.CreateWebhookEventParams{
+		ID:        uuid.New(),
+		Provider:  provider,
+		EventID:   eventID,
+		EventType: eventType,
+		Payload:   payload,
+	})
+	if err == sql.ErrNoRows {
+		event, err = cfg.db.GetWebhookEvent(ctx, database.GetWebhookEventParams{
+			Provider: provider,
+			EventID:  eventID,
+		})
+	}
+	if err != nil {
+		return database.WebhookEvent{}, false, err
+	}
+
+	event, err = cfg.claimWebhookEvent(ctx, event.ID, webhookEventReceived, webhookEventFailed)
+	if err == errWebhookEventClaimed {
+		return database.WebhookEvent{}, true, nil
+	}
+	if err != nil {
+		return database.WebhookEvent{}, false, err
+	}
+	return event, false, nil
+}
+
+// processWebhookEvent applies an event claimed with claimWebhookEvent and
+// records the outcome.
+func (cfg *apiConfig) processWebhookEvent(ctx context.Context, event database.WebhookEvent) (database.WebhookEvent, error) {
+	var (
+		status string
+		err    error
+	)
+	if provider, ok := cfg.billingProviders[event.Provider]; ok {
+		var parsed billing.Event
+		parsed, err = provider.ParseEvent(nil, event.Payload)
+		if err == nil {
+			status, err = cfg.applyBillingEvent(ctx, parsed)
+		}
+	} else {
+		err = fmt.Errorf("no handler for %s webhooks", event.Provider)
+	}
+
+	if err != nil {
+		cfg.metrics.WebhookEvents.WithLabelValues(event.Provider, webhookEventFailed).Inc()
+		slog.ErrorContext(ctx, "error processing billing event", "provider", event.Provider, "event_id", event.EventID, "error", err)
+		updated, markErr := cfg.db.MarkWebhookEventFailed(ctx, database.MarkWebhookEventFailedParams{
+			ID:    event.ID,
+			Error: sql.NullString{String: err.Error(), Valid: true},
+		})
+		if markErr != nil {
+			slog.ErrorContext(ctx, "error recording webhook failure", "error", markErr)
+			return event, err
+		}
+		return updated, err
+	}
+
+	cfg.metrics.WebhookEvents.WithLabelValues(event.Provider, status).Inc()
+
+	return cfg.db.MarkWebhookEventProcessed(ctx, database.MarkWebhookEventProcessedParams{
+		ID:     event.ID,
+		Status: status,
+	})
+}
+
+type webhookEventResponse struct {
+	ID          uuid.UUID       `json:"id"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	Provider    string          `json:"provider"`
+	EventID     string          `json:"event_id"`
+	EventType   string          `json:"event_type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Error       *string         `json:"error"`
+	Attempts    int32           `json:"attempts"`
+	ProcessedAt *time.Time      `json:"processed_at"`
+}
+
+func newWebhookEventResponse(event database.WebhookEvent) webhookEventResponse {
+	resp := webhookEventResponse{
+		ID:        event.ID,
+		CreatedAt: event.CreatedAt,
+		UpdatedAt: event.UpdatedAt,
+		Provider:  event.Provider,
+		EventID:   event.EventID,
+		EventType: event.EventType,
+		Payload:   event.Payload,
+		Status:    event.Status,
+		Attempts:  event.Attempts,
+	}
+	if event.Error.Valid {
+		resp.Error = &event.Error.String
+	}
+	if event.ProcessedAt.Valid {
+		resp.ProcessedAt = &event.ProcessedAt.Time
+	}
+	return resp
+}
+
+// HandleListWebhookEvents lists logged webhook events, newest first,
+// optionally filtered by ?status=.
+func (cfg *apiConfig) HandleListWebhookEvents(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+
+	limit := 100
+	if limitString := r.URL.Query().Get("limit"); limitString != "" {
+		var err error
+		limit, err = strconv.Atoi(limitString)
+		if err != nil || limit < 1 || limit > 1000 {
+			respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "limit must be between 1 and 1000")
+			return
+		}
+	}
+
+	events, err := cfg.db.ListWebhookEvents(r.Context(), database.ListWebhookEventsParams{
+		Status:     sql.NullString{String: status, Valid: status != ""},
+		MaxResults: int32(limit),
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error listing webhook events", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't get webhook events")
+		return
+	}
+	body := make([]webhookEventResponse, 0, len(events))
+	for _, event := range events {
+		body = append(body, newWebhookEventResponse(event))
+	}
+
+	respondJSON(w, http.StatusOK, body)
+}
+
+// HandleReplayWebhookEvent processes a failed event again. Events that were
+// processed or ignored are only replayed with ?force=true.
+func (cfg *apiConfig) HandleReplayWebhookEvent(w http.ResponseWriter, r *http.Request) {
+	eventID, err := uuid.Parse(r.PathValue("eventID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeInvalidID, "invalid UUID")
+		return
+	}
+
+	force := false
+	if forceString := r.URL.Query().Get("force"); forceString != "" {
+		force, err = strconv.ParseBool(forceString)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, errCodeInvalidRequest, "force must be true or false")
+			return
+		}
+	}
+
+	event, err := cfg.db.GetWebhookEventByID(r.Context(), eventID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, errCodeNotFound, "webhook event not found")
+			return
+		}
+		slog.ErrorContext(r.Context(), "error fetching webhook event", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't get webhook event")
+		return
+	}
+
+	claimed, err := cfg.claimWebhookEvent(r.Context(), event.ID, replayStatuses(force)...)
+	if err == errWebhookEventClaimed {
+		respondError(w, http.StatusConflict, errCodeConflict, fmt.Sprintf("webhook event is %s; only failed events are replayed without force=true", event.Status))
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error claiming webhook event", "error", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "couldn't replay webhook event")
+		return
+	}
+
+	// A failed replay is still reported with the updated event so the
+	// caller can see the error.
+	event, _ = cfg.processWebhookEvent(r.Context(), claimed)
+
+	respondJSON(w, http.StatusOK, newWebhookEventResponse(event))
+}